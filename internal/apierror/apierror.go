@@ -0,0 +1,167 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"gorm.io/gorm"
+)
+
+// Code is a stable, machine readable error code. Clients should switch on
+// the code rather than on the HTTP status or the detail message.
+type Code string
+
+const (
+	CodeBadRequest   Code = "bad_request"
+	CodeValidation   Code = "validation_failed"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeDatabase     Code = "database_error"
+	CodeInternal     Code = "internal_error"
+)
+
+// Error is an error that can be written as a problem details response.
+type Error struct {
+	Status int
+	Code   Code
+	Detail string
+	Fields []payload.FieldError
+
+	// Err is the underlying cause. It is logged but never sent to the client.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// BadRequest is returned for requests that can not be parsed, such as
+// malformed JSON or invalid path parameters.
+func BadRequest(detail string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: detail}
+}
+
+// Unauthorized is returned when credentials or tokens are missing or invalid.
+func Unauthorized(detail string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Detail: detail}
+}
+
+// Forbidden is returned when the user is authenticated but not allowed to
+// perform the request.
+func Forbidden(detail string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: CodeForbidden, Detail: detail}
+}
+
+// NotFound is returned when the named resource does not exist, e.g.
+// NotFound("User").
+func NotFound(resource string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Detail: resource + " not found"}
+}
+
+// Conflict is returned when a request conflicts with existing data.
+func Conflict(detail string) *Error {
+	return &Error{Status: http.StatusConflict, Code: CodeConflict, Detail: detail}
+}
+
+// Internal is returned for unexpected server errors.
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: "Internal server error", Err: err}
+}
+
+// Invalid returns a validation error for a single field.
+func Invalid(field string, code string, message string) *Error {
+	return InvalidFields([]payload.FieldError{{Field: field, Code: code, Message: message}})
+}
+
+// InvalidFields returns a validation error for the given fields.
+func InvalidFields(fields []payload.FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Detail: "The request failed validation", Fields: fields}
+}
+
+// Validation converts an error returned while binding or validating a
+// request body into an Error with field-level details.
+func Validation(err error) *Error {
+	var apiErr *Error
+	var validationErrors validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &validationErrors):
+		fields := make([]payload.FieldError, 0, len(validationErrors))
+		for _, fe := range validationErrors {
+			fields = append(fields, payload.FieldError{Field: fe.Field(), Code: fe.Tag(), Message: message(fe)})
+		}
+		e := InvalidFields(fields)
+		e.Err = err
+		return e
+	case errors.As(err, &typeErr):
+		e := Invalid(typeErr.Field, "type", "must be a "+typeErr.Type.String())
+		e.Err = err
+		return e
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: "Request body is not valid JSON", Err: err}
+	case errors.Is(err, io.EOF):
+		return BadRequest("Request body is empty")
+	default:
+		return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: "Invalid request", Err: err}
+	}
+}
+
+// Database converts an error returned by GORM into an Error. Missing
+// records become not found errors and constraint violations become
+// conflicts. Anything else is reported as a database error without
+// exposing the underlying message.
+func Database(resource string, err error) *Error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		e := NotFound(resource)
+		e.Err = err
+		return e
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Detail: resource + " already exists", Err: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Detail: resource + " references a record that does not exist", Err: err}
+	default:
+		return &Error{Status: http.StatusInternalServerError, Code: CodeDatabase, Detail: "A database error occurred", Err: err}
+	}
+}
+
+// Returns a human readable message for a validator field error.
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "datetime":
+		return "must be in the format " + fe.Param()
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,58 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestValidationFieldErrors(t *testing.T) {
+	var r struct {
+		Sys  uint16 `json:"systolic" binding:"required"`
+		Dia  uint16 `json:"diastolic" binding:"required,max=200"`
+		Note string `json:"-" binding:"required"`
+	}
+	r.Dia = 300
+
+	e := Validation(binding.Validator.ValidateStruct(&r))
+
+	assert.Equal(t, http.StatusBadRequest, e.Status)
+	assert.Equal(t, CodeValidation, e.Code)
+	assert.Len(t, e.Fields, 3)
+	assert.Equal(t, "systolic", e.Fields[0].Field)
+	assert.Equal(t, "required", e.Fields[0].Code)
+	assert.Equal(t, "diastolic", e.Fields[1].Field)
+	assert.Equal(t, "must be at most 200", e.Fields[1].Message)
+}
+
+func TestValidationJsonErrors(t *testing.T) {
+	var r struct {
+		Sys uint16 `json:"systolic"`
+	}
+
+	e := Validation(json.Unmarshal([]byte(`{"systolic": "high"}`), &r))
+	assert.Equal(t, CodeValidation, e.Code)
+	assert.Equal(t, "systolic", e.Fields[0].Field)
+
+	e = Validation(json.Unmarshal([]byte(`{"systolic": `), &r))
+	assert.Equal(t, CodeBadRequest, e.Code)
+
+	e = Validation(Invalid("time", "future", "must not be in the future"))
+	assert.Equal(t, "time", e.Fields[0].Field)
+}
+
+func TestDatabase(t *testing.T) {
+	assert.Equal(t, CodeNotFound, Database("User", gorm.ErrRecordNotFound).Code)
+	assert.Equal(t, CodeConflict, Database("User", gorm.ErrDuplicatedKey).Code)
+	assert.Equal(t, CodeConflict, Database("User", gorm.ErrForeignKeyViolated).Code)
+
+	e := Database("User", errors.New("connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, e.Status)
+	assert.Equal(t, CodeDatabase, e.Code)
+	assert.NotContains(t, e.Detail, "connection refused")
+}
@@ -0,0 +1,65 @@
+package apierror
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+)
+
+// ContentType is the media type of problem details responses.
+const ContentType = "application/problem+json"
+
+// RequestIdHeader carries the id of the current request. It is set by
+// middleware.RequestId and echoed in every problem details response.
+const RequestIdHeader = "X-Request-Id"
+
+func init() {
+	// Report validation errors using the JSON field names of the request
+	// payloads rather than the Go struct field names.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// Abort writes err as a problem details response and stops the handler
+// chain. Errors that are not an *Error are reported as internal errors.
+func Abort(c *gin.Context, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal(err)
+	}
+
+	if e.Err != nil || e.Status >= http.StatusInternalServerError {
+		log.Print(e)
+	}
+
+	problem := payload.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  c.Request.URL.Path,
+		Code:      string(e.Code),
+		RequestId: c.Writer.Header().Get(RequestIdHeader),
+		Errors:    e.Fields,
+	}
+
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(e.Status, problem)
+}
@@ -0,0 +1,49 @@
+// Package blobstore keeps the files attached to vital readings. The API
+// only depends on the Store interface so the local filesystem store can be
+// replaced by e.g. an object storage service.
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrNotFound is returned by Store.Open if there is no blob with the key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs by key. Keys are slash separated paths such as
+// "users/1/3kTMd2jaBQ9x".
+type Store interface {
+	// Put stores the contents of r under key, replacing any existing blob.
+	Put(key string, r io.Reader) error
+
+	// Open returns the contents of the blob with the key. The caller must
+	// close it.
+	Open(key string) (io.ReadCloser, error)
+
+	// Delete removes the blob with the key. Deleting a blob that does not
+	// exist is not an error.
+	Delete(key string) error
+}
+
+var (
+	mu    sync.RWMutex
+	store Store
+)
+
+// Current returns the store in effect, or nil if none is configured.
+func Current() Store {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return store
+}
+
+// Configure replaces the store in effect.
+func Configure(s Store) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	store = s
+}
@@ -0,0 +1,46 @@
+package blobstore
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocal(dir)
+
+	require.NoError(t, store.Put("users/1/photo", strings.NewReader("cuff")))
+	require.NoError(t, store.Put("users/1/photo", strings.NewReader("display")))
+
+	f, err := store.Open("users/1/photo")
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(f)
+	f.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "display", string(data))
+
+	entries, err := os.ReadDir(filepath.Join(dir, "users", "1"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must be removed")
+
+	require.NoError(t, store.Delete("users/1/photo"))
+	require.NoError(t, store.Delete("users/1/photo"))
+
+	_, err = store.Open("users/1/photo")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLocalInvalidKeys(t *testing.T) {
+	store := NewLocal(t.TempDir())
+
+	for _, key := range []string{"", "../escape", "users/../../escape", "/absolute", `users\1`} {
+		assert.Error(t, store.Put(key, strings.NewReader("x")), key)
+	}
+}
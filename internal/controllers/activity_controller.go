@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+var activityResource = NewVitalResource("Activity", payload.ApplyActivityRequest, payload.MapActivityResponse, validation.Activity).
+	WithBeforeDelete(deleteAttachments[*models.Activity]("activities")).
+	WithBeforeSave(estimateActivityCalories).
+	WithFields(
+		TextField("type", "type"),
+		NumberField("durationMinutes", "duration_minutes"),
+		NumberField("distanceKm", "distance_km"),
+		NumberField("calories", "calories"),
+		NumberField("averageHeartRate", "avg_heart_rate"),
+	).
+	WithStats("durationMinutes", "distanceKm", "calories")
+
+// GET /users/:id/activities
+// Get all activity records for a user.
+//
+// Swagger Doc
+// @Summary Get all activity records for a user.
+// @Schemes
+// @Description Get all activity records for a user. Filter on type, e.g. type=run, and on durationMinutes, distanceKm, calories and averageHeartRate with the _gt, _gte, _lt, _lte and _ne suffixes, e.g. durationMinutes_gte=30.
+// @Tags Activity
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.ActivityResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/activities [get]
+// @Security Bearer
+func GetActivityByUserId(c *gin.Context) {
+	activityResource.List(c)
+}
+
+// GET /users/:id/activities/:recordId
+// Get a activity record for user.
+//
+// Swagger Doc
+// @Summary Get a activity record for user.
+// @Schemes
+// @Description Get a activity record for user.
+// @Tags Activity
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Activity ID"
+// @Success 200 {object} payload.ActivityResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/activities/{recordId} [get]
+// @Security Bearer
+func GetActivityById(c *gin.Context) {
+	activityResource.Get(c)
+}
+
+// POST /users/:id/activities
+// Adds a new activity record for user.
+//
+// Swagger Doc
+// @Summary Adds a new activity record for user.
+// @Schemes
+// @Description Adds a new activity record for user. Time is the start of the activity and is optional. If not provided, current time is used. If calories are not provided they are estimated from the MET value of the activity type and the user's latest weight in kilograms. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Activity
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.ActivityRequest true "User Activity"
+// @Success 200 {object} payload.ActivityResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/activities [post]
+// @Security Bearer
+func PostActivityByUserId(c *gin.Context) {
+	activityResource.Create(c)
+}
+
+// PUT /users/:id/activities/:recordId
+// Updates a activity record for user.
+//
+// Swagger Doc
+// @Summary Updates a activity record for user.
+// @Schemes
+// @Description Updates a activity record for user. Time is optional. If not provided, the existing time is kept. If calories are not provided they are estimated from the MET value of the activity type and the user's latest weight in kilograms. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Activity
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Activity ID"
+// @Param user body payload.ActivityRequest true "User Activity"
+// @Success 200 {object} payload.ActivityResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/activities/{recordId} [put]
+// @Security Bearer
+func PutActivityByUserId(c *gin.Context) {
+	activityResource.Update(c)
+}
+
+// DELETE /users/:id/activities/:recordId
+// Deletes a activity record for user.
+//
+// Swagger Doc
+// @Summary Deletes a activity record for user.
+// @Schemes
+// @Description Deletes a activity record for user.
+// @Tags Activity
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Activity ID"
+// @Success 200 {object} payload.ActivityResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/activities/{recordId} [delete]
+// @Security Bearer
+func DeleteActivityByUserId(c *gin.Context) {
+	activityResource.Delete(c)
+}
+
+// The most weeks the weekly totals can cover, like the periods of the
+// stats endpoints.
+const maxActivityWeeks = maxStatsPeriods
+
+// GET /users/:id/activities/weekly
+// Get weekly activity totals for a user.
+//
+// Swagger Doc
+// @Summary Get weekly activity totals for a user.
+// @Schemes
+// @Description Summarizes the activities of each week (Monday to Sunday) between from and to (default: the last 12 weeks, at most 400 weeks), oldest first. Weeks without activities are included. Activities of 3 to 6 METs count as moderate and activities of 6 METs or more as vigorous; the weekly goal of 150 minutes counts vigorous minutes twice.
+// @Tags Activity
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param from query string false "Start of the window, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "End of the window, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Success 200 {array} payload.ActivityWeekResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/activities/weekly [get]
+// @Security Bearer
+func GetActivityWeeklyByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseTimeRange(c, 12*7)
+	if !ok {
+		return
+	}
+
+	if from.AddDate(0, 0, 7*maxActivityWeeks).Before(to) {
+		apierror.Abort(c, apierror.Invalid("from", "range", fmt.Sprintf("must not be more than %d weeks before to", maxActivityWeeks)))
+		return
+	}
+
+	var activities []models.Activity
+	err := models.DB.Where("user_id = ? AND time >= ? AND time < ?", u.ID, from.UTC(), to.UTC()).Find(&activities).Error
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Activity", err))
+		return
+	}
+
+	var weeks []payload.ActivityWeekResponse
+	index := map[string]int{}
+
+	last := weekStart(to.Add(-time.Nanosecond))
+	for week := weekStart(from); !week.After(last); week = week.AddDate(0, 0, 7) {
+		start := week.Format(time.DateOnly)
+		index[start] = len(weeks)
+		weeks = append(weeks, payload.ActivityWeekResponse{WeekStart: start, Types: map[string]int{}})
+	}
+
+	for _, a := range activities {
+		i, ok := index[weekStart(a.Time.In(from.Location())).Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+
+		week := &weeks[i]
+		minutes := int(a.DurationMinutes)
+
+		week.Sessions++
+		week.ActiveMinutes += minutes
+		week.Types[a.Type] += minutes
+
+		if a.DistanceKm != nil {
+			week.DistanceKm += *a.DistanceKm
+		}
+
+		if a.Calories != nil {
+			week.Calories += *a.Calories
+		}
+
+		switch met := models.ActivityMETs[a.Type]; {
+		case met >= 6:
+			week.VigorousMinutes += minutes
+		case met >= 3:
+			week.ModerateMinutes += minutes
+		}
+	}
+
+	for i := range weeks {
+		week := &weeks[i]
+		week.DistanceKm = math.Round(week.DistanceKm*100) / 100
+		week.GoalMinutes = week.ModerateMinutes + 2*week.VigorousMinutes
+		week.GoalMet = week.GoalMinutes >= 150
+	}
+
+	c.JSON(http.StatusOK, weeks)
+}
+
+// Estimates the calories burned from the user's latest weight at the time
+// of the activity, or their latest weight if there is none. Without any
+// weight the calories are left empty.
+func estimateActivityCalories(tx *gorm.DB, a *models.Activity) error {
+	if a.Calories != nil {
+		return nil
+	}
+
+	var w models.Weight
+	err := tx.Where("user_id = ? AND time <= ?", a.UserID, a.Time).Order("time DESC").First(&w).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = tx.Where("user_id = ?", a.UserID).Order("time DESC").First(&w).Error
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	calories := payload.EstimateActivityCalories(a.Type, w.Kilograms, a.DurationMinutes)
+	a.Calories = &calories
+	a.CaloriesEstimated = true
+
+	return nil
+}
+
+// Returns midnight of the Monday of the week containing t.
+func weekStart(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -days).Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,552 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register the decoders of the accepted image types
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/blobstore"
+	"github.com/zenkimoto/vitals-server-api/internal/env"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/util"
+	"gorm.io/gorm"
+)
+
+const (
+	// How long the signed download URLs of an attachment are valid.
+	attachmentUrlTTL = 15 * time.Minute
+
+	// The maximum width and height of a thumbnail in pixels.
+	thumbnailSize = 256
+
+	// The maximum number of pixels of an image attachment. Images are
+	// decoded to make a thumbnail, which takes 4 bytes per pixel, and a
+	// small file can declare a huge size.
+	maxImagePixels = 40_000_000
+)
+
+// The content types accepted as attachments, as sniffed from the file.
+// Images get a thumbnail.
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"application/pdf": false,
+}
+
+// RegisterAttachments adds the routes of the attachments of every reading
+// to the group. The values of a custom metric are below the key of the
+// metric, e.g. /users/:id/metrics/steps/:recordId/attachments.
+func RegisterAttachments(group gin.IRoutes) {
+	vitals := make([]string, 0, len(readingModels))
+	for vital := range readingModels {
+		vitals = append(vitals, vital)
+	}
+	sort.Strings(vitals)
+
+	for _, vital := range vitals {
+		path := "/users/:id/" + vital + "/:recordId/attachments"
+		if vital == "metrics" {
+			path = "/users/:id/metrics/:metricKey/:recordId/attachments"
+		}
+
+		group.GET(path, GetAttachmentsByReading(vital))
+		group.POST(path, PostAttachmentByReading(vital))
+	}
+
+	group.GET("/users/:id/attachments/:attachmentId", GetAttachmentById)
+	group.DELETE("/users/:id/attachments/:attachmentId", DeleteAttachmentByUserId)
+}
+
+// GET /users/:id/{vital}/:recordId/attachments
+// Get the attachments of a reading.
+//
+// Swagger Doc
+// @Summary Get the attachments of a reading.
+// @Schemes
+// @Description Get the files attached to a reading, oldest first, with signed download URLs. Values of custom metrics have their attachments at /users/{id}/metrics/{metricKey}/{recordId}/attachments.
+// @Tags Attachment
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param vital path string true "Path name of the vital, e.g. blood-pressure or journal"
+// @Param recordId path int true "Reading ID"
+// @Success 200 {array} payload.AttachmentResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/{vital}/{recordId}/attachments [get]
+// @Security Bearer
+func GetAttachmentsByReading(vital string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, readingId, ok := findReading(c, vital)
+		if !ok {
+			return
+		}
+
+		var attachments []models.Attachment
+		err := models.DB.Where("user_id = ? AND reading_type = ? AND reading_id = ?", userId, vital, readingId).
+			Order("created_at").
+			Find(&attachments).Error
+		if err != nil {
+			apierror.Abort(c, apierror.Database("Attachment", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, Map(attachments, func(a models.Attachment) payload.AttachmentResponse {
+			return attachmentResponse(c, a)
+		}))
+	}
+}
+
+// POST /users/:id/{vital}/:recordId/attachments
+// Attaches a file to a reading.
+//
+// Swagger Doc
+// @Summary Attaches a file to a reading.
+// @Schemes
+// @Description Attaches a photo (JPEG, PNG or GIF) or PDF document to a reading, e.g. a photo of the display of the device. The type is detected from the contents of the file. Photos get a thumbnail of at most 256x256 pixels. The maximum size defaults to 10 MiB. Values of custom metrics take attachments at /users/{id}/metrics/{metricKey}/{recordId}/attachments.
+// @Tags Attachment
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "User ID"
+// @Param vital path string true "Path name of the vital, e.g. blood-pressure or journal"
+// @Param recordId path int true "Reading ID"
+// @Param file formData file true "Photo or PDF document"
+// @Success 200 {object} payload.AttachmentResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/{vital}/{recordId}/attachments [post]
+// @Security Bearer
+func PostAttachmentByReading(vital string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := blobstore.Current()
+		if store == nil {
+			apierror.Abort(c, apierror.Internal(errors.New("no blob store is configured")))
+			return
+		}
+
+		userId, readingId, ok := findReading(c, vital)
+		if !ok {
+			return
+		}
+
+		data, fileName, ok := readAttachment(c)
+		if !ok {
+			return
+		}
+
+		contentType := http.DetectContentType(data)
+		hasThumbnail, ok := attachmentTypes[contentType]
+		if !ok {
+			apierror.Abort(c, apierror.Invalid("file", "mimetype", "must be a JPEG, PNG or GIF image or a PDF document"))
+			return
+		}
+
+		a := models.Attachment{
+			UserID:      userId,
+			ReadingType: vital,
+			ReadingID:   readingId,
+			FileName:    fileName,
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			BlobKey:     fmt.Sprintf("users/%d/%s", userId, util.RandString(24)),
+		}
+
+		var thumbnail bytes.Buffer
+		if hasThumbnail {
+			config, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				apierror.Abort(c, apierror.Invalid("file", "image", "is not a valid image"))
+				return
+			}
+
+			if int64(config.Width)*int64(config.Height) > maxImagePixels {
+				apierror.Abort(c, apierror.Invalid("file", "max", fmt.Sprintf("must not have more than %d pixels", maxImagePixels)))
+				return
+			}
+
+			img, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				apierror.Abort(c, apierror.Invalid("file", "image", "is not a valid image"))
+				return
+			}
+
+			if err := jpeg.Encode(&thumbnail, util.Thumbnail(img, thumbnailSize), nil); err != nil {
+				apierror.Abort(c, apierror.Internal(err))
+				return
+			}
+
+			a.ThumbnailKey = a.BlobKey + "-thumbnail"
+		}
+
+		if err := store.Put(a.BlobKey, bytes.NewReader(data)); err != nil {
+			apierror.Abort(c, apierror.Internal(err))
+			return
+		}
+
+		if a.ThumbnailKey != "" {
+			if err := store.Put(a.ThumbnailKey, &thumbnail); err != nil {
+				deleteBlobs(store, a.BlobKey)
+				apierror.Abort(c, apierror.Internal(err))
+				return
+			}
+		}
+
+		if err := models.DB.Create(&a).Error; err != nil {
+			deleteBlobs(store, a.BlobKey, a.ThumbnailKey)
+			apierror.Abort(c, apierror.Database("Attachment", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, attachmentResponse(c, a))
+	}
+}
+
+// GET /users/:id/attachments/:attachmentId
+// Get an attachment of a user.
+//
+// Swagger Doc
+// @Summary Get an attachment of a user.
+// @Schemes
+// @Description Get an attachment of a user with new signed download URLs.
+// @Tags Attachment
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 200 {object} payload.AttachmentResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/attachments/{attachmentId} [get]
+// @Security Bearer
+func GetAttachmentById(c *gin.Context) {
+	a, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, attachmentResponse(c, a))
+}
+
+// DELETE /users/:id/attachments/:attachmentId
+// Deletes an attachment of a user.
+//
+// Swagger Doc
+// @Summary Deletes an attachment of a user.
+// @Schemes
+// @Description Deletes an attachment of a user and its file.
+// @Tags Attachment
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 200 {object} payload.AttachmentResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/attachments/{attachmentId} [delete]
+// @Security Bearer
+func DeleteAttachmentByUserId(c *gin.Context) {
+	a, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DB.Delete(&a).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Attachment", err))
+		return
+	}
+
+	if store := blobstore.Current(); store != nil {
+		deleteBlobs(store, a.BlobKey, a.ThumbnailKey)
+	}
+
+	c.JSON(http.StatusOK, payload.MapAttachmentResponse(a))
+}
+
+// GET /attachments/:attachmentId/:variant
+// Downloads an attachment.
+//
+// Swagger Doc
+// @Summary Downloads an attachment.
+// @Schemes
+// @Description Downloads the file or the thumbnail of an attachment. The URL is signed for the owner of the reading and is returned in the url and thumbnailUrl of the attachment; no Authorization header is needed.
+// @Tags Attachment
+// @Produce image/jpeg,image/png,image/gif,application/pdf
+// @Param attachmentId path int true "Attachment ID"
+// @Param variant path string true "file or thumbnail" Enums(file, thumbnail)
+// @Param user query int true "User ID"
+// @Param expires query int true "Expiry as a Unix timestamp"
+// @Param signature query string true "Signature"
+// @Success 200 {file} file
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /attachments/{attachmentId}/{variant} [get]
+func GetAttachmentContent(c *gin.Context) {
+	variant := c.Param("variant")
+	if variant != "file" && variant != "thumbnail" {
+		apierror.Abort(c, apierror.NotFound("Attachment"))
+		return
+	}
+
+	id, err1 := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	userId, err2 := strconv.ParseUint(c.Query("user"), 10, 32)
+	expires, err3 := strconv.ParseInt(c.Query("expires"), 10, 64)
+
+	if err := errors.Join(err1, err2, err3); err != nil ||
+		time.Now().Unix() > expires ||
+		!util.Verify(env.GetAttachmentSigningKey(), downloadMessage(uint(id), uint(userId), variant, expires), c.Query("signature")) {
+		apierror.Abort(c, apierror.Forbidden("The download URL is invalid or has expired"))
+		return
+	}
+
+	var a models.Attachment
+	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&a).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Attachment", err))
+		return
+	}
+
+	key, contentType, size := a.BlobKey, a.ContentType, a.Size
+	if variant == "thumbnail" {
+		key, contentType, size = a.ThumbnailKey, "image/jpeg", -1
+	}
+
+	store := blobstore.Current()
+	if key == "" || store == nil {
+		apierror.Abort(c, apierror.NotFound("Attachment"))
+		return
+	}
+
+	f, err := store.Open(key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		apierror.Abort(c, apierror.NotFound("Attachment"))
+		return
+	} else if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+	defer f.Close()
+
+	c.DataFromReader(http.StatusOK, size, contentType, f, map[string]string{
+		"Cache-Control":          "private, max-age=" + strconv.Itoa(int(attachmentUrlTTL.Seconds())),
+		"Content-Disposition":    mime.FormatMediaType("inline", map[string]string{"filename": a.FileName}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// Checks that the reading identified by the :id and :recordId path
+// parameters, and the :metricKey of custom metrics, exists and belongs to
+// the user of the token, and returns the ids. On failure a problem details
+// response is written and false is returned.
+func findReading(c *gin.Context, vital string) (uint, uint, bool) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid user id"))
+		return 0, 0, false
+	}
+
+	if !checkSelf(c, uint(userId), "Users can only access the attachments of their own readings") {
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("recordId"), 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid reading id"))
+		return 0, 0, false
+	}
+
+	db := models.DB.Model(readingModels[vital]())
+	if vital == "metrics" {
+		d, ok := findMetricDefinition(c, uint(userId))
+		if !ok {
+			return 0, 0, false
+		}
+		db = db.Where("metric_definition_id = ?", d.ID)
+	}
+
+	var count int64
+	if err := db.Where("id = ? AND user_id = ?", id, userId).Count(&count).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Reading", err))
+		return 0, 0, false
+	}
+
+	if count == 0 {
+		apierror.Abort(c, apierror.NotFound("Reading"))
+		return 0, 0, false
+	}
+
+	return uint(userId), uint(id), true
+}
+
+// Finds the attachment identified by the :id and :attachmentId path
+// parameters, which must belong to the user of the token. On failure a
+// problem details response is written and false is returned.
+func findAttachment(c *gin.Context) (models.Attachment, bool) {
+	var a models.Attachment
+
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid user id"))
+		return a, false
+	}
+
+	if !checkSelf(c, uint(userId), "Users can only access their own attachments") {
+		return a, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid attachment id"))
+		return a, false
+	}
+
+	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&a).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Attachment", err))
+		return a, false
+	}
+
+	return a, true
+}
+
+// Reads the file form field, which must not be larger than the configured
+// maximum size, and returns its contents and base name. On failure a
+// problem details response is written and false is returned.
+func readAttachment(c *gin.Context) ([]byte, string, bool) {
+	maxBytes := env.GetAttachmentMaxBytes()
+	tooLarge := apierror.Invalid("file", "max", fmt.Sprintf("must not be larger than %d bytes", maxBytes))
+
+	// Allow for the multipart headers around the file.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+64<<10)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			apierror.Abort(c, tooLarge)
+		} else {
+			apierror.Abort(c, apierror.Invalid("file", "required", "is required"))
+		}
+		return nil, "", false
+	}
+
+	if header.Size > maxBytes {
+		apierror.Abort(c, tooLarge)
+		return nil, "", false
+	}
+
+	data, err := readFormFile(header)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return nil, "", false
+	}
+
+	name := filepath.Base(strings.ReplaceAll(header.Filename, `\`, "/"))
+	if len(name) > 255 {
+		name = name[:255]
+	}
+
+	return data, name, true
+}
+
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// Maps an attachment to its response with download URLs signed for the
+// owner. The URLs use the API version of the request.
+func attachmentResponse(c *gin.Context, a models.Attachment) payload.AttachmentResponse {
+	res := payload.MapAttachmentResponse(a)
+	res.UrlExpires = time.Now().Add(attachmentUrlTTL).Truncate(time.Second)
+
+	prefix, _, _ := strings.Cut(c.FullPath(), "/users/")
+	url := func(variant string) string {
+		expires := res.UrlExpires.Unix()
+		signature := util.Sign(env.GetAttachmentSigningKey(), downloadMessage(a.ID, a.UserID, variant, expires))
+
+		return fmt.Sprintf("%s/attachments/%d/%s?user=%d&expires=%d&signature=%s", prefix, a.ID, variant, a.UserID, expires, signature)
+	}
+
+	res.Url = url("file")
+	if a.ThumbnailKey != "" {
+		res.ThumbnailUrl = url("thumbnail")
+	}
+
+	return res
+}
+
+// Returns a before delete hook of a vital resource that deletes the
+// attachments of a reading of vital, e.g. "blood-pressure", and their files.
+func deleteAttachments[PM any](vital string) func(*gorm.DB, PM) error {
+	return func(tx *gorm.DB, record PM) error {
+		var attachments []models.Attachment
+		err := tx.Where("reading_type = ? AND reading_id = ?", vital, recordID(record)).Find(&attachments).Error
+		if err != nil || len(attachments) == 0 {
+			return err
+		}
+
+		if err := tx.Delete(&attachments).Error; err != nil {
+			return err
+		}
+
+		deleteAttachmentFiles(attachments)
+		return nil
+	}
+}
+
+// Deletes the files of deleted attachments.
+func deleteAttachmentFiles(attachments []models.Attachment) {
+	store := blobstore.Current()
+	if store == nil {
+		return
+	}
+
+	for _, a := range attachments {
+		deleteBlobs(store, a.BlobKey, a.ThumbnailKey)
+	}
+}
+
+// Returns the message signed in the download URL of an attachment.
+func downloadMessage(id uint, userId uint, variant string, expires int64) string {
+	return fmt.Sprintf("attachment:%d:%d:%s:%d", id, userId, variant, expires)
+}
+
+// Deletes blobs, logging failures since the record they belong to is
+// already gone.
+func deleteBlobs(store blobstore.Store, keys ...string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		if err := store.Delete(key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
 	"github.com/zenkimoto/vitals-server-api/internal/env"
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 	"github.com/zenkimoto/vitals-server-api/internal/payload"
@@ -26,13 +27,14 @@ import (
 // @Produce json
 // @Param user body payload.AuthRequest true "User Credentials Request"
 // @Success 200 {object} payload.AuthResponse
-// @Failure 400 {object} payload.ErrorResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
 // @Router /auth [post]
 func Login(c *gin.Context) {
 	var request payload.AuthRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Print(err)
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
+		apierror.Abort(c, apierror.Validation(err))
 		return
 	}
 
@@ -40,22 +42,22 @@ func Login(c *gin.Context) {
 	var user models.User
 	if err := models.DB.Where("user_name = ?", request.UserName).First(&user).Error; err != nil {
 		log.Print(err)
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid username and/or password"})
+		apierror.Abort(c, apierror.Unauthorized("Invalid username and/or password"))
 		return
 	}
 
 	// Validate bcrypt password hash
 	if !util.VerifyPassword(request.Password, user.PasswordHash) {
 		log.Print("bcrypt hash does not match.")
-		c.JSON(http.StatusUnauthorized, payload.ErrorResponse{Error: "Invalid username and/or password"})
+		apierror.Abort(c, apierror.Unauthorized("Invalid username and/or password"))
 		return
 	}
 
 	jwt, err := issueJsonWebToken(user)
 
 	if err != nil {
-		log.Print(err)
-		c.JSON(http.StatusInternalServerError, payload.ErrorResponse{Error: "Internal Server Error"})
+		apierror.Abort(c, apierror.Internal(err))
+		return
 	}
 
 	c.JSON(http.StatusOK, payload.AuthResponse{Token: jwt, UserId: user.ID})
@@ -83,12 +85,12 @@ func issueJsonWebToken(user models.User) (string, error) {
 // @Produce json
 // @Param user body payload.TokenRequest true "Token"
 // @Success 200 {object} payload.ValidateTokenResponse
-// @Failure 400 {object} payload.ErrorResponse
+// @Failure 400 {object} payload.Problem
 // @Router /token/validate [post]
 func ValidateToken(c *gin.Context) {
 	var request payload.TokenRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
+		apierror.Abort(c, apierror.Validation(err))
 		return
 	}
 
@@ -97,7 +99,7 @@ func ValidateToken(c *gin.Context) {
 
 	if err != nil {
 		log.Print(err)
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid token"})
+		apierror.Abort(c, apierror.BadRequest("Invalid token"))
 		return
 	}
 
@@ -116,12 +118,12 @@ func ValidateToken(c *gin.Context) {
 // @Produce json
 // @Param user body payload.TokenRequest true "Token"
 // @Success 200 {object} payload.TokenResponse
-// @Failure 400 {object} payload.ErrorResponse
+// @Failure 400 {object} payload.Problem
 // @Router /token/refresh [post]
 func RefreshToken(c *gin.Context) {
 	var request payload.TokenRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
+		apierror.Abort(c, apierror.Validation(err))
 		return
 	}
 
@@ -130,7 +132,7 @@ func RefreshToken(c *gin.Context) {
 
 	if err != nil {
 		log.Print(err)
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid token"})
+		apierror.Abort(c, apierror.BadRequest("Invalid token"))
 		return
 	}
 
@@ -139,15 +141,15 @@ func RefreshToken(c *gin.Context) {
 
 	if err != nil {
 		log.Print(err)
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid token"})
+		apierror.Abort(c, apierror.BadRequest("Invalid token"))
 		return
 	}
 
 	jwt, err := issueJsonWebToken(user)
 
 	if err != nil {
-		log.Print(err)
-		c.JSON(http.StatusInternalServerError, payload.ErrorResponse{Error: "Internal Server Error"})
+		apierror.Abort(c, apierror.Internal(err))
+		return
 	}
 
 	c.JSON(http.StatusOK, payload.AuthResponse{Token: jwt})
@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+)
+
+var bloodGlucoseResource = NewVitalResource("Blood Glucose", payload.ApplyBloodGlucoseRequest, payload.MapBloodGlucoseResponse, validation.BloodGlucose).
+	WithBeforeDelete(deleteAttachments[*models.BloodGlucose]("glucose")).
+	WithPresenter(presentBloodGlucose).
+	WithFields(
+		NumberField("mgDl", "mg_dl"),
+		TextField("mealContext", "meal_context"),
+		TextField("method", "method"),
+	).
+	WithStats("mgDl").
+	WithStatsUnit(glucoseStatsUnit).
+	WithParams("unit")
+
+// Readings below and above these values in mg/dL are very low and very
+// high. The target range of the stats endpoint must lie between them.
+const (
+	glucoseVeryLow  = 54.0
+	glucoseVeryHigh = 250.0
+)
+
+// GET /users/:id/glucose
+// Get all blood glucose records for a user.
+//
+// Swagger Doc
+// @Summary Get all blood glucose records for a user.
+// @Schemes
+// @Description Get all blood glucose records for a user. Filter on mgDl in mg/dL whatever the display unit, e.g. mgDl_gt=180 for highs, and on mealContext and method, e.g. mealContext=fasting. mgDl also takes the _gte, _lt, _lte and _ne suffixes.
+// @Tags Blood Glucose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(mg/dL, mmol/L)
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.BloodGlucoseResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/glucose [get]
+// @Security Bearer
+func GetBloodGlucoseByUserId(c *gin.Context) {
+	bloodGlucoseResource.List(c)
+}
+
+// GET /users/:id/glucose/:recordId
+// Get a blood glucose record for user.
+//
+// Swagger Doc
+// @Summary Get a blood glucose record for user.
+// @Schemes
+// @Description Get a blood glucose record for user.
+// @Tags Blood Glucose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Blood Glucose ID"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(mg/dL, mmol/L)
+// @Success 200 {object} payload.BloodGlucoseResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/glucose/{recordId} [get]
+// @Security Bearer
+func GetBloodGlucoseById(c *gin.Context) {
+	bloodGlucoseResource.Get(c)
+}
+
+// POST /users/:id/glucose
+// Adds a new blood glucose record for user.
+//
+// Swagger Doc
+// @Summary Adds a new blood glucose record for user.
+// @Schemes
+// @Description Adds a new blood glucose record for user. Time is optional. If not provided, current time is used. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Blood Glucose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.BloodGlucoseRequest true "User Blood Glucose"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(mg/dL, mmol/L)
+// @Success 200 {object} payload.BloodGlucoseResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/glucose [post]
+// @Security Bearer
+func PostBloodGlucoseByUserId(c *gin.Context) {
+	bloodGlucoseResource.Create(c)
+}
+
+// PUT /users/:id/glucose/:recordId
+// Updates a blood glucose record for user.
+//
+// Swagger Doc
+// @Summary Updates a blood glucose record for user.
+// @Schemes
+// @Description Updates a blood glucose record for user. Time is optional. If not provided, the existing time is kept. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Blood Glucose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Blood Glucose ID"
+// @Param user body payload.BloodGlucoseRequest true "User Blood Glucose"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(mg/dL, mmol/L)
+// @Success 200 {object} payload.BloodGlucoseResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/glucose/{recordId} [put]
+// @Security Bearer
+func PutBloodGlucoseByUserId(c *gin.Context) {
+	bloodGlucoseResource.Update(c)
+}
+
+// DELETE /users/:id/glucose/:recordId
+// Deletes a blood glucose record for user.
+//
+// Swagger Doc
+// @Summary Deletes a blood glucose record for user.
+// @Schemes
+// @Description Deletes a blood glucose record for user.
+// @Tags Blood Glucose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Blood Glucose ID"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(mg/dL, mmol/L)
+// @Success 200 {object} payload.BloodGlucoseResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/glucose/{recordId} [delete]
+// @Security Bearer
+func DeleteBloodGlucoseByUserId(c *gin.Context) {
+	bloodGlucoseResource.Delete(c)
+}
+
+// GET /users/:id/glucose/stats
+// Get blood glucose statistics and time in range for a user.
+//
+// Swagger Doc
+// @Summary Get blood glucose statistics and time in range for a user.
+// @Schemes
+// @Description Summarizes the blood glucose readings between from and to (default: the last 14 days). The range percentages are the share of readings below 54 mg/dL (very low), below the target (low), within the target, above the target (high) and above 250 mg/dL (very high). The target defaults to 70-180 mg/dL and must lie between 54 and 250 mg/dL. With the interval parameter it returns the statistics of the readings per period instead, like the stats endpoints of the other vitals.
+// @Tags Blood Glucose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param from query string false "Start of the window, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "End of the window, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param unit query string false "Unit of the values and target limits. Defaults to mg/dL." Enums(mg/dL, mmol/L)
+// @Param low query number false "Lower target limit"
+// @Param high query number false "Upper target limit"
+// @Param interval query string false "Length of the periods of per-period statistics" Enums(day, week, month)
+// @Success 200 {object} payload.BloodGlucoseStatsResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/glucose/stats [get]
+// @Security Bearer
+func GetBloodGlucoseStatsByUserId(c *gin.Context) {
+	if c.Query("interval") != "" {
+		vitalStats(c, "glucose")
+		return
+	}
+
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseTimeRange(c, 14)
+	if !ok {
+		return
+	}
+
+	unit, err := glucoseUnit(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	if unit == "" {
+		unit = payload.GlucoseMgDl
+	}
+
+	low, err := glucoseLimit(c, "low", unit, 70)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	high, err := glucoseLimit(c, "high", unit, 180)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	if low <= glucoseVeryLow {
+		apierror.Abort(c, apierror.Invalid("low", "gt", fmt.Sprintf("must be greater than %g %s", payload.GlucoseFromMgDl(glucoseVeryLow, unit), unit)))
+		return
+	}
+
+	if high >= glucoseVeryHigh {
+		apierror.Abort(c, apierror.Invalid("high", "lt", fmt.Sprintf("must be less than %g %s", payload.GlucoseFromMgDl(glucoseVeryHigh, unit), unit)))
+		return
+	}
+
+	if low >= high {
+		apierror.Abort(c, apierror.Invalid("low", "ltfield", "must be less than high"))
+		return
+	}
+
+	var readings []models.BloodGlucose
+	err = models.DB.Where("user_id = ? AND time >= ? AND time < ?", u.ID, from.UTC(), to.UTC()).Find(&readings).Error
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Blood Glucose", err))
+		return
+	}
+
+	stats := payload.BloodGlucoseStatsResponse{
+		From:       from,
+		To:         to,
+		Unit:       unit,
+		Count:      len(readings),
+		TargetLow:  payload.GlucoseFromMgDl(low, unit),
+		TargetHigh: payload.GlucoseFromMgDl(high, unit),
+	}
+
+	if len(readings) > 0 {
+		sum, minMgDl, maxMgDl := 0.0, math.Inf(1), math.Inf(-1)
+		var veryLow, below, inRange, above, veryHigh int
+
+		for _, r := range readings {
+			sum += r.MgDl
+			minMgDl = math.Min(minMgDl, r.MgDl)
+			maxMgDl = math.Max(maxMgDl, r.MgDl)
+
+			switch {
+			case r.MgDl < glucoseVeryLow:
+				veryLow++
+			case r.MgDl < low:
+				below++
+			case r.MgDl <= high:
+				inRange++
+			case r.MgDl <= glucoseVeryHigh:
+				above++
+			default:
+				veryHigh++
+			}
+		}
+
+		percent := func(n int) float64 {
+			return math.Round(float64(n)/float64(len(readings))*1000) / 10
+		}
+
+		stats.Mean = payload.GlucoseFromMgDl(sum/float64(len(readings)), unit)
+		stats.Min = payload.GlucoseFromMgDl(minMgDl, unit)
+		stats.Max = payload.GlucoseFromMgDl(maxMgDl, unit)
+		stats.VeryLowPercent = percent(veryLow)
+		stats.LowPercent = percent(below)
+		stats.InRangePercent = percent(inRange)
+		stats.HighPercent = percent(above)
+		stats.VeryHighPercent = percent(veryHigh)
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// Converts blood glucose responses to the unit in the unit query parameter.
+func presentBloodGlucose(c *gin.Context) (func(*payload.BloodGlucoseResponse), error) {
+	unit, err := glucoseUnit(c)
+	if err != nil || unit == "" {
+		return nil, err
+	}
+
+	return func(r *payload.BloodGlucoseResponse) {
+		r.ConvertTo(unit)
+	}, nil
+}
+
+// Returns the unit of blood glucose statistics: the unit query parameter
+// or mg/dL.
+func glucoseStatsUnit(c *gin.Context) (statsUnit, error) {
+	unit, err := glucoseUnit(c)
+	if err != nil {
+		return statsUnit{}, err
+	}
+	if unit == "" {
+		unit = payload.GlucoseMgDl
+	}
+
+	return statsUnit{Name: unit, Scale: 1 / payload.GlucoseToMgDl(1, unit)}, nil
+}
+
+// Returns the unit query parameter, or an empty string if there is none.
+func glucoseUnit(c *gin.Context) (string, error) {
+	switch unit := c.Query("unit"); unit {
+	case "", payload.GlucoseMgDl, payload.GlucoseMmolL:
+		return unit, nil
+	default:
+		return "", apierror.Invalid("unit", "oneof", "must be one of: mg/dL mmol/L")
+	}
+}
+
+// Returns a glucose limit query parameter in mg/dL, or def if there is none.
+func glucoseLimit(c *gin.Context, name string, unit string, def float64) (float64, error) {
+	s := c.Query(name)
+	if s == "" {
+		return def, nil
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value <= 0 {
+		return 0, apierror.Invalid(name, "gt", "must be a number greater than 0")
+	}
+
+	return payload.GlucoseToMgDl(value, unit), nil
+}
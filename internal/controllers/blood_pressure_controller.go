@@ -1,44 +1,72 @@
 package controllers
 
 import (
-	"net/http"
-	"strconv"
-	"time"
-
 	"github.com/gin-gonic/gin"
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
 )
 
+var bloodPressureResource = NewVitalResource("Blood Pressure", payload.ApplyBloodPressureRequest, payload.MapBloodPressureResponse, validation.BloodPressure).
+	WithBeforeDelete(deleteAttachments[*models.BloodPressure]("blood-pressure")).
+	WithFields(
+		NumberField("sys", "sys"),
+		NumberField("dia", "dia"),
+		NumberField("pulse", "pulse"),
+		TextField("arm", "arm"),
+		TextField("posture", "posture"),
+	).
+	WithStats("sys", "dia", "pulse")
+
 // GET /users/:id/blood-pressure
 // Get all blood pressure records for a user.
 //
 // Swagger Doc
 // @Summary Get all blood pressure records for a user.
 // @Schemes
-// @Description Get all blood pressure records for a user.
+// @Description Get all blood pressure records for a user. Filter on sys, dia and pulse, e.g. sys_gte=140 or dia_gte=90 for high readings, also with the _gt, _lt, _lte and _ne suffixes, and on arm and posture, e.g. arm=left&posture=seated.
 // @Tags Blood Pressure
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
 // @Success 200 {array} payload.BloodPressureResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id}/blood-pressure [get]
 // @Security Bearer
 func GetBloodPressureByUserId(c *gin.Context) {
-	userId := c.Param("id")
-	var bp []models.BloodPressure
-
-	var u models.User
-
-	if err := models.DB.Where("id = ?", c.Param("id")).First(&u).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "User not found"})
-		return
-	}
-
-	models.DB.Where("user_id = ?", userId).Order("time DESC").Find(&bp)
+	bloodPressureResource.List(c)
+}
 
-	c.JSON(http.StatusOK, Map(bp, payload.MapBloodPressureResponse))
+// GET /users/:id/blood-pressure/:recordId
+// Get a blood pressure record for user.
+//
+// Swagger Doc
+// @Summary Get a blood pressure record for user.
+// @Schemes
+// @Description Get a blood pressure record for user.
+// @Tags Blood Pressure
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Blood Pressure ID"
+// @Success 200 {object} payload.BloodPressureResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/blood-pressure/{recordId} [get]
+// @Security Bearer
+func GetBloodPressureById(c *gin.Context) {
+	bloodPressureResource.Get(c)
 }
 
 // POST /users/:id/blood-pressure
@@ -47,100 +75,46 @@ func GetBloodPressureByUserId(c *gin.Context) {
 // Swagger Doc
 // @Summary Adds a new blood pressure record for user.
 // @Schemes
-// @Description Adds a new blood pressure record for user. Time is optional. If not provided, current time is used.
+// @Description Adds a new blood pressure record for user. Time is optional. If not provided, current time is used. Pulse, arm, posture and the irregular heartbeat indicator are optional. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
 // @Tags Blood Pressure
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
 // @Param user body payload.BloodPressureRequest true "User Blood Pressure"
 // @Success 200 {object} payload.BloodPressureResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id}/blood-pressure [post]
 // @Security Bearer
 func PostBloodPressureByUserId(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	// Validate r
-	var r payload.BloodPressureRequest
-	if err := c.ShouldBindJSON(&r); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	createTime := time.Now()
-	if r.Time.IsZero() == false {
-		createTime = r.Time
-	}
-
-	// Create Blood Pressure
-	bp := models.BloodPressure{Sys: r.Sys, Dia: r.Dia, UserID: uint(id), Time: createTime}
-	models.DB.Create(&bp)
-
-	c.JSON(http.StatusOK, payload.MapBloodPressureResponse(bp))
+	bloodPressureResource.Create(c)
 }
 
-// PUT /users/:userId/blood-pressure/:id
+// PUT /users/:id/blood-pressure/:recordId
 // Updates a blood pressure record for user.
 //
 // Swagger Doc
 // @Summary Updates a blood pressure record for user.
 // @Schemes
-// @Description Updates a blood pressure record for user. Time is optional.
+// @Description Updates a blood pressure record for user. Time is optional. If not provided, the existing time is kept. Pulse, arm, posture and the irregular heartbeat indicator are optional. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
 // @Tags Blood Pressure
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Param id path int true "Blood Pressure ID"
+// @Param id path int true "User ID"
+// @Param recordId path int true "Blood Pressure ID"
 // @Param user body payload.BloodPressureRequest true "User Blood Pressure"
 // @Success 200 {object} payload.BloodPressureResponse
-// @Failure 404 {object} payload.ErrorResponse
-// @Router /users/{userId}/blood-pressure/{id} [put]
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/blood-pressure/{recordId} [put]
 // @Security Bearer
 func PutBloodPressureByUserId(c *gin.Context) {
-	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid blood pressure id"})
-		return
-	}
-
-	// Validate r
-	var r payload.BloodPressureRequest
-	if err := c.ShouldBindJSON(&r); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	// Find Blood Pressure
-	var bp models.BloodPressure
-	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&bp).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "Blood Pressure not found"})
-		return
-	}
-
-	// Update Blood Pressure
-	bp.Sys = r.Sys
-	bp.Dia = r.Dia
-	bp.Time = r.Time
-
-	models.DB.Save(&bp)
-
-	c.JSON(http.StatusOK, payload.MapBloodPressureResponse(bp))
+	bloodPressureResource.Update(c)
 }
 
-// DELETE /users/:userId/blood-pressure/:id
+// DELETE /users/:id/blood-pressure/:recordId
 // Deletes a blood pressure record for user.
 //
 // Swagger Doc
@@ -150,36 +124,46 @@ func PutBloodPressureByUserId(c *gin.Context) {
 // @Tags Blood Pressure
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Param id path int true "Blood Pressure ID"
+// @Param id path int true "User ID"
+// @Param recordId path int true "Blood Pressure ID"
 // @Success 200 {object} payload.BloodPressureResponse
-// @Failure 404 {object} payload.ErrorResponse
-// @Router /users/{userId}/blood-pressure/{id} [delete]
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/blood-pressure/{recordId} [delete]
 // @Security Bearer
 func DeleteBloodPressureByUserId(c *gin.Context) {
-	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid blood pressure id"})
-		return
-	}
-
-	// Find Blood Pressure
-	var bp models.BloodPressure
-	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&bp).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "Blood Pressure not found"})
-		return
-	}
-
-	// Delete Blood Pressure
-	models.DB.Delete(&bp)
+	bloodPressureResource.Delete(c)
+}
 
-	c.JSON(http.StatusOK, payload.MapBloodPressureResponse(bp))
+// GET /users/:id/blood-pressure/trend
+// Get the blood pressure trend of a user.
+//
+// Swagger Doc
+// @Summary Get the blood pressure trend of a user.
+// @Schemes
+// @Description Smooths the daily mean systolic and diastolic pressure between from and to (default: the last 90 days) with a simple moving average over window days and an exponential moving average, and fits a linear regression line whose slope is the change in mmHg per week. With a target systolic pressure it projects the date the exponential moving average reaches the target at that slope. Days are in the time zone of the user.
+// @Tags Blood Pressure
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param from query string false "Start of the window, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "End of the window, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param window query int false "Days of the simple moving average" default(7) minimum(1) maximum(90)
+// @Param alpha query number false "Smoothing factor of the exponential moving average" default(0.1)
+// @Param target query number false "Target systolic pressure"
+// @Success 200 {object} payload.TrendResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/blood-pressure/trend [get]
+// @Security Bearer
+func GetBloodPressureTrendByUserId(c *gin.Context) {
+	vitalTrend(c, trendSpec{
+		Name:        "Blood Pressure",
+		Model:       &models.BloodPressure{},
+		Unit:        "mmHg",
+		Fields:      []QueryField{NumberField("systolic", "sys"), NumberField("diastolic", "dia")},
+		Scale:       1,
+		TargetField: "systolic",
+	})
 }
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+)
+
+var bodyCompositionResource = NewVitalResource("Body Composition", payload.ApplyBodyCompositionRequest, payload.MapBodyCompositionResponse, validation.BodyComposition).
+	WithBeforeDelete(deleteAttachments[*models.BodyComposition]("body-composition")).
+	WithPresenter(presentBodyComposition).
+	WithFields(
+		NumberField("weightKg", "weight_kg"),
+		NumberField("bodyFatPercent", "body_fat_percent"),
+		NumberField("muscleMassKg", "muscle_mass_kg"),
+		NumberField("waterPercent", "water_percent"),
+		NumberField("boneMassKg", "bone_mass_kg"),
+		NumberField("visceralFat", "visceral_fat"),
+	).
+	WithStats("weightKg", "bodyFatPercent", "muscleMassKg")
+
+// GET /users/:id/body-composition
+// Get all body composition records for a user.
+//
+// Swagger Doc
+// @Summary Get all body composition records for a user.
+// @Schemes
+// @Description Get all body composition records for a user. Filter on weightKg, bodyFatPercent, muscleMassKg, waterPercent, boneMassKg and visceralFat with the _gt, _gte, _lt, _lte and _ne suffixes, e.g. bodyFatPercent_lt=25.
+// @Tags Body Composition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.BodyCompositionResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/body-composition [get]
+// @Security Bearer
+func GetBodyCompositionByUserId(c *gin.Context) {
+	bodyCompositionResource.List(c)
+}
+
+// GET /users/:id/body-composition/:recordId
+// Get a body composition record for user.
+//
+// Swagger Doc
+// @Summary Get a body composition record for user.
+// @Schemes
+// @Description Get a body composition record for user.
+// @Tags Body Composition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Composition ID"
+// @Success 200 {object} payload.BodyCompositionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/body-composition/{recordId} [get]
+// @Security Bearer
+func GetBodyCompositionById(c *gin.Context) {
+	bodyCompositionResource.Get(c)
+}
+
+// POST /users/:id/body-composition
+// Adds a new body composition record for user.
+//
+// Swagger Doc
+// @Summary Adds a new body composition record for user.
+// @Schemes
+// @Description Adds a new body composition record for user. Time is optional. If not provided, current time is used. Masses are in kilograms. The lean mass is derived from the body fat percentage and the BMI from the height on the user profile. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Body Composition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.BodyCompositionRequest true "User Body Composition"
+// @Success 200 {object} payload.BodyCompositionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/body-composition [post]
+// @Security Bearer
+func PostBodyCompositionByUserId(c *gin.Context) {
+	bodyCompositionResource.Create(c)
+}
+
+// PUT /users/:id/body-composition/:recordId
+// Updates a body composition record for user.
+//
+// Swagger Doc
+// @Summary Updates a body composition record for user.
+// @Schemes
+// @Description Updates a body composition record for user. Time is optional. If not provided, the existing time is kept. Masses are in kilograms. The lean mass is derived from the body fat percentage and the BMI from the height on the user profile. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Body Composition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Composition ID"
+// @Param user body payload.BodyCompositionRequest true "User Body Composition"
+// @Success 200 {object} payload.BodyCompositionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/body-composition/{recordId} [put]
+// @Security Bearer
+func PutBodyCompositionByUserId(c *gin.Context) {
+	bodyCompositionResource.Update(c)
+}
+
+// DELETE /users/:id/body-composition/:recordId
+// Deletes a body composition record for user.
+//
+// Swagger Doc
+// @Summary Deletes a body composition record for user.
+// @Schemes
+// @Description Deletes a body composition record for user.
+// @Tags Body Composition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Composition ID"
+// @Success 200 {object} payload.BodyCompositionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/body-composition/{recordId} [delete]
+// @Security Bearer
+func DeleteBodyCompositionByUserId(c *gin.Context) {
+	bodyCompositionResource.Delete(c)
+}
+
+// Adds the metrics derived from the user's height to body composition responses.
+func presentBodyComposition(c *gin.Context) (func(*payload.BodyCompositionResponse), error) {
+	height := userHeight(c)
+
+	return func(r *payload.BodyCompositionResponse) {
+		r.SetHeight(height)
+	}, nil
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+)
+
+var bodyMeasurementResource = NewVitalResource("Body Measurement", payload.ApplyBodyMeasurementRequest, payload.MapBodyMeasurementResponse, validation.BodyMeasurement).
+	WithBeforeDelete(deleteAttachments[*models.BodyMeasurement]("measurements")).
+	WithPresenter(presentBodyMeasurement).
+	WithFields(
+		NumberField("waistCm", "waist_cm"),
+		NumberField("hipCm", "hip_cm"),
+		NumberField("chestCm", "chest_cm"),
+		NumberField("neckCm", "neck_cm"),
+	).
+	WithStats("waistCm", "hipCm")
+
+// GET /users/:id/measurements
+// Get all body measurement records for a user.
+//
+// Swagger Doc
+// @Summary Get all body measurement records for a user.
+// @Schemes
+// @Description Get all body measurement records for a user. Filter on waistCm, hipCm, chestCm and neckCm with the _gt, _gte, _lt, _lte and _ne suffixes, e.g. waistCm_lte=90.
+// @Tags Body Measurement
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.BodyMeasurementResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/measurements [get]
+// @Security Bearer
+func GetBodyMeasurementByUserId(c *gin.Context) {
+	bodyMeasurementResource.List(c)
+}
+
+// GET /users/:id/measurements/:recordId
+// Get a body measurement record for user.
+//
+// Swagger Doc
+// @Summary Get a body measurement record for user.
+// @Schemes
+// @Description Get a body measurement record for user.
+// @Tags Body Measurement
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Measurement ID"
+// @Success 200 {object} payload.BodyMeasurementResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/measurements/{recordId} [get]
+// @Security Bearer
+func GetBodyMeasurementById(c *gin.Context) {
+	bodyMeasurementResource.Get(c)
+}
+
+// POST /users/:id/measurements
+// Adds a new body measurement record for user.
+//
+// Swagger Doc
+// @Summary Adds a new body measurement record for user.
+// @Schemes
+// @Description Adds a new body measurement record for user. Time is optional. If not provided, current time is used. Circumferences are in centimeters and at least one is required. The waist-to-hip ratio is derived from the waist and hip and the waist-to-height ratio from the height on the user profile. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Body Measurement
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.BodyMeasurementRequest true "User Body Measurement"
+// @Success 200 {object} payload.BodyMeasurementResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/measurements [post]
+// @Security Bearer
+func PostBodyMeasurementByUserId(c *gin.Context) {
+	bodyMeasurementResource.Create(c)
+}
+
+// PUT /users/:id/measurements/:recordId
+// Updates a body measurement record for user.
+//
+// Swagger Doc
+// @Summary Updates a body measurement record for user.
+// @Schemes
+// @Description Updates a body measurement record for user. Time is optional. If not provided, the existing time is kept. Circumferences are in centimeters and at least one is required. The waist-to-hip ratio is derived from the waist and hip and the waist-to-height ratio from the height on the user profile. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Body Measurement
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Measurement ID"
+// @Param user body payload.BodyMeasurementRequest true "User Body Measurement"
+// @Success 200 {object} payload.BodyMeasurementResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/measurements/{recordId} [put]
+// @Security Bearer
+func PutBodyMeasurementByUserId(c *gin.Context) {
+	bodyMeasurementResource.Update(c)
+}
+
+// DELETE /users/:id/measurements/:recordId
+// Deletes a body measurement record for user.
+//
+// Swagger Doc
+// @Summary Deletes a body measurement record for user.
+// @Schemes
+// @Description Deletes a body measurement record for user.
+// @Tags Body Measurement
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Measurement ID"
+// @Success 200 {object} payload.BodyMeasurementResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/measurements/{recordId} [delete]
+// @Security Bearer
+func DeleteBodyMeasurementByUserId(c *gin.Context) {
+	bodyMeasurementResource.Delete(c)
+}
+
+// Adds the metrics derived from the user's height to body measurement responses.
+func presentBodyMeasurement(c *gin.Context) (func(*payload.BodyMeasurementResponse), error) {
+	height := userHeight(c)
+
+	return func(r *payload.BodyMeasurementResponse) {
+		r.SetHeight(height)
+	}, nil
+}
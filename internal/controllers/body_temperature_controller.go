@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+)
+
+var bodyTemperatureResource = NewVitalResource("Body Temperature", payload.ApplyBodyTemperatureRequest, payload.MapBodyTemperatureResponse, validation.BodyTemperature).
+	WithBeforeDelete(deleteAttachments[*models.BodyTemperature]("temperature")).
+	WithPresenter(presentBodyTemperature).
+	WithFields(
+		NumberField("celsius", "celsius"),
+		TextField("site", "site"),
+	).
+	WithStats("celsius").
+	WithStatsUnit(temperatureStatsUnit).
+	WithParams("unit")
+
+// GET /users/:id/temperature
+// Get all body temperature records for a user.
+//
+// Swagger Doc
+// @Summary Get all body temperature records for a user.
+// @Schemes
+// @Description Get all body temperature records for a user. Filter on celsius, e.g. celsius_gte=38 for fevers, and on the measurement site, e.g. site=ear. Number fields also take the _gt, _lt, _lte and _ne suffixes.
+// @Tags Body Temperature
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(C, F)
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.BodyTemperatureResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/temperature [get]
+// @Security Bearer
+func GetBodyTemperatureByUserId(c *gin.Context) {
+	bodyTemperatureResource.List(c)
+}
+
+// GET /users/:id/temperature/:recordId
+// Get a body temperature record for user.
+//
+// Swagger Doc
+// @Summary Get a body temperature record for user.
+// @Schemes
+// @Description Get a body temperature record for user.
+// @Tags Body Temperature
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Temperature ID"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(C, F)
+// @Success 200 {object} payload.BodyTemperatureResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/temperature/{recordId} [get]
+// @Security Bearer
+func GetBodyTemperatureById(c *gin.Context) {
+	bodyTemperatureResource.Get(c)
+}
+
+// POST /users/:id/temperature
+// Adds a new body temperature record for user.
+//
+// Swagger Doc
+// @Summary Adds a new body temperature record for user.
+// @Schemes
+// @Description Adds a new body temperature record for user. Time is optional. If not provided, current time is used. Values outside plausible limits are rejected; unusual values are saved and listed in warnings. Readings of 38 °C (100.4 °F) or more are flagged as a fever.
+// @Tags Body Temperature
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.BodyTemperatureRequest true "User Body Temperature"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(C, F)
+// @Success 200 {object} payload.BodyTemperatureResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/temperature [post]
+// @Security Bearer
+func PostBodyTemperatureByUserId(c *gin.Context) {
+	bodyTemperatureResource.Create(c)
+}
+
+// PUT /users/:id/temperature/:recordId
+// Updates a body temperature record for user.
+//
+// Swagger Doc
+// @Summary Updates a body temperature record for user.
+// @Schemes
+// @Description Updates a body temperature record for user. Time is optional. If not provided, the existing time is kept. Values outside plausible limits are rejected; unusual values are saved and listed in warnings. Readings of 38 °C (100.4 °F) or more are flagged as a fever.
+// @Tags Body Temperature
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Temperature ID"
+// @Param user body payload.BodyTemperatureRequest true "User Body Temperature"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(C, F)
+// @Success 200 {object} payload.BodyTemperatureResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/temperature/{recordId} [put]
+// @Security Bearer
+func PutBodyTemperatureByUserId(c *gin.Context) {
+	bodyTemperatureResource.Update(c)
+}
+
+// DELETE /users/:id/temperature/:recordId
+// Deletes a body temperature record for user.
+//
+// Swagger Doc
+// @Summary Deletes a body temperature record for user.
+// @Schemes
+// @Description Deletes a body temperature record for user.
+// @Tags Body Temperature
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Body Temperature ID"
+// @Param unit query string false "Unit to return values in. Defaults to the unit each reading was submitted in." Enums(C, F)
+// @Success 200 {object} payload.BodyTemperatureResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/temperature/{recordId} [delete]
+// @Security Bearer
+func DeleteBodyTemperatureByUserId(c *gin.Context) {
+	bodyTemperatureResource.Delete(c)
+}
+
+// Returns the unit of body temperature statistics: the unit query
+// parameter or Celsius.
+func temperatureStatsUnit(c *gin.Context) (statsUnit, error) {
+	switch unit := c.Query("unit"); unit {
+	case "", payload.TemperatureCelsius:
+		return statsUnit{Name: payload.TemperatureCelsius, Scale: 1}, nil
+	case payload.TemperatureFahrenheit:
+		return statsUnit{Name: unit, Scale: 9.0 / 5, Offset: 32}, nil
+	default:
+		return statsUnit{}, apierror.Invalid("unit", "oneof", "must be one of: C F")
+	}
+}
+
+// Converts body temperature responses to the unit in the unit query parameter.
+func presentBodyTemperature(c *gin.Context) (func(*payload.BodyTemperatureResponse), error) {
+	switch unit := c.Query("unit"); unit {
+	case "":
+		return nil, nil
+	case payload.TemperatureCelsius, payload.TemperatureFahrenheit:
+		return func(r *payload.BodyTemperatureResponse) {
+			r.ConvertTo(unit)
+		}, nil
+	default:
+		return nil, apierror.Invalid("unit", "oneof", "must be one of: C F")
+	}
+}
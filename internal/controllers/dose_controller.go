@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"gorm.io/gorm"
+)
+
+var doseResource = NewVitalResource("Dose", payload.ApplyDoseRequest, payload.MapDoseResponse, nil).
+	WithBeforeDelete(deleteAttachments[*models.Dose]("doses")).
+	WithBeforeSave(checkDoseMedication).
+	WithFields(
+		NumberField("medicationId", "medication_id"),
+		TextField("status", "status"),
+	)
+
+// GET /users/:id/doses
+// Get all dose records for a user.
+//
+// Swagger Doc
+// @Summary Get all dose records for a user.
+// @Schemes
+// @Description Get all dose records for a user. Filter on the medication and status of the doses, e.g. medicationId=3&status=skipped.
+// @Tags Dose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.DoseResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/doses [get]
+// @Security Bearer
+func GetDoseByUserId(c *gin.Context) {
+	doseResource.List(c)
+}
+
+// GET /users/:id/doses/:recordId
+// Get a dose record for user.
+//
+// Swagger Doc
+// @Summary Get a dose record for user.
+// @Schemes
+// @Description Get a dose record for user.
+// @Tags Dose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Dose ID"
+// @Success 200 {object} payload.DoseResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/doses/{recordId} [get]
+// @Security Bearer
+func GetDoseById(c *gin.Context) {
+	doseResource.Get(c)
+}
+
+// POST /users/:id/doses
+// Adds a new dose record for user.
+//
+// Swagger Doc
+// @Summary Adds a new dose record for user.
+// @Schemes
+// @Description Adds a new dose record for user. Time is when the dose was taken, or when it was due if it was skipped. It is optional. If not provided, current time is used. The medication must belong to the user.
+// @Tags Dose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.DoseRequest true "User Dose"
+// @Success 200 {object} payload.DoseResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/doses [post]
+// @Security Bearer
+func PostDoseByUserId(c *gin.Context) {
+	doseResource.Create(c)
+}
+
+// PUT /users/:id/doses/:recordId
+// Updates a dose record for user.
+//
+// Swagger Doc
+// @Summary Updates a dose record for user.
+// @Schemes
+// @Description Updates a dose record for user. Time is optional. If not provided, the existing time is kept. The medication must belong to the user.
+// @Tags Dose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Dose ID"
+// @Param user body payload.DoseRequest true "User Dose"
+// @Success 200 {object} payload.DoseResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/doses/{recordId} [put]
+// @Security Bearer
+func PutDoseByUserId(c *gin.Context) {
+	doseResource.Update(c)
+}
+
+// DELETE /users/:id/doses/:recordId
+// Deletes a dose record for user.
+//
+// Swagger Doc
+// @Summary Deletes a dose record for user.
+// @Schemes
+// @Description Deletes a dose record for user.
+// @Tags Dose
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Dose ID"
+// @Success 200 {object} payload.DoseResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/doses/{recordId} [delete]
+// @Security Bearer
+func DeleteDoseByUserId(c *gin.Context) {
+	doseResource.Delete(c)
+}
+
+// Rejects doses of medications that do not belong to the user.
+func checkDoseMedication(tx *gorm.DB, d *models.Dose) error {
+	var m models.Medication
+	err := tx.Where("id = ? AND user_id = ?", d.MedicationID, d.UserID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apierror.Invalid("medicationId", "exists", "must be a medication of the user")
+	}
+
+	return err
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+)
+
+var heartRateResource = NewVitalResource("Heart Rate", payload.ApplyHeartRateRequest, payload.MapHeartRateResponse, validation.HeartRate).
+	WithBeforeDelete(deleteAttachments[*models.HeartRate]("heart-rate")).
+	WithFields(
+		NumberField("bpm", "bpm"),
+		TextField("context", "context"),
+	).
+	WithStats("bpm")
+
+// GET /users/:id/heart-rate
+// Get all heart rate records for a user.
+//
+// Swagger Doc
+// @Summary Get all heart rate records for a user.
+// @Schemes
+// @Description Get all heart rate records for a user. Filter on bpm, e.g. bpm_gt=100, and on the context and source of the reading, e.g. context=resting. Number fields also take the _gte, _lt, _lte and _ne suffixes.
+// @Tags Heart Rate
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.HeartRateResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/heart-rate [get]
+// @Security Bearer
+func GetHeartRateByUserId(c *gin.Context) {
+	heartRateResource.List(c)
+}
+
+// GET /users/:id/heart-rate/:recordId
+// Get a heart rate record for user.
+//
+// Swagger Doc
+// @Summary Get a heart rate record for user.
+// @Schemes
+// @Description Get a heart rate record for user.
+// @Tags Heart Rate
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Heart Rate ID"
+// @Success 200 {object} payload.HeartRateResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/heart-rate/{recordId} [get]
+// @Security Bearer
+func GetHeartRateById(c *gin.Context) {
+	heartRateResource.Get(c)
+}
+
+// POST /users/:id/heart-rate
+// Adds a new heart rate record for user.
+//
+// Swagger Doc
+// @Summary Adds a new heart rate record for user.
+// @Schemes
+// @Description Adds a new heart rate record for user. Time is optional. If not provided, current time is used. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Heart Rate
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.HeartRateRequest true "User Heart Rate"
+// @Success 200 {object} payload.HeartRateResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/heart-rate [post]
+// @Security Bearer
+func PostHeartRateByUserId(c *gin.Context) {
+	heartRateResource.Create(c)
+}
+
+// PUT /users/:id/heart-rate/:recordId
+// Updates a heart rate record for user.
+//
+// Swagger Doc
+// @Summary Updates a heart rate record for user.
+// @Schemes
+// @Description Updates a heart rate record for user. Time is optional. If not provided, the existing time is kept. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Heart Rate
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Heart Rate ID"
+// @Param user body payload.HeartRateRequest true "User Heart Rate"
+// @Success 200 {object} payload.HeartRateResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/heart-rate/{recordId} [put]
+// @Security Bearer
+func PutHeartRateByUserId(c *gin.Context) {
+	heartRateResource.Update(c)
+}
+
+// DELETE /users/:id/heart-rate/:recordId
+// Deletes a heart rate record for user.
+//
+// Swagger Doc
+// @Summary Deletes a heart rate record for user.
+// @Schemes
+// @Description Deletes a heart rate record for user.
+// @Tags Heart Rate
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Heart Rate ID"
+// @Success 200 {object} payload.HeartRateResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/heart-rate/{recordId} [delete]
+// @Security Bearer
+func DeleteHeartRateByUserId(c *gin.Context) {
+	heartRateResource.Delete(c)
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+var journalResource = NewVitalResource("Journal Entry", payload.ApplyJournalRequest, payload.MapJournalResponse, validation.Journal).
+	WithPreload("Symptoms", "Links").
+	WithFilter(filterJournal).
+	WithBeforeSave(prepareJournalEntry).
+	WithBeforeDelete(deleteAttachments[*models.JournalEntry]("journal")).
+	WithFields(
+		NumberField("mood", "mood"),
+		NumberField("energy", "energy"),
+	).
+	WithStats("mood", "energy").
+	WithParams("symptom", "reading")
+
+// GET /users/:id/journal
+// Get the journal entries of a user.
+//
+// Swagger Doc
+// @Summary Get the journal entries of a user.
+// @Schemes
+// @Description Get the journal entries of a user, optionally filtered by symptom, date range or linked reading. mood and energy (1-5) can be compared too, e.g. mood_lte=2 for low days, also with the _gt, _gte, _lt and _ne suffixes.
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param symptom query []string false "Only entries with any of these symptoms" collectionFormat(multi)
+// @Param from query string false "Only entries at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only entries before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param reading query string false "Only entries linked to this reading, as type:id" example(blood-pressure:12)
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.JournalResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/journal [get]
+// @Security Bearer
+func GetJournalByUserId(c *gin.Context) {
+	journalResource.List(c)
+}
+
+// GET /users/:id/journal/:recordId
+// Get a journal entry for user.
+//
+// Swagger Doc
+// @Summary Get a journal entry for user.
+// @Schemes
+// @Description Get a journal entry for user.
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Journal Entry ID"
+// @Success 200 {object} payload.JournalResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/journal/{recordId} [get]
+// @Security Bearer
+func GetJournalById(c *gin.Context) {
+	journalResource.Get(c)
+}
+
+// POST /users/:id/journal
+// Adds a new journal entry for user.
+//
+// Swagger Doc
+// @Summary Adds a new journal entry for user.
+// @Schemes
+// @Description Adds a new journal entry for user. Time is optional. If not provided, current time is used. Symptoms must be part of the vocabulary returned by /symptoms. Links refer to readings of the user by vital path name and id, e.g. {"type": "blood-pressure", "id": 12}.
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.JournalRequest true "User Journal"
+// @Success 200 {object} payload.JournalResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/journal [post]
+// @Security Bearer
+func PostJournalByUserId(c *gin.Context) {
+	journalResource.Create(c)
+}
+
+// PUT /users/:id/journal/:recordId
+// Updates a journal entry for user.
+//
+// Swagger Doc
+// @Summary Updates a journal entry for user.
+// @Schemes
+// @Description Updates a journal entry for user. Time is optional. If not provided, the existing time is kept. Symptoms must be part of the vocabulary returned by /symptoms. Links refer to readings of the user by vital path name and id, e.g. {"type": "blood-pressure", "id": 12}.
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Journal Entry ID"
+// @Param user body payload.JournalRequest true "User Journal"
+// @Success 200 {object} payload.JournalResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/journal/{recordId} [put]
+// @Security Bearer
+func PutJournalByUserId(c *gin.Context) {
+	journalResource.Update(c)
+}
+
+// DELETE /users/:id/journal/:recordId
+// Deletes a journal entry for user.
+//
+// Swagger Doc
+// @Summary Deletes a journal entry for user.
+// @Schemes
+// @Description Deletes a journal entry for user.
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Journal Entry ID"
+// @Success 200 {object} payload.JournalResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/journal/{recordId} [delete]
+// @Security Bearer
+func DeleteJournalByUserId(c *gin.Context) {
+	journalResource.Delete(c)
+}
+
+// GET /symptoms
+// Get the symptoms that can be recorded in the journal.
+//
+// Swagger Doc
+// @Summary Get the symptoms that can be recorded in the journal.
+// @Schemes
+// @Description Get the symptoms that can be recorded in the journal. The vocabulary can be configured per deployment with the symptoms field of the validation rules file.
+// @Tags Journal
+// @Accept json
+// @Produce json
+// @Success 200 {array} string
+// @Failure 401 {object} payload.Problem
+// @Router /symptoms [get]
+// @Security Bearer
+func GetSymptoms(c *gin.Context) {
+	c.JSON(http.StatusOK, validation.Current().Symptoms)
+}
+
+// Filters journal entries by the symptom and reading query parameters.
+func filterJournal(c *gin.Context, db *gorm.DB) (*gorm.DB, error) {
+	if symptoms := c.QueryArray("symptom"); len(symptoms) > 0 {
+		db = db.Where("id IN (?)", models.DB.Model(&models.JournalSymptom{}).Select("journal_entry_id").Where("name IN ?", symptoms))
+	}
+
+	if s := c.Query("reading"); s != "" {
+		readingType, rawId, _ := strings.Cut(s, ":")
+		id, err := strconv.ParseUint(rawId, 10, 32)
+		if err != nil {
+			return nil, apierror.Invalid("reading", "format", "must be a vital path name and a reading id, e.g. blood-pressure:12")
+		}
+		db = db.Where("id IN (?)", models.DB.Model(&models.JournalLink{}).Select("journal_entry_id").Where("reading_type = ? AND reading_id = ?", readingType, id))
+	}
+
+	return db, nil
+}
+
+// Checks that the linked readings belong to the user and removes the
+// symptoms and links of an existing entry, which are replaced by those of
+// the request.
+func prepareJournalEntry(tx *gorm.DB, j *models.JournalEntry) error {
+	for i, l := range j.Links {
+		newModel, ok := readingModels[l.ReadingType]
+		if !ok {
+			types := make([]string, 0, len(readingModels))
+			for t := range readingModels {
+				types = append(types, t)
+			}
+			sort.Strings(types)
+
+			return apierror.Invalid(fmt.Sprintf("links[%d].type", i), "oneof", "must be one of: "+strings.Join(types, " "))
+		}
+
+		var count int64
+		if err := tx.Model(newModel()).Where("id = ? AND user_id = ?", l.ReadingID, j.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return apierror.Invalid(fmt.Sprintf("links[%d].id", i), "exists", "must be a reading of the user")
+		}
+	}
+
+	if j.ID == 0 {
+		return nil
+	}
+
+	if err := tx.Where("journal_entry_id = ?", j.ID).Delete(&models.JournalSymptom{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("journal_entry_id = ?", j.ID).Delete(&models.JournalLink{}).Error
+}
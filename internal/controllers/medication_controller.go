@@ -0,0 +1,452 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+// GET /users/:id/medications
+// Get all medications of a user.
+//
+// Swagger Doc
+// @Summary Get all medications of a user.
+// @Schemes
+// @Description Get all medications of a user, ordered by name.
+// @Tags Medication
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {array} payload.MedicationResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/medications [get]
+// @Security Bearer
+func GetMedicationsByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	var medications []models.Medication
+	if err := models.DB.Where("user_id = ?", u.ID).Order("name").Find(&medications).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Medication", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, Map(medications, payload.MapMedicationResponse))
+}
+
+// GET /users/:id/medications/:recordId
+// Get a medication of a user.
+//
+// Swagger Doc
+// @Summary Get a medication of a user.
+// @Schemes
+// @Description Get a medication of a user.
+// @Tags Medication
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Medication ID"
+// @Success 200 {object} payload.MedicationResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/medications/{recordId} [get]
+// @Security Bearer
+func GetMedicationById(c *gin.Context) {
+	m, ok := findMedication(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, payload.MapMedicationResponse(m))
+}
+
+// POST /users/:id/medications
+// Adds a medication for a user.
+//
+// Swagger Doc
+// @Summary Adds a medication for a user.
+// @Schemes
+// @Description Adds a medication for a user. The schedule lists the daily dose times in HH:MM; leave it empty for medications taken as needed. The start date defaults to today and an empty end date means the medication is ongoing.
+// @Tags Medication
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param medication body payload.MedicationRequest true "Medication"
+// @Success 200 {object} payload.MedicationResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/medications [post]
+// @Security Bearer
+func PostMedicationByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	r, ok := bindMedication(c)
+	if !ok {
+		return
+	}
+
+	if r.StartDate == "" {
+		r.StartDate = time.Now().In(userLocation(c)).Format(time.DateOnly)
+	}
+
+	m := models.Medication{UserID: u.ID}
+	payload.ApplyMedicationRequest(&m, r)
+
+	if err := models.DB.Create(&m).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Medication", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, payload.MapMedicationResponse(m))
+}
+
+// PUT /users/:id/medications/:recordId
+// Updates a medication of a user.
+//
+// Swagger Doc
+// @Summary Updates a medication of a user.
+// @Schemes
+// @Description Updates a medication of a user. To stop a medication, set its end date.
+// @Tags Medication
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Medication ID"
+// @Param medication body payload.MedicationRequest true "Medication"
+// @Success 200 {object} payload.MedicationResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/medications/{recordId} [put]
+// @Security Bearer
+func PutMedicationByUserId(c *gin.Context) {
+	r, ok := bindMedication(c)
+	if !ok {
+		return
+	}
+
+	m, ok := findMedication(c)
+	if !ok {
+		return
+	}
+
+	if r.StartDate == "" {
+		r.StartDate = m.StartDate
+	}
+	payload.ApplyMedicationRequest(&m, r)
+
+	if err := models.DB.Save(&m).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Medication", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, payload.MapMedicationResponse(m))
+}
+
+// DELETE /users/:id/medications/:recordId
+// Deletes a medication of a user.
+//
+// Swagger Doc
+// @Summary Deletes a medication of a user.
+// @Schemes
+// @Description Deletes a medication of a user with its doses and their attachments. To keep the medication in adherence reports, set its end date instead.
+// @Tags Medication
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Medication ID"
+// @Success 200 {object} payload.MedicationResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/medications/{recordId} [delete]
+// @Security Bearer
+func DeleteMedicationByUserId(c *gin.Context) {
+	m, ok := findMedication(c)
+	if !ok {
+		return
+	}
+
+	var attachments []models.Attachment
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		doses := tx.Model(&models.Dose{}).Select("id").Where("medication_id = ?", m.ID)
+		if err := tx.Where("reading_type = ? AND reading_id IN (?)", "doses", doses).Find(&attachments).Error; err != nil {
+			return err
+		}
+
+		if len(attachments) > 0 {
+			if err := tx.Delete(&attachments).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("medication_id = ?", m.ID).Delete(&models.Dose{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&m).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Medication", err))
+		return
+	}
+
+	deleteAttachmentFiles(attachments)
+
+	c.JSON(http.StatusOK, payload.MapMedicationResponse(m))
+}
+
+// GET /users/:id/medications/adherence
+// Get the medication adherence of a user.
+//
+// Swagger Doc
+// @Summary Get the medication adherence of a user.
+// @Schemes
+// @Description Compares the scheduled doses between from and to (default: the last 30 days) with the logged doses. Late doses count as taken and scheduled doses without a log entry count as missed. Medications taken as needed are not included. The blood pressure section averages the readings on days where every scheduled dose was taken and on days where a dose was skipped or missed. Days are in the time zone of from. The range may be at most 400 days.
+// @Tags Medication
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param from query string false "Start of the window, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "End of the window, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Success 200 {object} payload.AdherenceResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/medications/adherence [get]
+// @Security Bearer
+func GetMedicationAdherenceByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDayRange(c, 30)
+	if !ok {
+		return
+	}
+
+	first := from.Format(time.DateOnly)
+	last := to.Add(-time.Nanosecond).Format(time.DateOnly)
+
+	var medications []models.Medication
+	err := models.DB.Where("user_id = ? AND schedule <> '' AND start_date <= ? AND (end_date IS NULL OR end_date >= ?)", u.ID, last, first).
+		Order("name").
+		Find(&medications).Error
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Medication", err))
+		return
+	}
+
+	var doses []models.Dose
+	if err := models.DB.Where("user_id = ? AND time >= ? AND time < ?", u.ID, from.UTC(), to.UTC()).Find(&doses).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Dose", err))
+		return
+	}
+
+	var readings []models.BloodPressure
+	if err := models.DB.Where("user_id = ? AND time >= ? AND time < ?", u.ID, from.UTC(), to.UTC()).Find(&readings).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Blood Pressure", err))
+		return
+	}
+
+	res := payload.AdherenceResponse{From: first, To: last, Medications: []payload.MedicationAdherenceResponse{}}
+	index := map[uint]int{}
+	scheduledByDay := map[string]int{}
+	takenByDay := map[string]int{}
+
+	for _, m := range medications {
+		a := payload.MedicationAdherenceResponse{MedicationId: m.ID, Name: m.Name}
+
+		for _, slot := range scheduledDoses(m, from, to) {
+			a.Scheduled++
+			scheduledByDay[slot.Format(time.DateOnly)]++
+		}
+
+		index[m.ID] = len(res.Medications)
+		res.Medications = append(res.Medications, a)
+	}
+
+	for _, d := range doses {
+		i, ok := index[d.MedicationID]
+		if !ok {
+			continue
+		}
+
+		a := &res.Medications[i].MedicationAdherence
+		switch d.Status {
+		case models.DoseTaken:
+			a.Taken++
+		case models.DoseLate:
+			a.Taken++
+			a.Late++
+		case models.DoseSkipped:
+			a.Skipped++
+			continue
+		}
+
+		takenByDay[d.Time.In(from.Location()).Format(time.DateOnly)]++
+	}
+
+	for i := range res.Medications {
+		a := &res.Medications[i].MedicationAdherence
+		finishAdherence(a)
+
+		res.Scheduled += a.Scheduled
+		res.Taken += a.Taken
+		res.Late += a.Late
+		res.Skipped += a.Skipped
+		res.Missed += a.Missed
+	}
+	finishAdherence(&res.MedicationAdherence)
+
+	var adherent, nonAdherent bpSum
+	for day, scheduled := range scheduledByDay {
+		if takenByDay[day] >= scheduled {
+			adherent.days++
+		} else {
+			nonAdherent.days++
+		}
+	}
+
+	for _, r := range readings {
+		day := r.Time.In(from.Location()).Format(time.DateOnly)
+
+		scheduled, ok := scheduledByDay[day]
+		switch {
+		case !ok:
+			continue
+		case takenByDay[day] >= scheduled:
+			adherent.add(r)
+		default:
+			nonAdherent.add(r)
+		}
+	}
+
+	res.BloodPressure.AdherentDays = adherent.average()
+	res.BloodPressure.NonAdherentDays = nonAdherent.average()
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Binds and validates a medication request. On failure a problem details
+// response is written and false is returned.
+func bindMedication(c *gin.Context) (payload.MedicationRequest, bool) {
+	var r payload.MedicationRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		apierror.Abort(c, apierror.Validation(err))
+		return r, false
+	}
+
+	if err := validation.Medication(r).Err(); err != nil {
+		apierror.Abort(c, err)
+		return r, false
+	}
+
+	return r, true
+}
+
+// Finds the medication identified by the :id and :recordId path
+// parameters. On failure a problem details response is written and false
+// is returned.
+func findMedication(c *gin.Context) (models.Medication, bool) {
+	var m models.Medication
+
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid user id"))
+		return m, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("recordId"), 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid medication id"))
+		return m, false
+	}
+
+	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&m).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Medication", err))
+		return m, false
+	}
+
+	return m, true
+}
+
+// Returns the times doses of m were due between from and to, on the days
+// the medication was taken. Dose times are in the time zone of from.
+func scheduledDoses(m models.Medication, from time.Time, to time.Time) []time.Time {
+	var slots []time.Time
+
+	schedule := payload.MedicationSchedule(m)
+	y, mo, d := from.Date()
+
+	for day := time.Date(y, mo, d, 0, 0, 0, 0, from.Location()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		if date < m.StartDate || (m.EndDate != nil && date > *m.EndDate) {
+			continue
+		}
+
+		for _, s := range schedule {
+			t, err := time.Parse("15:04", s)
+			if err != nil {
+				continue
+			}
+
+			// Not day.Add, so that dose times stay on the wall clock on
+			// days with a daylight saving time transition.
+			slot := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+			if !slot.Before(from) && slot.Before(to) {
+				slots = append(slots, slot)
+			}
+		}
+	}
+
+	return slots
+}
+
+// Sets the missed doses and the adherence percentage from the counts.
+func finishAdherence(a *payload.MedicationAdherence) {
+	a.Missed = max(a.Scheduled-a.Taken-a.Skipped, 0)
+
+	if a.Scheduled > 0 {
+		a.AdherencePercent = math.Round(float64(min(a.Taken, a.Scheduled))/float64(a.Scheduled)*1000) / 10
+	}
+}
+
+// Sums blood pressure readings for payload.BloodPressureAverage.
+type bpSum struct {
+	days, readings int
+	sys, dia       float64
+}
+
+func (s *bpSum) add(r models.BloodPressure) {
+	s.readings++
+	s.sys += float64(r.Sys)
+	s.dia += float64(r.Dia)
+}
+
+func (s bpSum) average() payload.BloodPressureAverage {
+	avg := payload.BloodPressureAverage{Days: s.days, Readings: s.readings}
+
+	if s.readings > 0 {
+		sys := math.Round(s.sys / float64(s.readings))
+		dia := math.Round(s.dia / float64(s.readings))
+		avg.Systolic, avg.Diastolic = &sys, &dia
+	}
+
+	return avg
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+type metricValueResource = VitalResource[models.MetricValue, *models.MetricValue, payload.MetricValueRequest, payload.MetricValueResponse]
+
+// GET /users/:id/metrics/:metricKey
+// Get all values of a custom metric for a user.
+//
+// Swagger Doc
+// @Summary Get all values of a custom metric for a user.
+// @Schemes
+// @Description Get all values of a custom metric for a user. The JSON type of a value depends on the kind of the metric. Filter on the value with query parameters, e.g. value_gte=10000 for a steps metric or value=good for an enum metric.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricKey path string true "Metric Key"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.MetricValueResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/metrics/{metricKey} [get]
+// @Security Bearer
+func GetMetricValuesByUserId(c *gin.Context) {
+	if r, ok := metricValues(c); ok {
+		r.List(c)
+	}
+}
+
+// GET /users/:id/metrics/:metricKey/:recordId
+// Get a value of a custom metric for user.
+//
+// Swagger Doc
+// @Summary Get a value of a custom metric for user.
+// @Schemes
+// @Description Get a value of a custom metric for user.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricKey path string true "Metric Key"
+// @Param recordId path int true "Metric Value ID"
+// @Success 200 {object} payload.MetricValueResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/metrics/{metricKey}/{recordId} [get]
+// @Security Bearer
+func GetMetricValueById(c *gin.Context) {
+	if r, ok := metricValues(c); ok {
+		r.Get(c)
+	}
+}
+
+// POST /users/:id/metrics/:metricKey
+// Records a value of a custom metric for user.
+//
+// Swagger Doc
+// @Summary Records a value of a custom metric for user.
+// @Schemes
+// @Description Records a value of a custom metric for user. The value is a number for number and integer metrics, true or false for boolean metrics, one of the options for enum metrics and an array of two numbers for pair metrics. Values outside the limits of the metric are rejected. Time is optional. If not provided, current time is used.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricKey path string true "Metric Key"
+// @Param value body payload.MetricValueRequest true "Metric Value"
+// @Success 200 {object} payload.MetricValueResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/metrics/{metricKey} [post]
+// @Security Bearer
+func PostMetricValueByUserId(c *gin.Context) {
+	if r, ok := metricValues(c); ok {
+		r.Create(c)
+	}
+}
+
+// PUT /users/:id/metrics/:metricKey/:recordId
+// Updates a value of a custom metric for user.
+//
+// Swagger Doc
+// @Summary Updates a value of a custom metric for user.
+// @Schemes
+// @Description Updates a value of a custom metric for user. Time is optional. If not provided, the existing time is kept.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricKey path string true "Metric Key"
+// @Param recordId path int true "Metric Value ID"
+// @Param value body payload.MetricValueRequest true "Metric Value"
+// @Success 200 {object} payload.MetricValueResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/metrics/{metricKey}/{recordId} [put]
+// @Security Bearer
+func PutMetricValueByUserId(c *gin.Context) {
+	if r, ok := metricValues(c); ok {
+		r.Update(c)
+	}
+}
+
+// DELETE /users/:id/metrics/:metricKey/:recordId
+// Deletes a value of a custom metric for user.
+//
+// Swagger Doc
+// @Summary Deletes a value of a custom metric for user.
+// @Schemes
+// @Description Deletes a value of a custom metric for user.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricKey path string true "Metric Key"
+// @Param recordId path int true "Metric Value ID"
+// @Success 200 {object} payload.MetricValueResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/metrics/{metricKey}/{recordId} [delete]
+// @Security Bearer
+func DeleteMetricValueByUserId(c *gin.Context) {
+	if r, ok := metricValues(c); ok {
+		r.Delete(c)
+	}
+}
+
+// Returns the resource for the values of the custom metric identified by
+// the :id and :metricKey path parameters. Unlike the built-in vitals the
+// resource is declared per request because it depends on the definition.
+// On failure a problem details response is written and false is returned.
+func metricValues(c *gin.Context) (*metricValueResource, bool) {
+	u, ok := findUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	d, ok := findMetricDefinition(c, u.ID)
+	if !ok {
+		return nil, false
+	}
+
+	value := NumberField("value", "number")
+	if d.Kind == models.MetricEnum {
+		value = TextField("value", "text")
+	}
+
+	return NewVitalResource(d.Name,
+		func(v *models.MetricValue, r payload.MetricValueRequest) {
+			payload.ApplyMetricValueRequest(d, v, r)
+		},
+		func(v models.MetricValue) payload.MetricValueResponse {
+			return payload.MapMetricValueResponse(d, v)
+		},
+		func(r payload.MetricValueRequest) validation.Result {
+			return validation.MetricValue(d, r)
+		},
+	).WithScope(func(db *gorm.DB) *gorm.DB {
+		return db.Where("metric_definition_id = ?", d.ID)
+	}).WithFields(value).
+		WithBeforeDelete(deleteAttachments[*models.MetricValue]("metrics")), true
+}
+
+// metricTimeline adds the values of every custom metric of the user
+// identified by the :id path parameter to the timeline. Values are
+// returned as by the endpoint of their metric, which is named in their
+// metricKey.
+type metricTimeline struct{}
+
+func (metricTimeline) timeline(c *gin.Context, vital string, scope func(*gorm.DB) *gorm.DB, order string, limit int) ([]timelineItem, error) {
+	var definitions []models.MetricDefinition
+	if err := models.DB.Where("user_id = ? OR user_id IS NULL", c.Param("id")).Find(&definitions).Error; err != nil {
+		return nil, apierror.Database("Metric Type", err)
+	}
+
+	byId := map[uint]models.MetricDefinition{}
+	for _, d := range definitions {
+		byId[d.ID] = d
+	}
+
+	r := &metricValueResource{
+		Name: "Metric Value",
+		Response: func(v models.MetricValue) payload.MetricValueResponse {
+			return payload.MapMetricValueResponse(byId[v.MetricDefinitionID], v)
+		},
+	}
+
+	return r.timeline(c, vital, scope, order, limit)
+}
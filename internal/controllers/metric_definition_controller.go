@@ -0,0 +1,447 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+// GET /users/:id/metric-types
+// Get the custom metric types available to a user.
+//
+// Swagger Doc
+// @Summary Get the custom metric types available to a user.
+// @Schemes
+// @Description Get the metric types defined by the user and the global metric types, ordered by key. A metric type of the user hides a global metric type with the same key.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {array} payload.MetricDefinitionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/metric-types [get]
+// @Security Bearer
+func GetMetricTypesByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	var definitions []models.MetricDefinition
+	if err := models.DB.Where("user_id = ? OR user_id IS NULL", u.ID).Order("key, user_id").Find(&definitions).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Metric Type", err))
+		return
+	}
+
+	own := map[string]bool{}
+	for _, d := range definitions {
+		if d.UserID != nil {
+			own[d.Key] = true
+		}
+	}
+
+	res := []payload.MetricDefinitionResponse{}
+	for _, d := range definitions {
+		if d.UserID != nil || !own[d.Key] {
+			res = append(res, payload.MapMetricDefinitionResponse(d))
+		}
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// GET /users/:id/metric-types/:metricKey
+// Get a custom metric type available to a user.
+//
+// Swagger Doc
+// @Summary Get a custom metric type available to a user.
+// @Schemes
+// @Description Get the metric type of the user with the key, or the global metric type if the user has none.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricKey path string true "Metric Key"
+// @Success 200 {object} payload.MetricDefinitionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/metric-types/{metricKey} [get]
+// @Security Bearer
+func GetMetricTypeByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	d, ok := findMetricDefinition(c, u.ID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, payload.MapMetricDefinitionResponse(d))
+}
+
+// POST /users/:id/metric-types
+// Adds a custom metric type for a user.
+//
+// Swagger Doc
+// @Summary Adds a custom metric type for a user.
+// @Schemes
+// @Description Adds a metric type for a user. Values are recorded under /users/{id}/metrics/{key}. Min and max limit number, integer and pair values, options list the values of an enum and labels name the two values of a pair. A metric type of the user hides a global metric type with the same key.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricType body payload.MetricDefinitionRequest true "Metric Type"
+// @Success 200 {object} payload.MetricDefinitionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Failure 409 {object} payload.Problem
+// @Router /users/{id}/metric-types [post]
+// @Security Bearer
+func PostMetricTypeByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	createMetricDefinition(c, &u.ID)
+}
+
+// PUT /users/:id/metric-types/:metricKey
+// Updates a custom metric type of a user.
+//
+// Swagger Doc
+// @Summary Updates a custom metric type of a user.
+// @Schemes
+// @Description Updates a metric type of a user. The key and kind can not be changed. Global metric types can only be changed by an admin.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricKey path string true "Metric Key"
+// @Param metricType body payload.MetricDefinitionRequest true "Metric Type"
+// @Success 200 {object} payload.MetricDefinitionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Failure 409 {object} payload.Problem
+// @Router /users/{id}/metric-types/{metricKey} [put]
+// @Security Bearer
+func PutMetricTypeByUserId(c *gin.Context) {
+	d, ok := findOwnMetricDefinition(c)
+	if !ok {
+		return
+	}
+
+	updateMetricDefinition(c, d)
+}
+
+// DELETE /users/:id/metric-types/:metricKey
+// Deletes a custom metric type of a user.
+//
+// Swagger Doc
+// @Summary Deletes a custom metric type of a user.
+// @Schemes
+// @Description Deletes a metric type of a user and its recorded values. Global metric types can only be deleted by an admin.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param metricKey path string true "Metric Key"
+// @Success 200 {object} payload.MetricDefinitionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/metric-types/{metricKey} [delete]
+// @Security Bearer
+func DeleteMetricTypeByUserId(c *gin.Context) {
+	d, ok := findOwnMetricDefinition(c)
+	if !ok {
+		return
+	}
+
+	deleteMetricDefinition(c, d)
+}
+
+// GET /metric-types
+// Get the global custom metric types.
+//
+// Swagger Doc
+// @Summary Get the global custom metric types.
+// @Schemes
+// @Description Get the metric types available to every user, ordered by key.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Success 200 {array} payload.MetricDefinitionResponse
+// @Failure 401 {object} payload.Problem
+// @Router /metric-types [get]
+// @Security Bearer
+func GetMetricTypes(c *gin.Context) {
+	var definitions []models.MetricDefinition
+	if err := models.DB.Where("user_id IS NULL").Order("key").Find(&definitions).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Metric Type", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, Map(definitions, payload.MapMetricDefinitionResponse))
+}
+
+// POST /metric-types
+// Adds a global custom metric type.
+//
+// Swagger Doc
+// @Summary Adds a global custom metric type.
+// @Schemes
+// @Description Adds a metric type available to every user. Requires the admin role.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param metricType body payload.MetricDefinitionRequest true "Metric Type"
+// @Success 200 {object} payload.MetricDefinitionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 409 {object} payload.Problem
+// @Router /metric-types [post]
+// @Security Bearer
+func PostMetricType(c *gin.Context) {
+	createMetricDefinition(c, nil)
+}
+
+// PUT /metric-types/:metricKey
+// Updates a global custom metric type.
+//
+// Swagger Doc
+// @Summary Updates a global custom metric type.
+// @Schemes
+// @Description Updates a metric type available to every user. The key and kind can not be changed. Requires the admin role.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param metricKey path string true "Metric Key"
+// @Param metricType body payload.MetricDefinitionRequest true "Metric Type"
+// @Success 200 {object} payload.MetricDefinitionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Failure 409 {object} payload.Problem
+// @Router /metric-types/{metricKey} [put]
+// @Security Bearer
+func PutMetricType(c *gin.Context) {
+	d, ok := findGlobalMetricDefinition(c)
+	if !ok {
+		return
+	}
+
+	updateMetricDefinition(c, d)
+}
+
+// DELETE /metric-types/:metricKey
+// Deletes a global custom metric type.
+//
+// Swagger Doc
+// @Summary Deletes a global custom metric type.
+// @Schemes
+// @Description Deletes a metric type available to every user and the values recorded by every user. Requires the admin role.
+// @Tags Custom Metric
+// @Accept json
+// @Produce json
+// @Param metricKey path string true "Metric Key"
+// @Success 200 {object} payload.MetricDefinitionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /metric-types/{metricKey} [delete]
+// @Security Bearer
+func DeleteMetricType(c *gin.Context) {
+	d, ok := findGlobalMetricDefinition(c)
+	if !ok {
+		return
+	}
+
+	deleteMetricDefinition(c, d)
+}
+
+// Creates a metric definition of the user, or a global one if userId is
+// nil, from the request body.
+func createMetricDefinition(c *gin.Context, userId *uint) {
+	r, ok := bindMetricDefinition(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	err := models.DB.Model(&models.MetricDefinition{}).
+		Where("key = ?", r.Key).
+		Scopes(metricOwner(userId)).
+		Count(&count).Error
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Metric Type", err))
+		return
+	}
+
+	if count > 0 {
+		apierror.Abort(c, apierror.Conflict("Metric Type "+r.Key+" already exists"))
+		return
+	}
+
+	d := models.MetricDefinition{UserID: userId}
+	payload.ApplyMetricDefinitionRequest(&d, r)
+
+	if err := models.DB.Create(&d).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Metric Type", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, payload.MapMetricDefinitionResponse(d))
+}
+
+// Updates d from the request body. The key and kind of a definition can
+// not be changed because recorded values depend on them.
+func updateMetricDefinition(c *gin.Context, d models.MetricDefinition) {
+	r, ok := bindMetricDefinition(c)
+	if !ok {
+		return
+	}
+
+	if r.Key != d.Key {
+		apierror.Abort(c, apierror.Conflict("The key of a metric type can not be changed"))
+		return
+	}
+
+	if r.Kind != d.Kind {
+		apierror.Abort(c, apierror.Conflict("The kind of a metric type can not be changed"))
+		return
+	}
+
+	payload.ApplyMetricDefinitionRequest(&d, r)
+
+	if err := models.DB.Save(&d).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Metric Type", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, payload.MapMetricDefinitionResponse(d))
+}
+
+// Deletes d and its recorded values.
+func deleteMetricDefinition(c *gin.Context, d models.MetricDefinition) {
+	var attachments []models.Attachment
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		values := tx.Model(&models.MetricValue{}).Select("id").Where("metric_definition_id = ?", d.ID)
+		if err := tx.Where("reading_type = ? AND reading_id IN (?)", "metrics", values).Find(&attachments).Error; err != nil {
+			return err
+		}
+
+		if len(attachments) > 0 {
+			if err := tx.Delete(&attachments).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("metric_definition_id = ?", d.ID).Delete(&models.MetricValue{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&d).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Metric Type", err))
+		return
+	}
+
+	deleteAttachmentFiles(attachments)
+
+	c.JSON(http.StatusOK, payload.MapMetricDefinitionResponse(d))
+}
+
+// Binds and validates a metric definition request. On failure a problem
+// details response is written and false is returned.
+func bindMetricDefinition(c *gin.Context) (payload.MetricDefinitionRequest, bool) {
+	var r payload.MetricDefinitionRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		apierror.Abort(c, apierror.Validation(err))
+		return r, false
+	}
+
+	if err := validation.MetricDefinition(r).Err(); err != nil {
+		apierror.Abort(c, err)
+		return r, false
+	}
+
+	return r, true
+}
+
+// Finds the metric definition identified by the :metricKey path parameter
+// that applies to the user: the user's own definition if there is one and
+// the global definition otherwise. On failure a problem details response
+// is written and false is returned.
+func findMetricDefinition(c *gin.Context, userId uint) (models.MetricDefinition, bool) {
+	var d models.MetricDefinition
+
+	err := models.DB.Where("key = ? AND (user_id = ? OR user_id IS NULL)", c.Param("metricKey"), userId).
+		Order("user_id IS NULL").
+		First(&d).Error
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Metric Type", err))
+		return d, false
+	}
+
+	return d, true
+}
+
+// Finds the metric definition identified by the :metricKey path parameter
+// that the user identified by the :id path parameter defined. A global
+// definition can not be changed by the user. On failure a problem details
+// response is written and false is returned.
+func findOwnMetricDefinition(c *gin.Context) (models.MetricDefinition, bool) {
+	u, ok := findUser(c)
+	if !ok {
+		return models.MetricDefinition{}, false
+	}
+
+	d, ok := findMetricDefinition(c, u.ID)
+	if ok && d.UserID == nil {
+		apierror.Abort(c, apierror.Forbidden("Global metric types can only be changed by an admin"))
+		return d, false
+	}
+
+	return d, ok
+}
+
+// Finds the global metric definition identified by the :metricKey path
+// parameter. On failure a problem details response is written and false
+// is returned.
+func findGlobalMetricDefinition(c *gin.Context) (models.MetricDefinition, bool) {
+	var d models.MetricDefinition
+
+	if err := models.DB.Where("key = ? AND user_id IS NULL", c.Param("metricKey")).First(&d).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Metric Type", err))
+		return d, false
+	}
+
+	return d, true
+}
+
+// Restricts a query to the metric definitions of the user, or to the
+// global definitions if userId is nil.
+func metricOwner(userId *uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if userId == nil {
+			return db.Where("user_id IS NULL")
+		}
+
+		return db.Where("user_id = ?", *userId)
+	}
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+)
+
+var nutritionResource = NewVitalResource("Nutrition Entry", payload.ApplyNutritionRequest, payload.MapNutritionResponse, validation.Nutrition).
+	WithBeforeDelete(deleteAttachments[*models.NutritionEntry]("nutrition")).
+	WithFields(
+		TextField("name", "name"),
+		TextField("mealType", "meal_type"),
+		NumberField("calories", "calories"),
+		NumberField("proteinG", "protein_g"),
+		NumberField("carbsG", "carbs_g"),
+		NumberField("fatG", "fat_g"),
+		NumberField("fiberG", "fiber_g"),
+		NumberField("sugarG", "sugar_g"),
+		NumberField("sodiumMg", "sodium_mg"),
+	).
+	WithStats("calories", "proteinG", "carbsG", "fatG")
+
+// GET /users/:id/nutrition
+// Get all nutrition entries for a user.
+//
+// Swagger Doc
+// @Summary Get all nutrition entries for a user.
+// @Schemes
+// @Description Get all nutrition entries for a user. The nutrients calories, proteinG, carbsG, fatG, fiberG, sugarG and sodiumMg can be compared with the _gt, _gte, _lt, _lte and _ne suffixes, e.g. sodiumMg_gt=800, and name and mealType match values, e.g. mealType=breakfast.
+// @Tags Nutrition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.NutritionResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/nutrition [get]
+// @Security Bearer
+func GetNutritionByUserId(c *gin.Context) {
+	nutritionResource.List(c)
+}
+
+// GET /users/:id/nutrition/:recordId
+// Get a nutrition entry for user.
+//
+// Swagger Doc
+// @Summary Get a nutrition entry for user.
+// @Schemes
+// @Description Get a nutrition entry for user.
+// @Tags Nutrition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Nutrition Entry ID"
+// @Success 200 {object} payload.NutritionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/nutrition/{recordId} [get]
+// @Security Bearer
+func GetNutritionById(c *gin.Context) {
+	nutritionResource.Get(c)
+}
+
+// POST /users/:id/nutrition
+// Adds a new nutrition entry for user.
+//
+// Swagger Doc
+// @Summary Adds a new nutrition entry for user.
+// @Schemes
+// @Description Adds a new nutrition entry for user. Time is optional. If not provided, current time is used. Nutrients are in grams except sodium, which is in milligrams. The sugar of the entry is also listed under /sugar, where it can not be changed on its own. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Nutrition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.NutritionRequest true "User Nutrition"
+// @Success 200 {object} payload.NutritionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/nutrition [post]
+// @Security Bearer
+func PostNutritionByUserId(c *gin.Context) {
+	nutritionResource.Create(c)
+}
+
+// PUT /users/:id/nutrition/:recordId
+// Updates a nutrition entry for user.
+//
+// Swagger Doc
+// @Summary Updates a nutrition entry for user.
+// @Schemes
+// @Description Updates a nutrition entry for user. Time is optional. If not provided, the existing time is kept. Nutrients are in grams except sodium, which is in milligrams. The sugar of the entry is also listed under /sugar, where it can not be changed on its own. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Nutrition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Nutrition Entry ID"
+// @Param user body payload.NutritionRequest true "User Nutrition"
+// @Success 200 {object} payload.NutritionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/nutrition/{recordId} [put]
+// @Security Bearer
+func PutNutritionByUserId(c *gin.Context) {
+	nutritionResource.Update(c)
+}
+
+// DELETE /users/:id/nutrition/:recordId
+// Deletes a nutrition entry for user.
+//
+// Swagger Doc
+// @Summary Deletes a nutrition entry for user.
+// @Schemes
+// @Description Deletes a nutrition entry for user.
+// @Tags Nutrition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Nutrition Entry ID"
+// @Success 200 {object} payload.NutritionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/nutrition/{recordId} [delete]
+// @Security Bearer
+func DeleteNutritionByUserId(c *gin.Context) {
+	nutritionResource.Delete(c)
+}
+
+// GET /users/:id/nutrition/daily
+// Get daily nutrition totals for a user.
+//
+// Swagger Doc
+// @Summary Get daily nutrition totals for a user.
+// @Schemes
+// @Description Totals the nutrition entries of each day between from and to (default: the last 7 days), oldest first. Days without entries are included. Days are in the time zone of from. The range may be at most 400 days.
+// @Tags Nutrition
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param from query string false "Start of the window, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "End of the window, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Success 200 {array} payload.NutritionDayResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/nutrition/daily [get]
+// @Security Bearer
+func GetNutritionDailyByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDayRange(c, 7)
+	if !ok {
+		return
+	}
+
+	var entries []models.NutritionEntry
+	if err := models.DB.Where("user_id = ? AND time >= ? AND time < ?", u.ID, from.UTC(), to.UTC()).Find(&entries).Error; err != nil {
+		apierror.Abort(c, apierror.Database("Nutrition Entry", err))
+		return
+	}
+
+	var days []payload.NutritionDayResponse
+	index := map[string]int{}
+
+	y, m, d := from.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, from.Location()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		index[date] = len(days)
+		days = append(days, payload.NutritionDayResponse{Date: date, MealCalories: map[string]float64{}})
+	}
+
+	value := func(f *float64) float64 {
+		if f == nil {
+			return 0
+		}
+		return *f
+	}
+
+	for _, e := range entries {
+		i, ok := index[e.Time.In(from.Location()).Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+
+		day := &days[i]
+
+		day.Entries++
+		day.Calories += value(e.Calories)
+		day.ProteinG += value(e.ProteinG)
+		day.CarbsG += value(e.CarbsG)
+		day.FatG += value(e.FatG)
+		day.FiberG += value(e.FiberG)
+		day.SugarG += value(e.SugarG)
+		day.SodiumMg += value(e.SodiumMg)
+
+		if e.MealType != "" {
+			day.MealCalories[e.MealType] += value(e.Calories)
+		}
+	}
+
+	for i := range days {
+		day := &days[i]
+		for _, f := range []*float64{&day.Calories, &day.ProteinG, &day.CarbsG, &day.FatG, &day.FiberG, &day.SugarG, &day.SodiumMg} {
+			*f = math.Round(*f*10) / 10
+		}
+	}
+
+	c.JSON(http.StatusOK, days)
+}
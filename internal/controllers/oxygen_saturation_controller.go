@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+)
+
+var oxygenSaturationResource = NewVitalResource("Oxygen Saturation", payload.ApplyOxygenSaturationRequest, payload.MapOxygenSaturationResponse, validation.OxygenSaturation).
+	WithBeforeDelete(deleteAttachments[*models.OxygenSaturation]("spo2")).
+	WithFields(
+		NumberField("percent", "percent"),
+		NumberField("pulse", "pulse"),
+	).
+	WithStats("percent", "pulse")
+
+// GET /users/:id/spo2
+// Get all oxygen saturation records for a user.
+//
+// Swagger Doc
+// @Summary Get all oxygen saturation records for a user.
+// @Schemes
+// @Description Get all oxygen saturation records for a user. Filter on percent and pulse, e.g. percent_lt=92 for low readings, also with the _gt, _gte, _lte and _ne suffixes.
+// @Tags Oxygen Saturation
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.OxygenSaturationResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/spo2 [get]
+// @Security Bearer
+func GetOxygenSaturationByUserId(c *gin.Context) {
+	oxygenSaturationResource.List(c)
+}
+
+// GET /users/:id/spo2/:recordId
+// Get a oxygen saturation record for user.
+//
+// Swagger Doc
+// @Summary Get a oxygen saturation record for user.
+// @Schemes
+// @Description Get a oxygen saturation record for user.
+// @Tags Oxygen Saturation
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Oxygen Saturation ID"
+// @Success 200 {object} payload.OxygenSaturationResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/spo2/{recordId} [get]
+// @Security Bearer
+func GetOxygenSaturationById(c *gin.Context) {
+	oxygenSaturationResource.Get(c)
+}
+
+// POST /users/:id/spo2
+// Adds a new oxygen saturation record for user.
+//
+// Swagger Doc
+// @Summary Adds a new oxygen saturation record for user.
+// @Schemes
+// @Description Adds a new oxygen saturation record for user. Time is optional. If not provided, current time is used. Values outside plausible limits are rejected; unusual values are saved and listed in warnings. Readings below 92% are flagged as low oxygen.
+// @Tags Oxygen Saturation
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.OxygenSaturationRequest true "User Oxygen Saturation"
+// @Success 200 {object} payload.OxygenSaturationResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/spo2 [post]
+// @Security Bearer
+func PostOxygenSaturationByUserId(c *gin.Context) {
+	oxygenSaturationResource.Create(c)
+}
+
+// PUT /users/:id/spo2/:recordId
+// Updates a oxygen saturation record for user.
+//
+// Swagger Doc
+// @Summary Updates a oxygen saturation record for user.
+// @Schemes
+// @Description Updates a oxygen saturation record for user. Time is optional. If not provided, the existing time is kept. Values outside plausible limits are rejected; unusual values are saved and listed in warnings. Readings below 92% are flagged as low oxygen.
+// @Tags Oxygen Saturation
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Oxygen Saturation ID"
+// @Param user body payload.OxygenSaturationRequest true "User Oxygen Saturation"
+// @Success 200 {object} payload.OxygenSaturationResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/spo2/{recordId} [put]
+// @Security Bearer
+func PutOxygenSaturationByUserId(c *gin.Context) {
+	oxygenSaturationResource.Update(c)
+}
+
+// DELETE /users/:id/spo2/:recordId
+// Deletes a oxygen saturation record for user.
+//
+// Swagger Doc
+// @Summary Deletes a oxygen saturation record for user.
+// @Schemes
+// @Description Deletes a oxygen saturation record for user.
+// @Tags Oxygen Saturation
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Oxygen Saturation ID"
+// @Success 200 {object} payload.OxygenSaturationResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/spo2/{recordId} [delete]
+// @Security Bearer
+func DeleteOxygenSaturationByUserId(c *gin.Context) {
+	oxygenSaturationResource.Delete(c)
+}
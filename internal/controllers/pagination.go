@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+)
+
+// Page sizes of list endpoints when the limit query parameter is missing,
+// and the largest page size a client can ask for.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+)
+
+// NextCursorHeader holds the cursor of the next page of a list response.
+// It is missing on the last page.
+const NextCursorHeader = "X-Next-Cursor"
+
+// page is a page of a list endpoint requested with the limit and cursor
+// query parameters. Cursors are opaque to clients; they encode the sort
+// key of the last record of the previous page.
+type page struct {
+	Limit  int
+	Cursor string
+}
+
+// Parses the limit and cursor query parameters. The limit defaults to
+// defaultPageSize.
+func parsePage(c *gin.Context) (page, error) {
+	p := page{Limit: defaultPageSize, Cursor: c.Query("cursor")}
+
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return p, apierror.Invalid("limit", "range", fmt.Sprintf("must be a number between 1 and %d", maxPageSize))
+		}
+		p.Limit = limit
+	}
+
+	return p, nil
+}
+
+func invalidCursor() error {
+	return apierror.Invalid("cursor", "format", "must be a cursor returned by a previous page")
+}
+
+// Encodes the time and id of the last record of a page as a cursor. The
+// time keeps the offset it was loaded with, so that it compares equal to
+// the stored value.
+func timeCursor(t time.Time, id uint) string {
+	return encodeCursor(t.Format(time.RFC3339Nano) + "|" + strconv.FormatUint(uint64(id), 10))
+}
+
+// Decodes a cursor created by timeCursor.
+func parseTimeCursor(cursor string) (time.Time, uint, error) {
+	s, err := decodeCursor(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	rawTime, rawId, _ := strings.Cut(s, "|")
+	t, err := time.Parse(time.RFC3339Nano, rawTime)
+	if err != nil {
+		return time.Time{}, 0, invalidCursor()
+	}
+
+	id, err := strconv.ParseUint(rawId, 10, 32)
+	if err != nil {
+		return time.Time{}, 0, invalidCursor()
+	}
+
+	return t, uint(id), nil
+}
+
+// Encodes the id of the last record of a page as a cursor.
+func idCursor(id uint) string {
+	return encodeCursor(strconv.FormatUint(uint64(id), 10))
+}
+
+// Decodes a cursor created by idCursor.
+func parseIdCursor(cursor string) (uint, error) {
+	s, err := decodeCursor(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, invalidCursor()
+	}
+
+	return uint(id), nil
+}
+
+func encodeCursor(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(b) == 0 {
+		return "", invalidCursor()
+	}
+
+	return string(b), nil
+}
+
+// Trims records fetched with one extra record to the page limit and, if
+// there is a next page, adds its cursor and a Link header to the response.
+func setNextPage[T any](c *gin.Context, p page, records []T, cursor func(T) string) []T {
+	if len(records) <= p.Limit {
+		return records
+	}
+
+	records = records[:p.Limit]
+	next := cursor(records[p.Limit-1])
+
+	u := *c.Request.URL
+	q := u.Query()
+	q.Set("cursor", next)
+	q.Set("limit", strconv.Itoa(p.Limit))
+	u.RawQuery = q.Encode()
+
+	// Added rather than set, to keep the successor-version link of
+	// deprecated routes.
+	c.Header(NextCursorHeader, next)
+	c.Writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+
+	return records
+}
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+// QueryField is a column of a vital that its list endpoint can be filtered
+// on. Number fields are compared with name_gt, name_gte, name_lt, name_lte
+// and name_ne as well as name for equality; text fields only match values.
+// Repeating name matches any of the values.
+type QueryField struct {
+	// Name is the query parameter, e.g. "sys".
+	Name string
+
+	// Column is the database column, e.g. "sys".
+	Column string
+
+	// Text is true for text columns.
+	Text bool
+}
+
+// NumberField returns a number QueryField.
+func NumberField(name string, column string) QueryField {
+	return QueryField{Name: name, Column: column}
+}
+
+// TextField returns a text QueryField.
+func TextField(name string, column string) QueryField {
+	return QueryField{Name: name, Column: column, Text: true}
+}
+
+// Comparison operators of number fields by query parameter suffix.
+var queryOperators = map[string]string{
+	"_gt":  ">",
+	"_gte": ">=",
+	"_lt":  "<",
+	"_lte": "<=",
+	"_ne":  "<>",
+}
+
+// Query parameters understood by every list endpoint.
+var listParams = map[string]bool{"limit": true, "cursor": true, "from": true, "to": true, "sort": true, "fields": true}
+
+// listQuery is the query string of a list endpoint: filters, sort direction
+// and the fields to return.
+type listQuery struct {
+	// Ascending lists the oldest readings first.
+	Ascending bool
+
+	// Fields are the JSON fields of each reading to return, or nil for all.
+	Fields []string
+
+	scopes []func(*gorm.DB) *gorm.DB
+}
+
+// Apply adds the filters of the query to db.
+func (q listQuery) Apply(db *gorm.DB) *gorm.DB {
+	return db.Scopes(q.scopes...)
+}
+
+// listQuerySpec describes what a list endpoint can be queried on.
+type listQuerySpec struct {
+	// Fields are the columns that can be filtered on.
+	Fields []QueryField
+
+	// Params are further query parameters read by the endpoint, e.g. the
+	// unit of a presenter.
+	Params []string
+
+	// Context is true if the readings have the tags and source of
+	// payload.ReadingContext.
+	Context bool
+
+	// Response is the response payload type, whose JSON fields can be
+	// selected with the fields parameter.
+	Response reflect.Type
+}
+
+// Parses the query string of a list endpoint. Every query parameter must
+// be known to spec and have a valid value; all problems are reported in a
+// single validation error.
+func parseListQuery(c *gin.Context, spec listQuerySpec) (listQuery, error) {
+	var q listQuery
+	var errs []payload.FieldError
+
+	invalid := func(field string, code string, message string) {
+		errs = append(errs, payload.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	fields := map[string]QueryField{}
+	for _, f := range spec.Fields {
+		fields[f.Name] = f
+	}
+
+	params := map[string]bool{}
+	for _, p := range spec.Params {
+		params[p] = true
+	}
+
+	values := c.Request.URL.Query()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	loc := userLocation(c)
+
+	for _, name := range names {
+		value := values.Get(name)
+
+		switch {
+		case name == "from":
+			from, _, err := parseTime(value, loc)
+			if err != nil {
+				invalid("from", "format", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+				continue
+			}
+			q.where("time >= ?", from.UTC())
+
+		case name == "to":
+			to, dateOnly, err := parseTime(value, loc)
+			if err != nil {
+				invalid("to", "format", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+				continue
+			}
+			if dateOnly {
+				to = to.AddDate(0, 0, 1)
+			}
+			q.where("time < ?", to.UTC())
+
+		case name == "sort":
+			switch value {
+			case "asc":
+				q.Ascending = true
+			case "desc":
+			default:
+				invalid("sort", "oneof", "must be one of: asc desc")
+			}
+
+		case name == "fields":
+			known := jsonFields(spec.Response)
+			for _, f := range strings.Split(value, ",") {
+				if !known[f] {
+					invalid("fields", "oneof", f+" is not a field of the response")
+					continue
+				}
+				q.Fields = append(q.Fields, f)
+			}
+
+		case name == "source" && spec.Context:
+			q.where("source IN ?", values[name])
+
+		case name == "tag" && spec.Context:
+			// Tags are matched with LIKE, so % and _ must not get through.
+			for _, tag := range values[name] {
+				if result := validation.Tag("tag", tag); len(result.Errors) > 0 {
+					errs = append(errs, result.Errors...)
+					continue
+				}
+				q.where("tags LIKE ?", models.TagPattern(tag))
+			}
+
+		case listParams[name] || params[name]:
+
+		default:
+			field, op, ok := queryField(fields, name)
+			if !ok {
+				invalid(name, "unknown", "is not a known query parameter")
+				continue
+			}
+
+			if field.Text {
+				q.where(field.Column+" IN ?", values[name])
+				continue
+			}
+
+			numbers := make([]float64, 0, len(values[name]))
+			for _, v := range values[name] {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					invalid(name, "number", "must be a number")
+					break
+				}
+				numbers = append(numbers, f)
+			}
+
+			if len(numbers) < len(values[name]) {
+				continue
+			}
+
+			if op == "" {
+				q.where(field.Column+" IN ?", numbers)
+				continue
+			}
+
+			for _, f := range numbers {
+				q.where(field.Column+" "+op+" ?", f)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return q, apierror.InvalidFields(errs)
+	}
+
+	return q, nil
+}
+
+// Adds a condition to the query.
+func (q *listQuery) where(query string, args ...any) {
+	q.scopes = append(q.scopes, func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, args...)
+	})
+}
+
+// Returns the field of a query parameter and its comparison operator,
+// which is empty for equality. Text fields have no operators.
+func queryField(fields map[string]QueryField, name string) (QueryField, string, bool) {
+	if f, ok := fields[name]; ok {
+		return f, "", true
+	}
+
+	for suffix, op := range queryOperators {
+		if f, ok := fields[strings.TrimSuffix(name, suffix)]; ok && strings.HasSuffix(name, suffix) && !f.Text {
+			return f, op, true
+		}
+	}
+
+	return QueryField{}, "", false
+}
+
+// Returns the names of the JSON fields of a struct type, including the
+// fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("json")
+
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			for name := range jsonFields(f.Type) {
+				names[name] = true
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+
+	return names
+}
+
+// Returns the responses with only the given JSON fields.
+func selectFields[Resp any](responses []Resp, fields []string) ([]map[string]json.RawMessage, error) {
+	res := make([]map[string]json.RawMessage, 0, len(responses))
+
+	for _, r := range responses {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(b, &all); err != nil {
+			return nil, err
+		}
+
+		selected := make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				selected[f] = v
+			}
+		}
+		res = append(res, selected)
+	}
+
+	return res, nil
+}
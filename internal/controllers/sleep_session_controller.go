@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+var sleepSessionResource = NewVitalResource("Sleep Session", payload.ApplySleepSessionRequest, payload.MapSleepSessionResponse, validation.SleepSession).
+	WithBeforeDelete(deleteAttachments[*models.SleepSession]("sleep")).
+	WithBeforeSave(saveSleepSession).
+	WithFields(
+		NumberField("quality", "quality"),
+		TextField("nightOf", "night_of"),
+	).
+	WithStats("quality")
+
+// GET /users/:id/sleep
+// Get all sleep session records for a user.
+//
+// Swagger Doc
+// @Summary Get all sleep session records for a user.
+// @Schemes
+// @Description Get all sleep session records for a user. Filter on quality (1-5), e.g. quality_lte=2, also with the _gt, _gte, _lt and _ne suffixes, and on the night a session belongs to, e.g. nightOf=2024-01-01.
+// @Tags Sleep Session
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
+// @Success 200 {array} payload.SleepSessionResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/sleep [get]
+// @Security Bearer
+func GetSleepSessionByUserId(c *gin.Context) {
+	sleepSessionResource.List(c)
+}
+
+// GET /users/:id/sleep/:recordId
+// Get a sleep session record for user.
+//
+// Swagger Doc
+// @Summary Get a sleep session record for user.
+// @Schemes
+// @Description Get a sleep session record for user.
+// @Tags Sleep Session
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Sleep Session ID"
+// @Success 200 {object} payload.SleepSessionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/sleep/{recordId} [get]
+// @Security Bearer
+func GetSleepSessionById(c *gin.Context) {
+	sleepSessionResource.Get(c)
+}
+
+// POST /users/:id/sleep
+// Adds a new sleep session record for user.
+//
+// Swagger Doc
+// @Summary Adds a new sleep session record for user.
+// @Schemes
+// @Description Adds a new sleep session record for user. Start and end are required. Sessions that overlap another session of the user are rejected with 409 Conflict. The session is attributed to the night of the date it starts on, or of the previous date if it starts before noon. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Sleep Session
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.SleepSessionRequest true "User Sleep Session"
+// @Success 200 {object} payload.SleepSessionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Failure 409 {object} payload.Problem
+// @Router /users/{id}/sleep [post]
+// @Security Bearer
+func PostSleepSessionByUserId(c *gin.Context) {
+	sleepSessionResource.Create(c)
+}
+
+// PUT /users/:id/sleep/:recordId
+// Updates a sleep session record for user.
+//
+// Swagger Doc
+// @Summary Updates a sleep session record for user.
+// @Schemes
+// @Description Updates a sleep session record for user. Start and end are required. Sessions that overlap another session of the user are rejected with 409 Conflict. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
+// @Tags Sleep Session
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Sleep Session ID"
+// @Param user body payload.SleepSessionRequest true "User Sleep Session"
+// @Success 200 {object} payload.SleepSessionResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Failure 409 {object} payload.Problem
+// @Router /users/{id}/sleep/{recordId} [put]
+// @Security Bearer
+func PutSleepSessionByUserId(c *gin.Context) {
+	sleepSessionResource.Update(c)
+}
+
+// DELETE /users/:id/sleep/:recordId
+// Deletes a sleep session record for user.
+//
+// Swagger Doc
+// @Summary Deletes a sleep session record for user.
+// @Schemes
+// @Description Deletes a sleep session record for user.
+// @Tags Sleep Session
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Sleep Session ID"
+// @Success 200 {object} payload.SleepSessionResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/sleep/{recordId} [delete]
+// @Security Bearer
+func DeleteSleepSessionByUserId(c *gin.Context) {
+	sleepSessionResource.Delete(c)
+}
+
+// GET /users/:id/sleep/nightly
+// Get nightly sleep summaries for a user.
+//
+// Swagger Doc
+// @Summary Get nightly sleep summaries for a user.
+// @Schemes
+// @Description Summarizes the sleep sessions of each night between from and to (default: the last 30 days), oldest first. Nights without sessions are omitted. The range may be at most 400 days.
+// @Tags Sleep Session
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param from query string false "First night, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Last night, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Success 200 {array} payload.SleepNightResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/sleep/nightly [get]
+// @Security Bearer
+func GetSleepNightlyByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDayRange(c, 30)
+	if !ok {
+		return
+	}
+
+	// to is exclusive, so the last night is the date just before it.
+	first := from.Format(time.DateOnly)
+	last := to.Add(-time.Nanosecond).Format(time.DateOnly)
+
+	var sessions []models.SleepSession
+	err := models.DB.Where("user_id = ? AND night_of >= ? AND night_of <= ?", u.ID, first, last).
+		Order("time").
+		Find(&sessions).Error
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Sleep Session", err))
+		return
+	}
+
+	nights := map[string]*payload.SleepNightResponse{}
+	qualities := map[string][]uint8{}
+
+	for _, s := range sessions {
+		night, ok := nights[s.NightOf]
+		if !ok {
+			night = &payload.SleepNightResponse{NightOf: s.NightOf}
+			nights[s.NightOf] = night
+		}
+
+		minutes := int(s.EndTime.Sub(s.Time).Minutes())
+		night.Sessions++
+		night.TimeInBedMinutes += minutes
+		night.AsleepMinutes += minutes
+
+		if s.LightMinutes != nil {
+			if night.Stages == nil {
+				night.Stages = &payload.SleepStages{}
+			}
+			night.Stages.Light += *s.LightMinutes
+			night.Stages.Deep += *s.DeepMinutes
+			night.Stages.Rem += *s.RemMinutes
+			night.Stages.Awake += *s.AwakeMinutes
+			night.AsleepMinutes -= int(*s.AwakeMinutes)
+		}
+
+		if s.Quality != nil {
+			qualities[s.NightOf] = append(qualities[s.NightOf], *s.Quality)
+		}
+	}
+
+	res := make([]payload.SleepNightResponse, 0, len(nights))
+	for nightOf, night := range nights {
+		if q := qualities[nightOf]; len(q) > 0 {
+			sum := 0
+			for _, v := range q {
+				sum += int(v)
+			}
+			avg := math.Round(float64(sum)/float64(len(q))*10) / 10
+			night.Quality = &avg
+		}
+		res = append(res, *night)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].NightOf < res[j].NightOf
+	})
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Attributes a session to a night in the user's time zone, falling back
+// to the UTC offset its start was submitted with, and rejects sessions
+// that overlap another session of the same user.
+func saveSleepSession(tx *gorm.DB, s *models.SleepSession) error {
+	start := s.LocalTime()
+	if loc := userTimeZone(s.UserID); loc != nil {
+		start = start.In(loc)
+	}
+	s.NightOf = payload.SleepNightOf(start)
+
+	return checkSleepOverlap(tx, s)
+}
+
+// Rejects sessions that overlap another session of the same user.
+func checkSleepOverlap(tx *gorm.DB, s *models.SleepSession) error {
+	var count int64
+	err := tx.Model(&models.SleepSession{}).
+		Where("user_id = ? AND id <> ? AND time < ? AND end_time > ?", s.UserID, s.ID, s.EndTime, s.Time).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return apierror.Conflict("Sleep session overlaps an existing session")
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"gorm.io/gorm"
+)
+
+// statsSpec describes what the stats endpoint of a vital summarizes.
+type statsSpec struct {
+	// Name is used in error messages, e.g. "Blood Pressure".
+	Name string
+
+	// Model is a pointer to the model of the vital.
+	Model any
+
+	// Scope restricts the readings, e.g. to the values of one custom metric.
+	Scope func(*gorm.DB) *gorm.DB
+
+	// Fields are the number fields summarized per period.
+	Fields []QueryField
+
+	// Totals adds the sum of each period.
+	Totals bool
+
+	// Unit returns the unit the statistics of the request are in, for
+	// vitals whose values can be returned in other units. Optional.
+	Unit func(*gin.Context) (statsUnit, error)
+}
+
+// statsUnit converts stored values to the unit of the statistics, as
+// value*Scale + Offset.
+type statsUnit struct {
+	Name   string
+	Scale  float64
+	Offset float64
+}
+
+// The unit of vitals whose values are summarized in the stored unit.
+var storedUnit = statsUnit{Scale: 1}
+
+// Converts a stored value to the unit.
+func (u statsUnit) convert(v float64) float64 {
+	return v*u.Scale + u.Offset
+}
+
+// Default time windows of the stats endpoint by interval, in days.
+var statsIntervals = map[string]int{"day": 30, "week": 12 * 7, "month": 365}
+
+// The longest series of periods the stats endpoint returns.
+const maxStatsPeriods = 400
+
+// StatsReadings returns the path names of the vitals with a stats endpoint,
+// e.g. "blood-pressure". Blood glucose is left out: its own stats endpoint
+// returns these statistics when it is given an interval.
+func StatsReadings() []string {
+	var names []string
+	for vital, r := range vitalResources {
+		if vital != "glucose" && len(r.statistics().Fields) > 0 {
+			names = append(names, vital)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// GET /users/:id/{vital}/stats
+// Get statistics of a vital per period for a user.
+//
+// Swagger Doc
+// @Summary Get statistics of a vital per period for a user.
+// @Schemes
+// @Description Summarizes the readings of a vital per day, week or month between from and to (default: the last 30 days, 12 weeks or 365 days), oldest first. Periods are in the time zone of the user; weeks start on Monday. For each number field of the vital there is the count, min, max, mean, median and standard deviation, and the sum for water and sugar intake. Weights, water intakes, glucose and temperatures are in the unit of the unit parameter, or the unit the user prefers for weights and water, and the unit is returned with their statistics. They default to kg, ml, mg/dL and C.
+// @Tags Statistics
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param vital path string true "Vital path name" Enums(activities, blood-pressure, body-composition, heart-rate, journal, measurements, nutrition, sleep, spo2, sugar, temperature, water, weight)
+// @Param interval query string false "Length of the periods" Enums(day, week, month) default(day)
+// @Param from query string false "Start of the window, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "End of the window, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param unit query string false "Unit of temperature (C, F), water (ml, l, fl oz, cups) or weight (kg, lb, st) statistics"
+// @Success 200 {array} payload.StatsPeriodResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/{vital}/stats [get]
+// @Security Bearer
+func GetStatsByReading(vital string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		vitalStats(c, vital)
+	}
+}
+
+// Writes the statistics per period of a vital for the user identified by
+// the :id path parameter.
+func vitalStats(c *gin.Context, vital string) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "day")
+	days, ok := statsIntervals[interval]
+	if !ok {
+		apierror.Abort(c, apierror.Invalid("interval", "oneof", "must be one of: day week month"))
+		return
+	}
+
+	from, to, ok := parseTimeRange(c, days)
+	if !ok {
+		return
+	}
+
+	bounds := statsPeriods(from, to, interval)
+	if len(bounds) > maxStatsPeriods+1 {
+		apierror.Abort(c, apierror.Invalid("from", "range", fmt.Sprintf("must not be more than %d periods before to", maxStatsPeriods)))
+		return
+	}
+
+	spec := vitalResources[vital].statistics()
+
+	unit := storedUnit
+	if spec.Unit != nil {
+		var err error
+		if unit, err = spec.Unit(c); err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+	}
+
+	res := make([]payload.StatsPeriodResponse, len(bounds)-1)
+	for i := range res {
+		res[i] = payload.StatsPeriodResponse{Start: bounds[i], End: bounds[i+1], Fields: map[string]payload.FieldStats{}}
+	}
+
+	query := func() *gorm.DB {
+		db := models.DB.Model(spec.Model)
+		if spec.Scope != nil {
+			db = db.Scopes(spec.Scope)
+		}
+		return db.Where("user_id = ? AND time >= ? AND time < ?", u.ID, bounds[0].UTC(), bounds[len(bounds)-1].UTC())
+	}
+
+	if err := aggregateStats(query(), spec, unit, bounds, res); err != nil {
+		apierror.Abort(c, apierror.Database(spec.Name, err))
+		return
+	}
+
+	for _, f := range spec.Fields {
+		if err := medianStats(query(), f, unit, bounds, res); err != nil {
+			apierror.Abort(c, apierror.Database(spec.Name, err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Returns the boundaries of the periods of interval that cover from to to,
+// in the time zone of from. The last boundary is the end of the last
+// period. Stops after one more period than the stats endpoint returns.
+func statsPeriods(from time.Time, to time.Time, interval string) []time.Time {
+	y, m, d := from.Date()
+
+	var start time.Time
+	switch interval {
+	case "week":
+		start = weekStart(from)
+	case "month":
+		start = time.Date(y, m, 1, 0, 0, 0, 0, from.Location())
+	default:
+		start = time.Date(y, m, d, 0, 0, 0, 0, from.Location())
+	}
+
+	bounds := []time.Time{start}
+	for t := start; t.Before(to) && len(bounds) <= maxStatsPeriods+1; {
+		// AddDate keeps periods on local midnights across daylight saving
+		// time transitions.
+		switch interval {
+		case "week":
+			t = t.AddDate(0, 0, 7)
+		case "month":
+			t = t.AddDate(0, 1, 0)
+		default:
+			t = t.AddDate(0, 0, 1)
+		}
+		bounds = append(bounds, t)
+	}
+
+	return bounds
+}
+
+// Returns an SQL expression of the index of the period a reading is in and
+// its arguments. The readings must be within the periods. Times are
+// compared in UTC, in which readings are stored.
+func periodExpr(bounds []time.Time) (string, []any) {
+	if len(bounds) == 2 {
+		return "0", nil
+	}
+
+	var b strings.Builder
+	args := make([]any, 0, len(bounds)-2)
+
+	b.WriteString("CASE")
+	for i := 1; i < len(bounds)-1; i++ {
+		fmt.Fprintf(&b, " WHEN time < ? THEN %d", i-1)
+		args = append(args, bounds[i].UTC())
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(bounds)-2)
+
+	return b.String(), args
+}
+
+// Computes the count of each period and the count, min, max, mean,
+// standard deviation and sum of each field in SQL, converted to unit.
+func aggregateStats(db *gorm.DB, spec statsSpec, unit statsUnit, bounds []time.Time, res []payload.StatsPeriodResponse) error {
+	period, args := periodExpr(bounds)
+
+	selects := []string{period + " AS period_index", "COUNT(*)"}
+	for _, f := range spec.Fields {
+		selects = append(selects, fmt.Sprintf("COUNT(%[1]s), MIN(%[1]s), MAX(%[1]s), AVG(%[1]s), AVG(%[1]s * %[1]s), SUM(%[1]s)", f.Column))
+	}
+
+	rows, err := db.Select(strings.Join(selects, ", "), args...).Group("period_index").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i, count int
+		values := make([]sql.NullFloat64, 6*len(spec.Fields))
+
+		dest := []any{&i, &count}
+		for j := range values {
+			dest = append(dest, &values[j])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+
+		res[i].Count = count
+
+		for j, f := range spec.Fields {
+			v := values[6*j : 6*j+6]
+			if v[0].Float64 == 0 {
+				continue
+			}
+
+			// The offset of the unit moves the values but not their
+			// spread or, for intakes, which have none, their sum.
+			stats := payload.FieldStats{
+				Count:  int(v[0].Float64),
+				Min:    roundStat(unit.convert(v[1].Float64)),
+				Max:    roundStat(unit.convert(v[2].Float64)),
+				Mean:   roundStat(unit.convert(v[3].Float64)),
+				StdDev: roundStat(math.Abs(unit.Scale) * math.Sqrt(math.Max(0, v[4].Float64-v[3].Float64*v[3].Float64))),
+				Unit:   unit.Name,
+			}
+
+			if spec.Totals {
+				sum := roundStat(v[5].Float64 * unit.Scale)
+				stats.Sum = &sum
+			}
+
+			res[i].Fields[f.Name] = stats
+		}
+	}
+
+	return rows.Err()
+}
+
+// Computes the median of a field in each period, converted to unit. SQLite
+// has no percentile functions, so the values are sorted in SQL and the
+// median is picked here.
+func medianStats(db *gorm.DB, f QueryField, unit statsUnit, bounds []time.Time, res []payload.StatsPeriodResponse) error {
+	var values []struct {
+		Time  time.Time
+		Value float64
+	}
+
+	err := db.Select("time, " + f.Column + " AS value").Where(f.Column + " IS NOT NULL").Order(f.Column).Scan(&values).Error
+	if err != nil {
+		return err
+	}
+
+	periods := make([][]float64, len(res))
+	for _, v := range values {
+		i := sort.Search(len(bounds), func(i int) bool { return bounds[i].After(v.Time) }) - 1
+		if i >= 0 && i < len(periods) {
+			periods[i] = append(periods[i], v.Value)
+		}
+	}
+
+	for i, p := range periods {
+		stats, ok := res[i].Fields[f.Name]
+		if !ok || len(p) == 0 {
+			continue
+		}
+
+		median := p[len(p)/2]
+		if len(p)%2 == 0 {
+			median = (p[len(p)/2-1] + median) / 2
+		}
+
+		stats.Median = roundStat(unit.convert(median))
+		res[i].Fields[f.Name] = stats
+	}
+
+	return nil
+}
+
+// Rounds a statistic to two decimals.
+func roundStat(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -1,44 +1,75 @@
 package controllers
 
 import (
-	"net/http"
-	"strconv"
-	"time"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
 )
 
+var sugarIntakeResource = NewVitalResource("Sugar Intake", payload.ApplySugarIntakeRequest, payload.MapSugarIntakeResponse, validation.SugarIntake).
+	WithBeforeSave(checkSugarIntakeSource).
+	WithBeforeDelete(checkSugarIntakeSource).
+	WithBeforeDelete(deleteAttachments[*models.SugarIntake]("sugar")).
+	WithFields(
+		NumberField("grams", "grams"),
+	).
+	WithStats("grams").
+	WithTotals()
+
 // GET /users/:id/sugar
 // Get all sugar intake records for a user.
 //
 // Swagger Doc
 // @Summary Get all sugar intake records for a user.
 // @Schemes
-// @Description Get all sugar intake records for a user.
+// @Description Get all sugar intake records for a user, including the sugar of nutrition entries. Filter on grams, e.g. grams_gt=25, also with the _gte, _lt, _lte and _ne suffixes.
 // @Tags Sugar Intake
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
 // @Success 200 {array} payload.SugarIntakeResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id}/sugar [get]
 // @Security Bearer
 func GetSugarIntakeByUserId(c *gin.Context) {
-	userId := c.Param("id")
-	var si []models.SugarIntake
-
-	var u models.User
-
-	if err := models.DB.Where("id = ?", c.Param("id")).First(&u).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "User not found"})
-		return
-	}
-
-	models.DB.Where("user_id = ?", userId).Order("time DESC").Find(&si)
+	sugarIntakeResource.List(c)
+}
 
-	c.JSON(http.StatusOK, Map(si, payload.MapSugarIntakeResponse))
+// GET /users/:id/sugar/:recordId
+// Get a sugar intake record for user.
+//
+// Swagger Doc
+// @Summary Get a sugar intake record for user.
+// @Schemes
+// @Description Get a sugar intake record for user.
+// @Tags Sugar Intake
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param recordId path int true "Sugar Intake ID"
+// @Success 200 {object} payload.SugarIntakeResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/sugar/{recordId} [get]
+// @Security Bearer
+func GetSugarIntakeById(c *gin.Context) {
+	sugarIntakeResource.Get(c)
 }
 
 // POST /users/:id/sugar
@@ -47,138 +78,74 @@ func GetSugarIntakeByUserId(c *gin.Context) {
 // Swagger Doc
 // @Summary Adds a new sugar intake record for user.
 // @Schemes
-// @Description Adds a new sugar intake record for user. Time is optional. If not provided, current time is used.
+// @Description Adds a new sugar intake record for user. Time is optional. If not provided, current time is used. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
 // @Tags Sugar Intake
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
 // @Param user body payload.SugarIntakeRequest true "User Sugar Intake"
 // @Success 200 {object} payload.SugarIntakeResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id}/sugar [post]
 // @Security Bearer
 func PostSugarIntakeByUserId(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	// Validate r
-	var r payload.SugarIntakeRequest
-	if err := c.ShouldBindJSON(&r); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	createTime := time.Now()
-	if r.Time.IsZero() == false {
-		createTime = r.Time
-	}
-
-	// Create Sugar Intake Record
-	si := models.SugarIntake{Grams: r.Grams, UserID: uint(id), Time: createTime}
-	models.DB.Create(&si)
-
-	c.JSON(http.StatusOK, payload.MapSugarIntakeResponse(si))
+	sugarIntakeResource.Create(c)
 }
 
-// PUT /users/:userId/sugar/:id
+// PUT /users/:id/sugar/:recordId
 // Updates a sugar intake record for user
 //
 // Swagger Doc
 // @Summary Updates a sugar intake record for user
 // @Schemes
-// @Description Updates a sugar intake record for user
+// @Description Updates a sugar intake record for user. Records logged with a nutrition entry are rejected with 409 Conflict; update the nutrition entry instead. Time is optional. If not provided, the existing time is kept. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
 // @Tags Sugar Intake
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Param id path int true "Sugar Intake ID"
+// @Param id path int true "User ID"
+// @Param recordId path int true "Sugar Intake ID"
 // @Param user body payload.SugarIntakeRequest true "User Sugar Intake"
 // @Success 200 {object} payload.SugarIntakeResponse
-// @Failure 404 {object} payload.ErrorResponse
-// @Router /users/{userId}/sugar/{id} [put]
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Failure 409 {object} payload.Problem
+// @Router /users/{id}/sugar/{recordId} [put]
 // @Security Bearer
 func PutSugarIntakeByUserId(c *gin.Context) {
-	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid sugar intake id"})
-		return
-	}
-
-	// Validate Request
-	var r payload.SugarIntakeRequest
-	if err := c.ShouldBindJSON(&r); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	// Find Sugar Intake Record
-	var si models.SugarIntake
-	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&si).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "Sugar Intake not found"})
-		return
-	}
-
-	// Update Sugar Intake Record
-	si.Grams = r.Grams
-	si.Time = r.Time
-
-	models.DB.Save(&si)
-
-	c.JSON(http.StatusOK, payload.MapSugarIntakeResponse(si))
+	sugarIntakeResource.Update(c)
 }
 
-// DELETE /users/:userId/sugar/:id
+// DELETE /users/:id/sugar/:recordId
 // Deletes a sugar intake record for user.
 //
 // Swagger Doc
 // @Summary Deletes a sugar intake record for user.
 // @Schemes
-// @Description Deletes a sugar intake record for user.
+// @Description Deletes a sugar intake record for user. Records logged with a nutrition entry are rejected with 409 Conflict; delete the nutrition entry instead.
 // @Tags Sugar Intake
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Param id path int true "Sugar Intake ID"
+// @Param id path int true "User ID"
+// @Param recordId path int true "Sugar Intake ID"
 // @Success 200 {object} payload.SugarIntakeResponse
-// @Failure 404 {object} payload.ErrorResponse
-// @Router /users/{userId}/sugar/{id} [delete]
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Failure 409 {object} payload.Problem
+// @Router /users/{id}/sugar/{recordId} [delete]
 // @Security Bearer
 func DeleteSugarIntakeByUserId(c *gin.Context) {
-	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid sugar id"})
-		return
-	}
+	sugarIntakeResource.Delete(c)
+}
 
-	// Find Sugar Intake Record
-	var si models.SugarIntake
-	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&si).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "Sugar Intake not found"})
-		return
+// Rejects changes to sugar intake records that were created from a
+// nutrition entry. Those change with the entry.
+func checkSugarIntakeSource(_ *gorm.DB, si *models.SugarIntake) error {
+	if si.NutritionEntryID != nil {
+		return apierror.Conflict(fmt.Sprintf("Sugar intake was logged with nutrition entry %d and can only be changed there", *si.NutritionEntryID))
 	}
 
-	// Delete Sugar Intake Record
-	models.DB.Delete(&si)
-
-	c.JSON(http.StatusOK, payload.MapSugarIntakeResponse(si))
+	return nil
 }
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+// GET /users/:id/tags
+// Get the tags of a user.
+//
+// Swagger Doc
+// @Summary Get the tags of a user.
+// @Schemes
+// @Description Get the tags used on the readings of a user, ordered by tag, with the number of readings across all vitals that have each tag.
+// @Tags Tag
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {array} payload.TagResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/tags [get]
+// @Security Bearer
+func GetTagsByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	counts := map[string]int{}
+
+	for _, model := range models.TaggedReadings() {
+		var tags []string
+		if err := models.DB.Model(model).Where("user_id = ? AND tags <> ''", u.ID).Pluck("tags", &tags).Error; err != nil {
+			apierror.Abort(c, apierror.Database("Tag", err))
+			return
+		}
+
+		for _, t := range tags {
+			for _, tag := range (models.Reading{Tags: t}).TagList() {
+				counts[tag]++
+			}
+		}
+	}
+
+	res := make([]payload.TagResponse, 0, len(counts))
+	for tag, count := range counts {
+		res = append(res, payload.TagResponse{Tag: tag, Count: count})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Tag < res[j].Tag
+	})
+
+	c.JSON(http.StatusOK, res)
+}
+
+// PUT /users/:id/tags/:tag
+// Renames a tag of a user.
+//
+// Swagger Doc
+// @Summary Renames a tag of a user.
+// @Schemes
+// @Description Renames a tag on the readings of every vital of a user. If the new name is already in use the tags are merged.
+// @Tags Tag
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param tag path string true "Tag"
+// @Param name body payload.TagRequest true "New Name"
+// @Success 200 {object} payload.TagResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/tags/{tag} [put]
+// @Security Bearer
+func PutTagByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	var r payload.TagRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		apierror.Abort(c, apierror.Validation(err))
+		return
+	}
+
+	// The path tag is checked too as it is used in a LIKE pattern.
+	tag := c.Param("tag")
+	result := validation.Tag("tag", tag)
+	result.Merge(validation.Tag("name", r.Name))
+
+	if err := result.Err(); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	res := payload.TagResponse{Tag: r.Name}
+	renamed := 0
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range models.TaggedReadings() {
+			var readings []struct {
+				ID   uint
+				Tags string
+			}
+
+			err := tx.Model(model).Select("id", "tags").Where("user_id = ? AND tags LIKE ?", u.ID, models.TagPattern(tag)).Find(&readings).Error
+			if err != nil {
+				return err
+			}
+
+			for _, reading := range readings {
+				tags := renameTag((models.Reading{Tags: reading.Tags}).TagList(), tag, r.Name)
+
+				// UpdateColumn skips the model hooks, e.g. the sugar intake
+				// sync of nutrition entries.
+				if err := tx.Model(model).Where("id = ?", reading.ID).UpdateColumn("tags", models.JoinTags(tags)).Error; err != nil {
+					return err
+				}
+			}
+
+			renamed += len(readings)
+
+			var count int64
+			if err := tx.Model(model).Where("user_id = ? AND tags LIKE ?", u.ID, models.TagPattern(r.Name)).Count(&count).Error; err != nil {
+				return err
+			}
+
+			res.Count += int(count)
+		}
+
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Database("Tag", err))
+		return
+	}
+
+	if renamed == 0 {
+		apierror.Abort(c, apierror.NotFound("Tag"))
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Replaces from with to in tags, dropping to if it is already listed.
+func renameTag(tags []string, from string, to string) []string {
+	res := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		if tag == from {
+			tag = to
+		}
+
+		if !slices.Contains(res, tag) {
+			res = append(res, tag)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"gorm.io/gorm"
+)
+
+// GET /users/:id/timeline
+// Get the readings of all vitals of a user in one stream.
+//
+// Swagger Doc
+// @Summary Get the readings of all vitals of a user in one stream.
+// @Schemes
+// @Description Get the readings of all vitals of a user, newest first, as entries with the type of the vital and the reading as returned by the endpoint of the type. The values of custom metrics have the type metrics; the metric of a value is in its metricKey. Readings at the same time are ordered by type. Weights and water intakes are returned in the units preferred by the user.
+// @Tags Timeline
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param type query []string false "Only these vitals, by path name, e.g. blood-pressure, or metrics for the values of custom metrics" collectionFormat(multi)
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. type,id,time"
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Success 200 {array} payload.TimelineEntry
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/timeline [get]
+// @Security Bearer
+func GetTimelineByUserId(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	q, err := parseListQuery(c, listQuerySpec{
+		Params:   []string{"type"},
+		Context:  true,
+		Response: reflect.TypeOf(payload.TimelineEntry{}),
+	})
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	sources := timelineSources()
+
+	types, err := timelineTypes(c, sources)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	order, before := "time DESC, id DESC", "<"
+	if q.Ascending {
+		order, before = "time, id", ">"
+	}
+
+	var cursor *timelineItem
+	if p.Cursor != "" {
+		item, err := parseTimelineCursor(p.Cursor)
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		cursor = &item
+	}
+
+	var items []timelineItem
+	for _, vital := range types {
+		scope := func(db *gorm.DB) *gorm.DB {
+			db = q.Apply(db.Where("user_id = ?", u.ID))
+			if cursor == nil {
+				return db
+			}
+
+			// Entries are ordered by time, then type and then id, so the
+			// readings at the time of the cursor only follow it in types
+			// after the type of the cursor.
+			t := cursor.Time.UTC()
+			switch {
+			case vital < cursor.Entry.Type:
+				return db.Where("time "+before+" ?", t)
+			case vital > cursor.Entry.Type:
+				return db.Where("time "+before+"= ?", t)
+			default:
+				return db.Where("(time "+before+" ? OR (time = ? AND id "+before+" ?))", t, t, cursor.Entry.Id)
+			}
+		}
+
+		// Each vital can fill the page on its own.
+		vitalItems, err := sources[vital].timeline(c, vital, scope, order, p.Limit+1)
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		items = append(items, vitalItems...)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time) != q.Ascending
+		}
+		if a.Entry.Type != b.Entry.Type {
+			return a.Entry.Type < b.Entry.Type
+		}
+		return (a.Entry.Id > b.Entry.Id) != q.Ascending
+	})
+
+	if len(items) > p.Limit+1 {
+		items = items[:p.Limit+1]
+	}
+
+	items = setNextPage(c, p, items, func(item timelineItem) string {
+		return encodeCursor(item.Time.Format(time.RFC3339Nano) + "|" + item.Entry.Type + "|" + strconv.FormatUint(uint64(item.Entry.Id), 10))
+	})
+
+	entries := Map(items, func(item timelineItem) payload.TimelineEntry {
+		return item.Entry
+	})
+
+	if q.Fields == nil {
+		c.JSON(http.StatusOK, entries)
+		return
+	}
+
+	selected, err := selectFields(entries, q.Fields)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, selected)
+}
+
+// timelineSource is implemented by everything with readings in the
+// timeline. It returns the readings in the scope, in order.
+type timelineSource interface {
+	timeline(c *gin.Context, vital string, scope func(*gorm.DB) *gorm.DB, order string, limit int) ([]timelineItem, error)
+}
+
+// Returns the sources of the timeline by type: the vitals and the values
+// of the custom metrics.
+func timelineSources() map[string]timelineSource {
+	sources := map[string]timelineSource{"metrics": metricTimeline{}}
+	for vital, r := range vitalResources {
+		sources[vital] = r
+	}
+
+	return sources
+}
+
+// Returns the types in the type query parameters, or all types if there
+// are none, in order.
+func timelineTypes(c *gin.Context, sources map[string]timelineSource) ([]string, error) {
+	types := c.QueryArray("type")
+	if len(types) == 0 {
+		for vital := range sources {
+			types = append(types, vital)
+		}
+	}
+
+	for _, vital := range types {
+		if _, ok := sources[vital]; !ok {
+			names := make([]string, 0, len(sources))
+			for name := range sources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			return nil, apierror.Invalid("type", "oneof", "must be one of: "+strings.Join(names, " "))
+		}
+	}
+
+	sort.Strings(types)
+	return slices.Compact(types), nil
+}
+
+// Decodes the cursor of a timeline page, the time, type and id of the
+// last entry of the previous page.
+func parseTimelineCursor(cursor string) (timelineItem, error) {
+	var item timelineItem
+
+	s, err := decodeCursor(cursor)
+	if err != nil {
+		return item, err
+	}
+
+	parts := strings.Split(s, "|")
+	if len(parts) != 3 {
+		return item, invalidCursor()
+	}
+
+	if item.Time, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return item, invalidCursor()
+	}
+
+	id, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return item, invalidCursor()
+	}
+
+	item.Entry.Type = parts[1]
+	item.Entry.Id = uint(id)
+
+	return item, nil
+}
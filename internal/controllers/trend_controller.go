@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+)
+
+// trendSpec describes what the trend endpoint of a vital smooths.
+type trendSpec struct {
+	// Name is used in error messages, e.g. "Weight".
+	Name string
+
+	// Model is a pointer to the model of the vital.
+	Model any
+
+	// Unit of the values in the response, e.g. "kg".
+	Unit string
+
+	// Fields are the number fields smoothed, named as in the responses of
+	// the vital, e.g. NumberField("weight", "kilograms").
+	Fields []QueryField
+
+	// Scale converts stored values to Unit.
+	Scale float64
+
+	// TargetField is the field the target query parameter applies to.
+	TargetField string
+}
+
+// Defaults and limits of the window and alpha query parameters.
+const (
+	defaultTrendWindow = 7
+	maxTrendWindow     = 90
+	defaultTrendAlpha  = 0.1
+)
+
+// Targets further away than this are not projected.
+const maxTargetDays = 10 * 365
+
+// Writes the trend of a vital for the user identified by the :id path
+// parameter.
+func vitalTrend(c *gin.Context, spec trendSpec) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseTimeRange(c, 90)
+	if !ok {
+		return
+	}
+
+	window := defaultTrendWindow
+	if s := c.Query("window"); s != "" {
+		w, err := strconv.Atoi(s)
+		if err != nil || w < 1 || w > maxTrendWindow {
+			apierror.Abort(c, apierror.Invalid("window", "range", fmt.Sprintf("must be a number of days between 1 and %d", maxTrendWindow)))
+			return
+		}
+		window = w
+	}
+
+	alpha := defaultTrendAlpha
+	if s := c.Query("alpha"); s != "" {
+		a, err := strconv.ParseFloat(s, 64)
+		if err != nil || a <= 0 || a > 1 {
+			apierror.Abort(c, apierror.Invalid("alpha", "range", "must be a number greater than 0 and at most 1"))
+			return
+		}
+		alpha = a
+	}
+
+	var target *float64
+	if s := c.Query("target"); s != "" {
+		t, err := strconv.ParseFloat(s, 64)
+		if err != nil || t <= 0 {
+			apierror.Abort(c, apierror.Invalid("target", "gt", "must be a number greater than 0"))
+			return
+		}
+		target = &t
+	}
+
+	days, err := dailyMeans(u.ID, from, to, spec)
+	if err != nil {
+		apierror.Abort(c, apierror.Database(spec.Name, err))
+		return
+	}
+
+	res := payload.TrendResponse{
+		From:   from,
+		To:     to,
+		Unit:   spec.Unit,
+		Window: window,
+		Alpha:  alpha,
+		Fields: map[string]payload.FieldTrend{},
+	}
+
+	for i, f := range spec.Fields {
+		var t *float64
+		if f.Name == spec.TargetField {
+			t = target
+		}
+		res.Fields[f.Name] = fieldTrend(days[i], window, alpha, t)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Returns the mean value of each field per day with readings, oldest
+// first. Days are in the time zone of from.
+func dailyMeans(userId uint, from time.Time, to time.Time, spec trendSpec) ([][]payload.TrendDay, error) {
+	columns := []string{"time"}
+	for _, f := range spec.Fields {
+		columns = append(columns, f.Column)
+	}
+
+	rows, err := models.DB.Model(spec.Model).Select(columns).Where("user_id = ? AND time >= ? AND time < ?", userId, from.UTC(), to.UTC()).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type total struct {
+		Count int
+		Sum   float64
+	}
+	totals := make([]map[string]*total, len(spec.Fields))
+	for i := range totals {
+		totals[i] = map[string]*total{}
+	}
+
+	for rows.Next() {
+		var t time.Time
+		values := make([]sql.NullFloat64, len(spec.Fields))
+
+		dest := []any{&t}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		date := t.In(from.Location()).Format(time.DateOnly)
+		for i, v := range values {
+			if !v.Valid {
+				continue
+			}
+			if totals[i][date] == nil {
+				totals[i][date] = &total{}
+			}
+			totals[i][date].Count++
+			totals[i][date].Sum += v.Float64 * spec.Scale
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	days := make([][]payload.TrendDay, len(spec.Fields))
+	for i, dates := range totals {
+		days[i] = make([]payload.TrendDay, 0, len(dates))
+		for date, t := range dates {
+			days[i] = append(days[i], payload.TrendDay{Date: date, Count: t.Count, Value: t.Sum / float64(t.Count)})
+		}
+
+		sort.Slice(days[i], func(a, b int) bool { return days[i][a].Date < days[i][b].Date })
+	}
+
+	return days, nil
+}
+
+// Adds the moving averages to the days of a field and computes the slope
+// of their values and, with a target, the date the trend reaches it.
+// The exponential moving average skips days without readings; the simple
+// moving average is the mean of the days with readings in the window.
+func fieldTrend(days []payload.TrendDay, window int, alpha float64, target *float64) payload.FieldTrend {
+	res := payload.FieldTrend{Days: days, Target: target}
+	if len(days) == 0 {
+		return res
+	}
+
+	numbers := make([]float64, len(days))
+	for i, d := range days {
+		numbers[i] = float64(dayNumber(d.Date))
+	}
+
+	ema := days[0].Value
+	start, sum := 0, 0.0
+	var sumX, sumY, sumXY, sumXX float64
+
+	for i := range days {
+		v := days[i].Value
+
+		sum += v
+		for numbers[start] <= numbers[i]-float64(window) {
+			sum -= days[start].Value
+			start++
+		}
+
+		ema += alpha * (v - ema)
+
+		x := numbers[i] - numbers[0]
+		sumX += x
+		sumY += v
+		sumXY += x * v
+		sumXX += x * x
+
+		days[i].Value = roundStat(v)
+		days[i].MovingAverage = roundStat(sum / float64(i-start+1))
+		days[i].ExponentialMovingAverage = roundStat(ema)
+	}
+
+	if len(days) < 2 {
+		return res
+	}
+
+	n := float64(len(days))
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	perWeek := roundStat(slope * 7)
+	res.SlopePerWeek = &perWeek
+
+	if target == nil {
+		return res
+	}
+
+	// Projected from the unrounded trend at the current slope per day.
+	remaining := 0.0
+	if diff := *target - ema; diff != 0 {
+		remaining = diff / slope
+		if slope == 0 || remaining < 0 || remaining > maxTargetDays {
+			return res
+		}
+	}
+
+	last, _ := time.Parse(time.DateOnly, days[len(days)-1].Date)
+	date := last.AddDate(0, 0, int(math.Ceil(remaining))).Format(time.DateOnly)
+	res.TargetDate = &date
+
+	return res
+}
+
+// Returns the number of days since the Unix epoch of a YYYY-MM-DD date.
+func dayNumber(date string) int64 {
+	t, _ := time.Parse(time.DateOnly, date)
+	return t.Unix() / (24 * 60 * 60)
+}
@@ -4,8 +4,10 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
 )
 
 // GET /users
@@ -14,17 +16,45 @@ import (
 // Swagger Doc
 // @Summary Get all users
 // @Schemes
-// @Description Get all users
+// @Description Get all users, ordered by id, in pages of limit users.
 // @Tags Users
 // @Accept json
 // @Produce json
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
 // @Success 200 {array} payload.UserResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users [get]
 // @Security Bearer
 func GetUsers(c *gin.Context) {
+	p, err := parsePage(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	query := models.DB.Order("id").Limit(p.Limit + 1)
+	if p.Cursor != "" {
+		id, err := parseIdCursor(p.Cursor)
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		query = query.Where("id > ?", id)
+	}
+
 	var userList []models.User
-	models.DB.Find(&userList)
+	if err := query.Find(&userList).Error; err != nil {
+		apierror.Abort(c, apierror.Database("User", err))
+		return
+	}
+
+	userList = setNextPage(c, p, userList, func(u models.User) string {
+		return idCursor(u.ID)
+	})
 
 	c.JSON(http.StatusOK, Map(userList, payload.MapUserResponse))
 }
@@ -41,13 +71,64 @@ func GetUsers(c *gin.Context) {
 // @Produce json
 // @Param id path int true "User ID"
 // @Success 200 {object} payload.UserResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id} [get]
 // @Security Bearer
 func GetUserById(c *gin.Context) {
 	var user models.User
 	if err := models.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "User not found"})
+		apierror.Abort(c, apierror.Database("User", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, payload.MapUserResponse(user))
+}
+
+// PUT /users/:id
+// Update the profile of a user
+//
+// Swagger Doc
+// @Summary Update the profile of a user
+// @Schemes
+// @Description Update the name, height, preferred units and time zone of a user. Fields that are left out keep their value; empty units and time zones reset them. The height is used for derived metrics such as the BMI. Users can only update their own profile.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body payload.UserRequest true "User Profile"
+// @Success 200 {object} payload.UserResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 403 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id} [put]
+// @Security Bearer
+func PutUserById(c *gin.Context) {
+	var r payload.UserRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		apierror.Abort(c, apierror.Validation(err))
+		return
+	}
+
+	if err := validation.User(r).Err(); err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	user, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	if !checkSelf(c, user.ID, "Users can only update their own profile") {
+		return
+	}
+
+	payload.ApplyUserRequest(&user, r)
+
+	if err := models.DB.Save(&user).Error; err != nil {
+		apierror.Abort(c, apierror.Database("User", err))
 		return
 	}
 
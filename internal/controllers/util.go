@@ -1,5 +1,17 @@
 package controllers
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
 // Map converts an array of one type to another.
 func Map[T, U any](data []T, f func(T) U) []U {
 	res := make([]U, 0, len(data))
@@ -10,3 +22,274 @@ func Map[T, U any](data []T, f func(T) U) []U {
 
 	return res
 }
+
+// Models of the readings by path name, e.g. "blood-pressure", including
+// journal entries and the values of custom metrics as "metrics". Journal
+// entries link to these readings and attachments are added to them.
+var readingModels = map[string]func() any{
+	"blood-pressure":   func() any { return &models.BloodPressure{} },
+	"weight":           func() any { return &models.Weight{} },
+	"water":            func() any { return &models.WaterIntake{} },
+	"sugar":            func() any { return &models.SugarIntake{} },
+	"heart-rate":       func() any { return &models.HeartRate{} },
+	"glucose":          func() any { return &models.BloodGlucose{} },
+	"sleep":            func() any { return &models.SleepSession{} },
+	"activities":       func() any { return &models.Activity{} },
+	"temperature":      func() any { return &models.BodyTemperature{} },
+	"spo2":             func() any { return &models.OxygenSaturation{} },
+	"doses":            func() any { return &models.Dose{} },
+	"body-composition": func() any { return &models.BodyComposition{} },
+	"measurements":     func() any { return &models.BodyMeasurement{} },
+	"nutrition":        func() any { return &models.NutritionEntry{} },
+	"journal":          func() any { return &models.JournalEntry{} },
+	"metrics":          func() any { return &models.MetricValue{} },
+}
+
+// vitalSource is implemented by every VitalResource.
+type vitalSource interface {
+	timelineSource
+	statistics() statsSpec
+}
+
+// The resources of the vitals by path name. A new vital is added to the
+// timeline and gets statistics by adding its resource here.
+var vitalResources = map[string]vitalSource{
+	"blood-pressure":   bloodPressureResource,
+	"weight":           weightResource,
+	"water":            waterIntakeResource,
+	"sugar":            sugarIntakeResource,
+	"heart-rate":       heartRateResource,
+	"glucose":          bloodGlucoseResource,
+	"sleep":            sleepSessionResource,
+	"activities":       activityResource,
+	"temperature":      bodyTemperatureResource,
+	"spo2":             oxygenSaturationResource,
+	"doses":            doseResource,
+	"body-composition": bodyCompositionResource,
+	"measurements":     bodyMeasurementResource,
+	"nutrition":        nutritionResource,
+	"journal":          journalResource,
+}
+
+// The list, get, create, update and delete handlers of a vital. They
+// call the VitalResource of the vital and carry its Swagger docs.
+type vitalHandlers struct {
+	list, get, create, update, delete gin.HandlerFunc
+}
+
+// The handlers of the vitals by path name. A new vital gets its routes by
+// adding its handlers here.
+var vitalRoutes = map[string]vitalHandlers{
+	"blood-pressure":   {GetBloodPressureByUserId, GetBloodPressureById, PostBloodPressureByUserId, PutBloodPressureByUserId, DeleteBloodPressureByUserId},
+	"weight":           {GetWeightByUserId, GetWeightById, PostWeightByUserId, PutWeightByUserId, DeleteWeightByUserId},
+	"water":            {GetWaterIntakeByUserId, GetWaterIntakeById, PostWaterIntakeByUserId, PutWaterIntakeByUserId, DeleteWaterIntakeByUserId},
+	"sugar":            {GetSugarIntakeByUserId, GetSugarIntakeById, PostSugarIntakeByUserId, PutSugarIntakeByUserId, DeleteSugarIntakeByUserId},
+	"heart-rate":       {GetHeartRateByUserId, GetHeartRateById, PostHeartRateByUserId, PutHeartRateByUserId, DeleteHeartRateByUserId},
+	"glucose":          {GetBloodGlucoseByUserId, GetBloodGlucoseById, PostBloodGlucoseByUserId, PutBloodGlucoseByUserId, DeleteBloodGlucoseByUserId},
+	"sleep":            {GetSleepSessionByUserId, GetSleepSessionById, PostSleepSessionByUserId, PutSleepSessionByUserId, DeleteSleepSessionByUserId},
+	"activities":       {GetActivityByUserId, GetActivityById, PostActivityByUserId, PutActivityByUserId, DeleteActivityByUserId},
+	"temperature":      {GetBodyTemperatureByUserId, GetBodyTemperatureById, PostBodyTemperatureByUserId, PutBodyTemperatureByUserId, DeleteBodyTemperatureByUserId},
+	"spo2":             {GetOxygenSaturationByUserId, GetOxygenSaturationById, PostOxygenSaturationByUserId, PutOxygenSaturationByUserId, DeleteOxygenSaturationByUserId},
+	"doses":            {GetDoseByUserId, GetDoseById, PostDoseByUserId, PutDoseByUserId, DeleteDoseByUserId},
+	"body-composition": {GetBodyCompositionByUserId, GetBodyCompositionById, PostBodyCompositionByUserId, PutBodyCompositionByUserId, DeleteBodyCompositionByUserId},
+	"measurements":     {GetBodyMeasurementByUserId, GetBodyMeasurementById, PostBodyMeasurementByUserId, PutBodyMeasurementByUserId, DeleteBodyMeasurementByUserId},
+	"nutrition":        {GetNutritionByUserId, GetNutritionById, PostNutritionByUserId, PutNutritionByUserId, DeleteNutritionByUserId},
+	"journal":          {GetJournalByUserId, GetJournalById, PostJournalByUserId, PutJournalByUserId, DeleteJournalByUserId},
+}
+
+// RegisterVitals adds the list, get, create, update and delete routes of
+// every vital to the group, below /users/:id/ and the path name of the
+// vital, e.g. "blood-pressure".
+func RegisterVitals(group gin.IRoutes) {
+	vitals := make([]string, 0, len(vitalRoutes))
+	for vital := range vitalRoutes {
+		vitals = append(vitals, vital)
+	}
+	sort.Strings(vitals)
+
+	for _, vital := range vitals {
+		h := vitalRoutes[vital]
+		group.GET("/users/:id/"+vital, h.list)
+		group.GET("/users/:id/"+vital+"/:recordId", h.get)
+		group.POST("/users/:id/"+vital, h.create)
+		group.PUT("/users/:id/"+vital+"/:recordId", h.update)
+		group.DELETE("/users/:id/"+vital+"/:recordId", h.delete)
+	}
+}
+
+// Finds the user identified by the :id path parameter. On failure a
+// problem details response is written and false is returned.
+func findUser(c *gin.Context) (models.User, bool) {
+	var u models.User
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid user id"))
+		return u, false
+	}
+
+	if err := models.DB.Where("id = ?", id).First(&u).Error; err != nil {
+		apierror.Abort(c, apierror.Database("User", err))
+		return u, false
+	}
+
+	return u, true
+}
+
+// Checks that the user identified by the :id path parameter is the user
+// of the token. On failure a 403 problem details response with the detail
+// is written and false is returned.
+func checkSelf(c *gin.Context, userId uint, detail string) bool {
+	// JwtAuth sets the id of the authenticated user.
+	if userId != c.GetUint("id") {
+		apierror.Abort(c, apierror.Forbidden(detail))
+		return false
+	}
+
+	return true
+}
+
+// Returns the height of the user identified by the :id path parameter, or
+// nil if the user has no height or does not exist.
+func userHeight(c *gin.Context) *float64 {
+	var u models.User
+	if err := models.DB.Select("height_cm").Where("id = ?", c.Param("id")).First(&u).Error; err != nil {
+		return nil
+	}
+
+	return u.HeightCm
+}
+
+// Returns the time zone of the user with the given id, or nil if the user
+// has not set one or does not exist.
+func userTimeZone(id any) *time.Location {
+	var u models.User
+	if err := models.DB.Select("time_zone").Where("id = ?", id).First(&u).Error; err != nil || u.TimeZone == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(u.TimeZone)
+	if err != nil {
+		return nil
+	}
+
+	return loc
+}
+
+// Returns the time zone the days of the user identified by the :id path
+// parameter are counted in: their own time zone, or UTC if they have not
+// set one.
+func userLocation(c *gin.Context) *time.Location {
+	if loc := userTimeZone(c.Param("id")); loc != nil {
+		return loc
+	}
+
+	return time.UTC
+}
+
+// Parses the from and to query parameters as RFC 3339 timestamps or
+// YYYY-MM-DD dates. Dates are days in the time zone of the user and the
+// range is returned in that time zone, so callers can bucket readings by
+// the user's local days. A date in to includes the whole day. If from is
+// missing it defaults to defaultDays before to, and to defaults to now.
+// On failure a problem details response is written and false is returned.
+func parseTimeRange(c *gin.Context, defaultDays int) (time.Time, time.Time, bool) {
+	from, to, err := queryTimeRange(c, defaultDays)
+	if err != nil {
+		apierror.Abort(c, err)
+		return from, to, false
+	}
+
+	return from, to, true
+}
+
+// The longest range the summaries per day return, like the periods of the
+// stats endpoint.
+const maxRangeDays = maxStatsPeriods
+
+// Parses the from and to query parameters like parseTimeRange and rejects
+// ranges of more than maxRangeDays days, for endpoints that return or
+// compute something for every day of the range.
+func parseDayRange(c *gin.Context, defaultDays int) (time.Time, time.Time, bool) {
+	from, to, ok := parseTimeRange(c, defaultDays)
+	if ok && from.AddDate(0, 0, maxRangeDays).Before(to) {
+		apierror.Abort(c, apierror.Invalid("from", "range", fmt.Sprintf("must not be more than %d days before to", maxRangeDays)))
+		return from, to, false
+	}
+
+	return from, to, ok
+}
+
+// Parses the from and to query parameters like parseTimeRange, returning
+// a validation error instead of writing it.
+func queryTimeRange(c *gin.Context, defaultDays int) (time.Time, time.Time, error) {
+	loc := userLocation(c)
+
+	to := time.Now().In(loc)
+	if s := c.Query("to"); s != "" {
+		t, dateOnly, err := parseTime(s, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, apierror.Invalid("to", "format", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t
+	}
+
+	from := to.AddDate(0, 0, -defaultDays)
+	if s := c.Query("from"); s != "" {
+		t, _, err := parseTime(s, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, apierror.Invalid("from", "format", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, apierror.Invalid("from", "ltfield", "must be before to")
+	}
+
+	return from, to, nil
+}
+
+// Parses an RFC 3339 timestamp or a YYYY-MM-DD date in loc and reports
+// whether the value was a date. The time is returned in loc.
+func parseTime(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	return t.In(loc), false, err
+}
+
+// Returns the unit query parameter, or the unit the user identified by
+// the :id path parameter prefers in the column preference. An empty
+// string means each reading keeps the unit it was submitted in.
+func displayUnit(c *gin.Context, units map[string]float64, preference string) (string, error) {
+	if unit := c.Query("unit"); unit != "" {
+		if _, ok := units[unit]; !ok {
+			return "", apierror.Invalid("unit", "oneof", "must be one of: "+strings.Join(unitNames(units), " "))
+		}
+		return unit, nil
+	}
+
+	var prefs []string
+	if err := models.DB.Model(&models.User{}).Where("id = ?", c.Param("id")).Pluck(preference, &prefs).Error; err != nil || len(prefs) == 0 {
+		return "", nil
+	}
+
+	return prefs[0], nil
+}
+
+// Returns the names of units sorted from the smallest to the largest.
+func unitNames(units map[string]float64) []string {
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool { return units[names[i]] < units[names[j]] })
+	return names
+}
@@ -0,0 +1,609 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
+	"gorm.io/gorm"
+)
+
+// vitalModel is satisfied by a pointer to any model that embeds
+// models.Reading.
+type vitalModel[M any] interface {
+	*M
+	GetReading() *models.Reading
+}
+
+// warner is implemented by response payloads that embed payload.VitalWarnings.
+type warner interface {
+	SetWarnings([]payload.FieldError)
+}
+
+// contextSetter is implemented by response payloads that embed
+// payload.ReadingContextResponse.
+type contextSetter interface {
+	SetReadingContext(models.Reading)
+}
+
+// Filter narrows the records returned by List using the query parameters
+// of the request. It returns an error if the query parameters are invalid.
+type Filter func(*gin.Context, *gorm.DB) (*gorm.DB, error)
+
+// Presenter returns a function that adjusts responses for the current
+// request, e.g. converting values to a unit given in the query string.
+// It returns an error if the query parameters are invalid.
+type Presenter[Resp any] func(*gin.Context) (func(*Resp), error)
+
+// VitalResource implements the list, get, create, update and delete
+// handlers shared by every vital type. A vital is declared once with
+// NewVitalResource and the per-type controller only adds Swagger docs.
+type VitalResource[M any, PM vitalModel[M], Req payload.VitalRequest, Resp any] struct {
+	// Name is used in error messages, e.g. "Blood Pressure".
+	Name string
+
+	// Apply copies the values of a request onto a model.
+	Apply func(PM, Req)
+
+	// Response maps a model to its response payload.
+	Response func(M) Resp
+
+	// Validate checks the request values beyond what the binding tags can
+	// express. The reading time is checked for every vital.
+	Validate func(Req) validation.Result
+
+	// Present adjusts responses for the current request. Optional.
+	Present Presenter[Resp]
+
+	// BeforeSave is called before a record is created or updated, e.g. to
+	// check it against the user's other records. It runs in the
+	// transaction that saves the record. Optional.
+	BeforeSave func(tx *gorm.DB, record PM) error
+
+	// BeforeDelete is called before a record is deleted, e.g. to delete its
+	// attachments. It runs in the transaction that deletes the record.
+	// Optional.
+	BeforeDelete func(tx *gorm.DB, record PM) error
+
+	// Filter narrows the records returned by List. Optional.
+	Filter Filter
+
+	// Fields are the columns List can be filtered on with query
+	// parameters.
+	Fields []QueryField
+
+	// Params are the query parameters read by the presenter or filter.
+	// List rejects any other parameter it does not know.
+	Params []string
+
+	// Stats names the number fields summarized per period by the stats
+	// endpoint of the vital.
+	Stats []string
+
+	// Totals adds the sum of each period to the statistics, e.g. for
+	// intakes.
+	Totals bool
+
+	// StatsUnit returns the unit of the statistics of a request, for
+	// vitals whose values can be converted. Optional.
+	StatsUnit func(*gin.Context) (statsUnit, error)
+
+	// Scope restricts every query of the resource, e.g. to the values of
+	// one custom metric. Optional.
+	Scope func(*gorm.DB) *gorm.DB
+
+	// Preload lists the associations loaded with each record.
+	Preload []string
+}
+
+// NewVitalResource creates a VitalResource. The model, request and
+// response types are inferred from apply and response.
+func NewVitalResource[M any, PM vitalModel[M], Req payload.VitalRequest, Resp any](name string, apply func(PM, Req), response func(M) Resp, validate func(Req) validation.Result) *VitalResource[M, PM, Req, Resp] {
+	return &VitalResource[M, PM, Req, Resp]{Name: name, Apply: apply, Response: response, Validate: validate}
+}
+
+// WithPresenter sets the presenter of the resource and returns it.
+func (v *VitalResource[M, PM, Req, Resp]) WithPresenter(p Presenter[Resp]) *VitalResource[M, PM, Req, Resp] {
+	v.Present = p
+	return v
+}
+
+// WithBeforeSave sets the before save hook of the resource and returns it.
+func (v *VitalResource[M, PM, Req, Resp]) WithBeforeSave(f func(tx *gorm.DB, record PM) error) *VitalResource[M, PM, Req, Resp] {
+	v.BeforeSave = f
+	return v
+}
+
+// WithBeforeDelete adds a before delete hook to the resource and returns
+// it. Hooks run in the order they are added, until one fails.
+func (v *VitalResource[M, PM, Req, Resp]) WithBeforeDelete(f func(tx *gorm.DB, record PM) error) *VitalResource[M, PM, Req, Resp] {
+	before := v.BeforeDelete
+	if before == nil {
+		v.BeforeDelete = f
+		return v
+	}
+
+	v.BeforeDelete = func(tx *gorm.DB, record PM) error {
+		if err := before(tx, record); err != nil {
+			return err
+		}
+		return f(tx, record)
+	}
+	return v
+}
+
+// WithFilter sets the list filter of the resource and returns it.
+func (v *VitalResource[M, PM, Req, Resp]) WithFilter(f Filter) *VitalResource[M, PM, Req, Resp] {
+	v.Filter = f
+	return v
+}
+
+// WithFields sets the columns List can be filtered on and returns the
+// resource.
+func (v *VitalResource[M, PM, Req, Resp]) WithFields(fields ...QueryField) *VitalResource[M, PM, Req, Resp] {
+	v.Fields = fields
+	return v
+}
+
+// WithParams sets the query parameters read by the presenter or filter and
+// returns the resource.
+func (v *VitalResource[M, PM, Req, Resp]) WithParams(params ...string) *VitalResource[M, PM, Req, Resp] {
+	v.Params = params
+	return v
+}
+
+// WithStats sets the fields summarized per period and returns the
+// resource. The fields must be number fields of the resource.
+func (v *VitalResource[M, PM, Req, Resp]) WithStats(fields ...string) *VitalResource[M, PM, Req, Resp] {
+	v.Stats = fields
+	return v
+}
+
+// WithTotals adds the sum of each period to the statistics and returns the
+// resource.
+func (v *VitalResource[M, PM, Req, Resp]) WithTotals() *VitalResource[M, PM, Req, Resp] {
+	v.Totals = true
+	return v
+}
+
+// WithStatsUnit sets the function that returns the unit of the statistics
+// and returns the resource.
+func (v *VitalResource[M, PM, Req, Resp]) WithStatsUnit(unit func(*gin.Context) (statsUnit, error)) *VitalResource[M, PM, Req, Resp] {
+	v.StatsUnit = unit
+	return v
+}
+
+// WithScope sets the scope of the resource and returns it.
+func (v *VitalResource[M, PM, Req, Resp]) WithScope(scope func(*gorm.DB) *gorm.DB) *VitalResource[M, PM, Req, Resp] {
+	v.Scope = scope
+	return v
+}
+
+// WithPreload sets the associations loaded with each record and returns
+// the resource.
+func (v *VitalResource[M, PM, Req, Resp]) WithPreload(associations ...string) *VitalResource[M, PM, Req, Resp] {
+	v.Preload = associations
+	return v
+}
+
+// List handles GET /users/:id/{vital}
+// Readings can be filtered by time with from and to, by the fields of the
+// resource and, for vitals with a reading context, by one or more tag
+// parameters, of which a reading must have every tag to be listed, and by
+// source. Readings are listed newest
+// first unless sort is asc, in pages of the limit query parameter; the
+// cursor of the next page is returned in the X-Next-Cursor and Link
+// headers. The fields parameter selects the JSON fields of each reading.
+func (v *VitalResource[M, PM, Req, Resp]) List(c *gin.Context) {
+	present, ok := v.presenter(c)
+	if !ok {
+		return
+	}
+
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	_, context := any(*new(Req)).(payload.ReadingContextual)
+	q, err := parseListQuery(c, listQuerySpec{
+		Fields:   v.Fields,
+		Params:   v.Params,
+		Context:  context,
+		Response: reflect.TypeOf(*new(Resp)),
+	})
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	query := q.Apply(v.query().Where("user_id = ?", u.ID))
+
+	if v.Filter != nil {
+		if query, err = v.Filter(c, query); err != nil {
+			apierror.Abort(c, apierror.Validation(err))
+			return
+		}
+	}
+
+	order, before := "time DESC, id DESC", "<"
+	if q.Ascending {
+		order, before = "time, id", ">"
+	}
+
+	if p.Cursor != "" {
+		t, id, err := parseTimeCursor(p.Cursor)
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		t = t.UTC()
+		query = query.Where("(time "+before+" ? OR (time = ? AND id "+before+" ?))", t, t, id)
+	}
+
+	var records []M
+	if err := query.Order(order).Limit(p.Limit + 1).Find(&records).Error; err != nil {
+		apierror.Abort(c, apierror.Database(v.Name, err))
+		return
+	}
+
+	records = setNextPage(c, p, records, func(record M) string {
+		return timeCursor(PM(&record).GetReading().Time, recordID(record))
+	})
+
+	res := Map(records, func(record M) Resp {
+		return v.respond(record, nil, present)
+	})
+
+	if q.Fields == nil {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	selected, err := selectFields(res, q.Fields)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, selected)
+}
+
+// timelineItem is a timeline entry with the time of its reading as
+// loaded from the database, from which the cursor of a page is made.
+type timelineItem struct {
+	Entry payload.TimelineEntry
+	Time  time.Time
+}
+
+// Returns up to limit readings within scope as timeline entries of
+// the type vital, ordered by order.
+func (v *VitalResource[M, PM, Req, Resp]) timeline(c *gin.Context, vital string, scope func(*gorm.DB) *gorm.DB, order string, limit int) ([]timelineItem, error) {
+	var present func(*Resp)
+	if v.Present != nil {
+		var err error
+		if present, err = v.Present(c); err != nil {
+			return nil, apierror.Validation(err)
+		}
+	}
+
+	var records []M
+	if err := v.query().Scopes(scope).Order(order).Limit(limit).Find(&records).Error; err != nil {
+		return nil, apierror.Database(v.Name, err)
+	}
+
+	return Map(records, func(record M) timelineItem {
+		reading := PM(&record).GetReading()
+		return timelineItem{
+			Entry: payload.TimelineEntry{Type: vital, Id: recordID(record), Time: reading.LocalTime(), Data: v.respond(record, nil, present)},
+			Time:  reading.Time,
+		}
+	}), nil
+}
+
+// Returns what the stats endpoint of the vital summarizes.
+func (v *VitalResource[M, PM, Req, Resp]) statistics() statsSpec {
+	spec := statsSpec{Name: v.Name, Model: new(M), Scope: v.Scope, Totals: v.Totals, Unit: v.StatsUnit}
+
+	for _, name := range v.Stats {
+		for _, f := range v.Fields {
+			if f.Name == name && !f.Text {
+				spec.Fields = append(spec.Fields, f)
+			}
+		}
+	}
+
+	return spec
+}
+
+// Get handles GET /users/:id/{vital}/:recordId
+func (v *VitalResource[M, PM, Req, Resp]) Get(c *gin.Context) {
+	present, ok := v.presenter(c)
+	if !ok {
+		return
+	}
+
+	record, ok := v.find(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, v.respond(*record, nil, present))
+}
+
+// Create handles POST /users/:id/{vital}
+func (v *VitalResource[M, PM, Req, Resp]) Create(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid user id"))
+		return
+	}
+
+	present, ok := v.presenter(c)
+	if !ok {
+		return
+	}
+
+	r, warnings, ok := v.bind(c)
+	if !ok {
+		return
+	}
+
+	createTime := time.Now().In(userLocation(c))
+	if !r.ReadingTime().IsZero() {
+		createTime = r.ReadingTime()
+	}
+
+	var record M
+	v.Apply(&record, r)
+
+	reading := PM(&record).GetReading()
+	reading.UserID = uint(id)
+	reading.SetTime(createTime)
+	applyContext(reading, r)
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := runHook(tx, v.BeforeSave, &record); err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		v.abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, v.respond(record, warnings, present))
+}
+
+// Update handles PUT /users/:id/{vital}/:recordId
+// The existing time is kept if the request does not provide one.
+func (v *VitalResource[M, PM, Req, Resp]) Update(c *gin.Context) {
+	present, ok := v.presenter(c)
+	if !ok {
+		return
+	}
+
+	r, warnings, ok := v.bind(c)
+	if !ok {
+		return
+	}
+
+	record, ok := v.find(c)
+	if !ok {
+		return
+	}
+
+	v.Apply(record, r)
+	applyContext(record.GetReading(), r)
+
+	if !r.ReadingTime().IsZero() {
+		record.GetReading().SetTime(r.ReadingTime())
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := runHook(tx, v.BeforeSave, record); err != nil {
+			return err
+		}
+		return tx.Save(record).Error
+	})
+	if err != nil {
+		v.abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, v.respond(*record, warnings, present))
+}
+
+// Delete handles DELETE /users/:id/{vital}/:recordId
+func (v *VitalResource[M, PM, Req, Resp]) Delete(c *gin.Context) {
+	present, ok := v.presenter(c)
+	if !ok {
+		return
+	}
+
+	record, ok := v.find(c)
+	if !ok {
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := runHook(tx, v.BeforeDelete, record); err != nil {
+			return err
+		}
+		return tx.Delete(record).Error
+	})
+	if err != nil {
+		v.abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, v.respond(*record, nil, present))
+}
+
+// Binds and validates the request body and returns any warnings about
+// unusual values. On failure a problem details response is written and
+// false is returned.
+func (v *VitalResource[M, PM, Req, Resp]) bind(c *gin.Context) (Req, []payload.FieldError, bool) {
+	var r Req
+	if err := c.ShouldBindJSON(&r); err != nil {
+		apierror.Abort(c, apierror.Validation(err))
+		return r, nil, false
+	}
+
+	field := "time"
+	if f, ok := any(r).(payload.ReadingTimeFielder); ok {
+		field = f.ReadingTimeField()
+	}
+
+	result := validation.Time(field, r.ReadingTime())
+	if rc, ok := any(r).(payload.ReadingContextual); ok {
+		result.Merge(validation.ReadingContext(rc.GetReadingContext()))
+	}
+
+	if v.Validate != nil {
+		result.Merge(v.Validate(r))
+	}
+
+	if err := result.Err(); err != nil {
+		apierror.Abort(c, err)
+		return r, nil, false
+	}
+
+	return r, result.Warnings, true
+}
+
+// Copies the note, tags and source of a request onto a reading if the
+// request has them.
+func applyContext(reading *models.Reading, r payload.VitalRequest) {
+	if rc, ok := r.(payload.ReadingContextual); ok {
+		ctx := rc.GetReadingContext()
+		reading.Note = ctx.Note
+		reading.SetTags(ctx.Tags)
+		reading.Source = ctx.Source
+	}
+}
+
+// Runs a before save or before delete hook in tx if it is set.
+func runHook[PM any](tx *gorm.DB, hook func(*gorm.DB, PM) error, record PM) error {
+	if hook == nil {
+		return nil
+	}
+
+	return hook(tx, record)
+}
+
+// Writes the problem details response of an error of a hook or of saving
+// or deleting a record. Errors that are not problem details are database
+// errors.
+func (v *VitalResource[M, PM, Req, Resp]) abort(c *gin.Context, err error) {
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = apierror.Database(v.Name, err)
+	}
+
+	apierror.Abort(c, apiErr)
+}
+
+// Returns the presentation function for the request, or nil if the
+// resource has no presenter. On failure a problem details response is
+// written and false is returned.
+func (v *VitalResource[M, PM, Req, Resp]) presenter(c *gin.Context) (func(*Resp), bool) {
+	if v.Present == nil {
+		return nil, true
+	}
+
+	present, err := v.Present(c)
+	if err != nil {
+		apierror.Abort(c, apierror.Validation(err))
+		return nil, false
+	}
+
+	return present, true
+}
+
+// Maps a record to its response in the UTC offset it was submitted with,
+// adding the note, tags and warnings if the response type supports them
+// and applying the presentation function if there is one.
+func (v *VitalResource[M, PM, Req, Resp]) respond(record M, warnings []payload.FieldError, present func(*Resp)) Resp {
+	reading := PM(&record).GetReading()
+	reading.Time = reading.LocalTime()
+
+	resp := v.Response(record)
+
+	if s, ok := any(&resp).(contextSetter); ok {
+		s.SetReadingContext(*reading)
+	}
+
+	if w, ok := any(&resp).(warner); ok && len(warnings) > 0 {
+		w.SetWarnings(warnings)
+	}
+
+	if present != nil {
+		present(&resp)
+	}
+
+	return resp
+}
+
+// Returns a query within the scope of the resource that loads its
+// associations.
+func (v *VitalResource[M, PM, Req, Resp]) query() *gorm.DB {
+	db := models.DB
+	if v.Scope != nil {
+		db = db.Scopes(v.Scope)
+	}
+
+	for _, association := range v.Preload {
+		db = db.Preload(association)
+	}
+
+	return db
+}
+
+// Finds the record identified by the :id and :recordId path parameters.
+// On failure a problem details response is written and false is returned.
+func (v *VitalResource[M, PM, Req, Resp]) find(c *gin.Context) (PM, bool) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid user id"))
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("recordId"), 10, 32)
+
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid "+strings.ToLower(v.Name)+" id"))
+		return nil, false
+	}
+
+	var record M
+	if err := v.query().Where("id = ? AND user_id = ?", id, userId).First(&record).Error; err != nil {
+		apierror.Abort(c, apierror.Database(v.Name, err))
+		return nil, false
+	}
+
+	return &record, true
+}
+
+// Returns the primary key of a record. Every vital model embeds
+// gorm.Model, whose ID is promoted to the model.
+func recordID(record any) uint {
+	return uint(reflect.Indirect(reflect.ValueOf(record)).FieldByName("ID").Uint())
+}
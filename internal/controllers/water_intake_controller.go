@@ -1,44 +1,74 @@
 package controllers
 
 import (
-	"net/http"
-	"strconv"
-	"time"
-
 	"github.com/gin-gonic/gin"
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
 )
 
+var waterIntakeResource = NewVitalResource("Water Intake", payload.ApplyWaterIntakeRequest, payload.MapWaterIntakeResponse, validation.WaterIntake).
+	WithBeforeDelete(deleteAttachments[*models.WaterIntake]("water")).
+	WithPresenter(presentWaterIntake).
+	WithFields(
+		NumberField("milliliters", "milliliters"),
+	).
+	WithStats("milliliters").
+	WithStatsUnit(waterStatsUnit).
+	WithTotals().
+	WithParams("unit")
+
 // GET /users/:id/water
 // Get all water intake records for a user.
 //
 // Swagger Doc
 // @Summary Get all water intake records for a user.
 // @Schemes
-// @Description Get all water intake records for a user.
+// @Description Get all water intake records for a user. Filter on milliliters whatever the display unit, e.g. milliliters_gte=500, also with the _gt, _lt, _lte and _ne suffixes.
 // @Tags Water Intake
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param unit query string false "Unit to return amounts in. Defaults to the water unit of the user, or the unit each reading was submitted in." Enums(ml, l, fl oz, cups)
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
 // @Success 200 {array} payload.WaterIntakeResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id}/water [get]
 // @Security Bearer
 func GetWaterIntakeByUserId(c *gin.Context) {
-	userId := c.Param("id")
-	var wi []models.WaterIntake
-
-	var u models.User
-
-	if err := models.DB.Where("id = ?", c.Param("id")).First(&u).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "User not found"})
-		return
-	}
-
-	models.DB.Where("user_id = ?", userId).Order("time DESC").Find(&wi)
+	waterIntakeResource.List(c)
+}
 
-	c.JSON(http.StatusOK, Map(wi, payload.MapWaterIntakeResponse))
+// GET /users/:id/water/:recordId
+// Get a water intake record for user.
+//
+// Swagger Doc
+// @Summary Get a water intake record for user.
+// @Schemes
+// @Description Get a water intake record for user.
+// @Tags Water Intake
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit to return amounts in. Defaults to the water unit of the user, or the unit each reading was submitted in." Enums(ml, l, fl oz, cups)
+// @Param recordId path int true "Water Intake ID"
+// @Success 200 {object} payload.WaterIntakeResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/water/{recordId} [get]
+// @Security Bearer
+func GetWaterIntakeById(c *gin.Context) {
+	waterIntakeResource.Get(c)
 }
 
 // POST /users/:id/water
@@ -47,99 +77,48 @@ func GetWaterIntakeByUserId(c *gin.Context) {
 // Swagger Doc
 // @Summary Adds a new water intake record for user.
 // @Schemes
-// @Description Adds a new water intake record for user. Time is optional. If not provided, current time is used.
+// @Description Adds a new water intake record for user. Unit is one of ml, l, fl oz or cups and defaults to the server default water unit. The cups field is deprecated; use amount and unit instead. Time is optional. If not provided, current time is used. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
 // @Tags Water Intake
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param unit query string false "Unit to return amounts in. Defaults to the water unit of the user, or the unit each reading was submitted in." Enums(ml, l, fl oz, cups)
 // @Param user body payload.WaterIntakeRequest true "User Water Intake"
 // @Success 200 {object} payload.WaterIntakeResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id}/water [post]
 // @Security Bearer
 func PostWaterIntakeByUserId(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	// Validate r
-	var r payload.WaterIntakeRequest
-	if err := c.ShouldBindJSON(&r); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	createTime := time.Now()
-	if r.Time.IsZero() == false {
-		createTime = r.Time
-	}
-
-	// Create Water Intake Record
-	wi := models.WaterIntake{Cups: r.Cups, UserID: uint(id), Time: createTime}
-	models.DB.Create(&wi)
-
-	c.JSON(http.StatusOK, payload.MapWaterIntakeResponse(wi))
+	waterIntakeResource.Create(c)
 }
 
-// PUT /users/:userId/water/:id
+// PUT /users/:id/water/:recordId
 // Updates a water intake record for user.
 //
 // Swagger Doc
 // @Summary Updates a water intake record for user.
 // @Schemes
-// @Description Updates a water intake record for user. Time is optional.
+// @Description Updates a water intake record for user. Time is optional. If not provided, the existing time is kept. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
 // @Tags Water Intake
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Param id path int true "Water Intake ID"
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit to return amounts in. Defaults to the water unit of the user, or the unit each reading was submitted in." Enums(ml, l, fl oz, cups)
+// @Param recordId path int true "Water Intake ID"
 // @Param user body payload.WaterIntakeRequest true "User Water Intake"
 // @Success 200 {object} payload.WaterIntakeResponse
-// @Failure 404 {object} payload.ErrorResponse
-// @Router /users/{userId}/water/{id} [put]
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/water/{recordId} [put]
 // @Security Bearer
 func PutWaterIntakeByUserId(c *gin.Context) {
-	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid water intake id"})
-		return
-	}
-
-	// Validate r
-	var r payload.WaterIntakeRequest
-	if err := c.ShouldBindJSON(&r); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	// Find Water Intake Record
-	var wi models.WaterIntake
-	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&wi).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "Water Intake not found"})
-		return
-	}
-
-	// Update Water Intake Record
-	wi.Cups = r.Cups
-	wi.Time = r.Time
-
-	models.DB.Save(&wi)
-
-	c.JSON(http.StatusOK, payload.MapWaterIntakeResponse(wi))
+	waterIntakeResource.Update(c)
 }
 
-// DELETE /users/:userId/water/:id
+// DELETE /users/:id/water/:recordId
 // Deletes a water intake record for user.
 //
 // Swagger Doc
@@ -149,35 +128,41 @@ func PutWaterIntakeByUserId(c *gin.Context) {
 // @Tags Water Intake
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Param id path int true "Water Intake ID"
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit to return amounts in. Defaults to the water unit of the user, or the unit each reading was submitted in." Enums(ml, l, fl oz, cups)
+// @Param recordId path int true "Water Intake ID"
 // @Success 200 {object} payload.WaterIntakeResponse
-// @Failure 404 {object} payload.ErrorResponse
-// @Router /users/{userId}/water/{id} [delete]
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/water/{recordId} [delete]
 // @Security Bearer
 func DeleteWaterIntakeByUserId(c *gin.Context) {
-	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	waterIntakeResource.Delete(c)
+}
 
+// Returns the unit of water intake statistics: the unit query parameter,
+// the water unit preferred by the user or milliliters.
+func waterStatsUnit(c *gin.Context) (statsUnit, error) {
+	unit, err := displayUnit(c, models.MillilitersPer, "water_unit")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
+		return statsUnit{}, err
 	}
-
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid water id"})
-		return
+	if unit == "" {
+		unit = models.WaterMl
 	}
 
-	// Delete Water Intake Record
-	var wi models.WaterIntake
-	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&wi).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "Water Intake not found"})
-		return
-	}
+	return statsUnit{Name: unit, Scale: 1 / models.MillilitersPer[unit]}, nil
+}
 
-	models.DB.Delete(&wi)
+// Converts water intake responses to the unit in the unit query parameter
+// or the water unit preferred by the user.
+func presentWaterIntake(c *gin.Context) (func(*payload.WaterIntakeResponse), error) {
+	unit, err := displayUnit(c, models.MillilitersPer, "water_unit")
+	if err != nil || unit == "" {
+		return nil, err
+	}
 
-	c.JSON(http.StatusOK, payload.MapWaterIntakeResponse(wi))
+	return func(r *payload.WaterIntakeResponse) {
+		r.ConvertTo(unit)
+	}, nil
 }
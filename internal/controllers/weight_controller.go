@@ -1,44 +1,74 @@
 package controllers
 
 import (
-	"net/http"
-	"strconv"
-	"time"
-
 	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
 )
 
+var weightResource = NewVitalResource("Weight", payload.ApplyWeightRequest, payload.MapWeightResponse, validation.Weight).
+	WithBeforeDelete(deleteAttachments[*models.Weight]("weight")).
+	WithPresenter(presentWeight).
+	WithFields(
+		NumberField("kilograms", "kilograms"),
+	).
+	WithStats("kilograms").
+	WithStatsUnit(weightStatsUnit).
+	WithParams("unit")
+
 // GET /users/:id/weight
 // Get all weight records for a user.
 //
 // Swagger Doc
 // @Summary Get all weight records for a user.
 // @Schemes
-// @Description Get all weight records for a user.
+// @Description Get all weight records for a user. Filter on kilograms whatever the display unit, e.g. kilograms_lt=80, also with the _gt, _gte, _lte and _ne suffixes.
 // @Tags Weight
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param unit query string false "Unit to return weights in. Defaults to the weight unit of the user, or the unit each reading was submitted in." Enums(kg, lb, st)
+// @Param limit query int false "Page size" default(100) minimum(1) maximum(500)
+// @Param cursor query string false "Cursor of the next page, from the X-Next-Cursor header of the previous page"
+// @Param from query string false "Only readings at or after this time, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Only readings before this time, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param tag query []string false "Only readings with all of these tags" collectionFormat(multi)
+// @Param source query []string false "Only readings from one of these sources, e.g. Apple Watch" collectionFormat(multi)
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated JSON fields to return, e.g. id,time"
 // @Success 200 {array} payload.WeightResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page. Missing on the last page."
+// @Header 200 {string} Link "URL of the next page with rel=next. Missing on the last page."
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id}/weight [get]
 // @Security Bearer
 func GetWeightByUserId(c *gin.Context) {
-	userId := c.Param("id")
-	var w []models.Weight
-
-	var u models.User
-
-	if err := models.DB.Where("id = ?", c.Param("id")).First(&u).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "User not found"})
-		return
-	}
-
-	models.DB.Where("user_id = ?", userId).Order("time DESC").Find(&w)
+	weightResource.List(c)
+}
 
-	c.JSON(http.StatusOK, Map(w, payload.MapWeightResponse))
+// GET /users/:id/weight/:recordId
+// Get a weight record for user.
+//
+// Swagger Doc
+// @Summary Get a weight record for user.
+// @Schemes
+// @Description Get a weight record for user.
+// @Tags Weight
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit to return weights in. Defaults to the weight unit of the user, or the unit each reading was submitted in." Enums(kg, lb, st)
+// @Param recordId path int true "Weight ID"
+// @Success 200 {object} payload.WeightResponse
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/weight/{recordId} [get]
+// @Security Bearer
+func GetWeightById(c *gin.Context) {
+	weightResource.Get(c)
 }
 
 // POST /users/:id/weight
@@ -47,99 +77,48 @@ func GetWeightByUserId(c *gin.Context) {
 // Swagger Doc
 // @Summary Adds a new weight record for user.
 // @Schemes
-// @Description Adds a new weight record for user. Time is optional. If not provided, current time is used.
+// @Description Adds a new weight record for user. Unit is one of kg, lb or st and defaults to the server default weight unit. Time is optional. If not provided, current time is used. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
 // @Tags Weight
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param unit query string false "Unit to return weights in. Defaults to the weight unit of the user, or the unit each reading was submitted in." Enums(kg, lb, st)
 // @Param user body payload.WeightRequest true "User Weight"
 // @Success 200 {object} payload.WeightResponse
-// @Failure 404 {object} payload.ErrorResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
 // @Router /users/{id}/weight [post]
 // @Security Bearer
 func PostWeightByUserId(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	// Validate Request
-	var request payload.WeightRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	createTime := time.Now()
-	if request.Time.IsZero() == false {
-		createTime = request.Time
-	}
-
-	// Create Weight
-	w := models.Weight{Weight: request.Weight, UserID: uint(id), Time: createTime}
-	models.DB.Create(&w)
-
-	c.JSON(http.StatusOK, payload.MapWeightResponse(w))
+	weightResource.Create(c)
 }
 
-// PUT /users/:userId/weight/:id
+// PUT /users/:id/weight/:recordId
 // Updates a weight record for user.
 //
 // Swagger Doc
 // @Summary Updates a weight record for user.
 // @Schemes
-// @Description Updates a weight record for user. Time is optional.
+// @Description Updates a weight record for user. Time is optional. If not provided, the existing time is kept. Values outside plausible limits are rejected; unusual values are saved and listed in warnings.
 // @Tags Weight
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Param id path int true "Weight ID"
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit to return weights in. Defaults to the weight unit of the user, or the unit each reading was submitted in." Enums(kg, lb, st)
+// @Param recordId path int true "Weight ID"
 // @Param user body payload.WeightRequest true "User Weight"
 // @Success 200 {object} payload.WeightResponse
-// @Failure 404 {object} payload.ErrorResponse
-// @Router /users/{userId}/weight/{id} [put]
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/weight/{recordId} [put]
 // @Security Bearer
 func PutWeightByUserId(c *gin.Context) {
-	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
-		return
-	}
-
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid weight id"})
-		return
-	}
-
-	// Validate Request
-	var req payload.WeightRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	// Find Weight
-	var w models.Weight
-	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&w).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "Weight not found"})
-		return
-	}
-
-	// Update Weight
-	w.Weight = req.Weight
-	w.Time = req.Time
-
-	models.DB.Save(&w)
-
-	c.JSON(http.StatusOK, payload.MapWeightResponse(w))
+	weightResource.Update(c)
 }
 
-// DELETE /users/:userId/weight/:id
+// DELETE /users/:id/weight/:recordId
 // Deletes a weight record for user.
 //
 // Swagger Doc
@@ -149,36 +128,84 @@ func PutWeightByUserId(c *gin.Context) {
 // @Tags Weight
 // @Accept json
 // @Produce json
-// @Param userId path int true "User ID"
-// @Param id path int true "Weight ID"
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit to return weights in. Defaults to the weight unit of the user, or the unit each reading was submitted in." Enums(kg, lb, st)
+// @Param recordId path int true "Weight ID"
 // @Success 200 {object} payload.WeightResponse
-// @Failure 404 {object} payload.ErrorResponse
-// @Router /users/{userId}/weight/{id} [delete]
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/weight/{recordId} [delete]
 // @Security Bearer
 func DeleteWeightByUserId(c *gin.Context) {
-	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	weightResource.Delete(c)
+}
 
+// GET /users/:id/weight/trend
+// Get the weight trend of a user.
+//
+// Swagger Doc
+// @Summary Get the weight trend of a user.
+// @Schemes
+// @Description Smooths the daily mean weight between from and to (default: the last 90 days) with a simple moving average over window days and an exponential moving average, and fits a linear regression line whose slope is the weight change per week. With a target weight it projects the date the exponential moving average reaches the target at that slope. Days are in the time zone of the user.
+// @Tags Weight
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param unit query string false "Unit of the weights and the target. Defaults to the weight unit of the user, or the default weight unit." Enums(kg, lb, st)
+// @Param from query string false "Start of the window, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "End of the window, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param window query int false "Days of the simple moving average" default(7) minimum(1) maximum(90)
+// @Param alpha query number false "Smoothing factor of the exponential moving average" default(0.1)
+// @Param target query number false "Target weight"
+// @Success 200 {object} payload.TrendResponse
+// @Failure 400 {object} payload.Problem
+// @Failure 401 {object} payload.Problem
+// @Failure 404 {object} payload.Problem
+// @Router /users/{id}/weight/trend [get]
+// @Security Bearer
+func GetWeightTrendByUserId(c *gin.Context) {
+	unit, err := displayUnit(c, models.KilogramsPer, "weight_unit")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid user id"})
+		apierror.Abort(c, err)
 		return
 	}
+	if unit == "" {
+		unit = models.DefaultWeightUnit
+	}
 
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	vitalTrend(c, trendSpec{
+		Name:        "Weight",
+		Model:       &models.Weight{},
+		Unit:        unit,
+		Fields:      []QueryField{NumberField("weight", "kilograms")},
+		Scale:       1 / models.KilogramsPer[unit],
+		TargetField: "weight",
+	})
+}
 
+// Returns the unit of weight statistics: the unit query parameter, the
+// weight unit preferred by the user or the default weight unit.
+func weightStatsUnit(c *gin.Context) (statsUnit, error) {
+	unit, err := displayUnit(c, models.KilogramsPer, "weight_unit")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, payload.ErrorResponse{Error: "Invalid weight id"})
-		return
+		return statsUnit{}, err
 	}
-
-	// Find Weight
-	var w models.Weight
-	if err := models.DB.Where("id = ? AND user_id = ?", id, userId).First(&w).Error; err != nil {
-		c.JSON(http.StatusNotFound, payload.ErrorResponse{Error: "Weight not found"})
-		return
+	if unit == "" {
+		unit = models.DefaultWeightUnit
 	}
 
-	// Delete Weight
-	models.DB.Delete(&w)
+	return statsUnit{Name: unit, Scale: 1 / models.KilogramsPer[unit]}, nil
+}
+
+// Converts weight responses to the unit in the unit query parameter or the
+// weight unit preferred by the user.
+func presentWeight(c *gin.Context) (func(*payload.WeightResponse), error) {
+	unit, err := displayUnit(c, models.KilogramsPer, "weight_unit")
+	if err != nil || unit == "" {
+		return nil, err
+	}
 
-	c.JSON(http.StatusOK, payload.MapWeightResponse(w))
+	return func(r *payload.WeightResponse) {
+		r.ConvertTo(unit)
+	}, nil
 }
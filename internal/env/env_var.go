@@ -49,6 +49,107 @@ func GetDatabaseConnectionInfo() (string, string, string, string) {
 	return host, user, password, dbname
 }
 
+// API Versioning Section
+
+// Get the date the unversioned root routes will be removed, defined in the
+// LEGACY_API_SUNSET environment variable as an RFC 3339 timestamp.
+// Defaults to 2027-04-19 if the variable is missing or invalid.
+func GetLegacyApiSunset() time.Time {
+	sunset, err := time.Parse(time.RFC3339, os.Getenv("LEGACY_API_SUNSET"))
+
+	if err != nil {
+		return time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+	}
+
+	return sunset
+}
+
+// Validation Section
+
+// Get the path of a JSON file that overrides the default plausibility
+// limits for vital readings, defined in the VALIDATION_RULES_FILE
+// environment variable. Returns an empty string if not set.
+func GetValidationRulesFile() string {
+	return os.Getenv("VALIDATION_RULES_FILE")
+}
+
+// Units Section
+
+// Get the unit of weights submitted without a unit, defined in the
+// DEFAULT_WEIGHT_UNIT environment variable. Defaults to "kg".
+func GetDefaultWeightUnit() string {
+	unit := os.Getenv("DEFAULT_WEIGHT_UNIT")
+
+	if unit == "" {
+		return "kg"
+	}
+
+	return unit
+}
+
+// Get the unit of water intakes submitted without a unit, defined in the
+// DEFAULT_WATER_UNIT environment variable. Defaults to "cups".
+func GetDefaultWaterUnit() string {
+	unit := os.Getenv("DEFAULT_WATER_UNIT")
+
+	if unit == "" {
+		return "cups"
+	}
+
+	return unit
+}
+
+// Attachment Section
+
+// Get the directory of the local blob store that keeps the files attached
+// to readings, defined in the BLOB_STORE_DIR environment variable.
+// Defaults to "./data/blobs".
+func GetBlobStoreDir() string {
+	dir := os.Getenv("BLOB_STORE_DIR")
+
+	if dir == "" {
+		return "./data/blobs"
+	}
+
+	return dir
+}
+
+// Get the maximum size of an attachment in bytes, defined in the
+// ATTACHMENT_MAX_BYTES environment variable. Defaults to 10 MiB if the
+// variable is missing or invalid.
+func GetAttachmentMaxBytes() int64 {
+	size, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64)
+
+	if err != nil || size <= 0 {
+		return 10 << 20
+	}
+
+	return size
+}
+
+var attachmentSigningKey string = ""
+
+// Get the key that signs the download URLs of attachments, defined in the
+// ATTACHMENT_SIGNING_KEY environment variable. It must differ from the
+// JWT key. A random key is generated if the variable is missing, which
+// invalidates the URLs handed out before a restart.
+func GetAttachmentSigningKey() string {
+	if attachmentSigningKey != "" {
+		return attachmentSigningKey
+	}
+
+	attachmentSigningKey = os.Getenv("ATTACHMENT_SIGNING_KEY")
+
+	if attachmentSigningKey == "" {
+		log.Print("ERROR: Unable to retrieve environment variable ATTACHMENT_SIGNING_KEY")
+		log.Print("Generating random key...")
+
+		attachmentSigningKey = util.RandString(32)
+	}
+
+	return attachmentSigningKey
+}
+
 // JWT Key Section
 
 var jwtKey string = ""
@@ -74,6 +175,8 @@ func GetJWTKey() string {
 
 var duration time.Duration
 
+// Get the token expiration duration in seconds. util.Issue converts the
+// value to a time.Duration by multiplying it by time.Second.
 func GetTokenExpirationDuration() time.Duration {
 	if duration != 0 {
 		return duration
@@ -87,7 +190,7 @@ func GetTokenExpirationDuration() time.Duration {
 		log.Print("ERROR: Unable to retrieve environment variable DURATION_SEC")
 		log.Print("Setting default token expiration duration of 6 hours")
 
-		return time.Hour * 6
+		return time.Duration(6 * 60 * 60)
 	}
 
 	return time.Duration(duration)
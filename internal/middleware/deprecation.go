@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks every response of a route group as deprecated.
+// It sets the Deprecation (RFC 9745) and Sunset (RFC 8594) headers and a
+// Link header pointing to the same path under the successor version prefix.
+func Deprecated(since time.Time, sunset time.Time, successor string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", since.Unix())
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+
+	return func(c *gin.Context) {
+		path := successor + "/" + strings.TrimPrefix(c.Request.URL.Path, "/")
+
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunsetDate)
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", path))
+
+		c.Next()
+	}
+}
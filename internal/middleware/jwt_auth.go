@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
 	"github.com/zenkimoto/vitals-server-api/internal/env"
 	"github.com/zenkimoto/vitals-server-api/internal/util"
 )
@@ -15,8 +16,7 @@ func JwtAuth() gin.HandlerFunc {
 
 		if header == "" {
 			log.Printf("Authorization header is missing.")
-			c.String(401, "Unauthorized")
-			c.Abort()
+			apierror.Abort(c, apierror.Unauthorized("Authorization header is missing"))
 			return
 		}
 
@@ -24,8 +24,7 @@ func JwtAuth() gin.HandlerFunc {
 			user, id, err := util.Parse(env.GetJWTKey(), ar[1])
 			if err != nil {
 				log.Print(err)
-				c.String(401, "Unauthorized")
-				c.Abort()
+				apierror.Abort(c, apierror.Unauthorized("Invalid or expired token"))
 				return
 			} else {
 				c.Set("user", user)
@@ -33,8 +32,7 @@ func JwtAuth() gin.HandlerFunc {
 			}
 		} else {
 			log.Print("Can not parse Authorization header.")
-			c.String(401, "Unauthorized")
-			c.Abort()
+			apierror.Abort(c, apierror.Unauthorized("Authorization header must use the Bearer scheme"))
 			return
 		}
 
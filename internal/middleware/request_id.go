@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/util"
+)
+
+// RequestId assigns every request an id, reusing the X-Request-Id header
+// sent by the client or a proxy if there is one. The id is returned in the
+// X-Request-Id response header and in problem details responses.
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Request.Header.Get(apierror.RequestIdHeader)
+
+		if id == "" || len(id) > 128 {
+			id = util.RandString(16)
+		}
+
+		c.Header(apierror.RequestIdHeader, id)
+
+		c.Next()
+	}
+}
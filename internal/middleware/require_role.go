@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+// RequireRole rejects requests from users without the given role. It must
+// run after JwtAuth, which sets the id of the authenticated user.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var u models.User
+
+		if err := models.DB.Where("id = ?", c.GetUint("id")).First(&u).Error; err != nil || u.Role != role {
+			apierror.Abort(c, apierror.Forbidden("This endpoint requires the "+role+" role"))
+			return
+		}
+
+		c.Next()
+	}
+}
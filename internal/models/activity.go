@@ -0,0 +1,42 @@
+package models
+
+import "gorm.io/gorm"
+
+// Activity types.
+const (
+	ActivityWalk     = "walk"
+	ActivityRun      = "run"
+	ActivityCycle    = "cycle"
+	ActivitySwim     = "swim"
+	ActivityHike     = "hike"
+	ActivityStrength = "strength"
+	ActivityYoga     = "yoga"
+	ActivityOther    = "other"
+)
+
+// ActivityMETs are the metabolic equivalents of each activity type at a
+// moderate effort, from the Compendium of Physical Activities.
+var ActivityMETs = map[string]float64{
+	ActivityWalk:     3.5,
+	ActivityRun:      9.8,
+	ActivityCycle:    7.5,
+	ActivitySwim:     6.0,
+	ActivityHike:     6.0,
+	ActivityStrength: 5.0,
+	ActivityYoga:     2.5,
+	ActivityOther:    4.0,
+}
+
+// Activity is an exercise session. Reading.Time is the start of the
+// session. CaloriesEstimated is set when the calories were estimated by
+// the server rather than provided by the client.
+type Activity struct {
+	gorm.Model
+	Type              string `gorm:"not null"`
+	DurationMinutes   uint16 `gorm:"not null"`
+	DistanceKm        *float64
+	Calories          *float64
+	CaloriesEstimated bool
+	AvgHeartRate      *uint16
+	Reading
+}
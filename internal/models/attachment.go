@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// Attachment is a file attached to a vital reading, e.g. a photo of the
+// display of a blood pressure cuff. ReadingType is the path name of the
+// vital, e.g. "blood-pressure". The file and its thumbnail are kept in the
+// blob store under BlobKey and ThumbnailKey; files without a thumbnail
+// have an empty ThumbnailKey.
+type Attachment struct {
+	gorm.Model
+	UserID       uint   `gorm:"not null;index"`
+	ReadingType  string `gorm:"not null;index:idx_attachment_reading"`
+	ReadingID    uint   `gorm:"not null;index:idx_attachment_reading"`
+	FileName     string
+	ContentType  string `gorm:"not null"`
+	Size         int64  `gorm:"not null"`
+	BlobKey      string `gorm:"not null"`
+	ThumbnailKey string
+}
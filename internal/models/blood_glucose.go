@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// BloodGlucose is a measured blood glucose level. The value is stored in
+// mg/dL; Unit is the unit the reading was submitted in and is used as the
+// default unit when it is returned.
+type BloodGlucose struct {
+	gorm.Model
+	MgDl        float64 `gorm:"not null"`
+	Unit        string  `gorm:"not null"`
+	MealContext string
+	Method      string
+	Reading
+}
@@ -1,15 +1,27 @@
 package models
 
-import (
-	"time"
+import "gorm.io/gorm"
 
-	"gorm.io/gorm"
+// Arms and postures of a blood pressure reading.
+const (
+	ArmLeft  = "left"
+	ArmRight = "right"
+
+	PostureSeated   = "seated"
+	PostureStanding = "standing"
+	PostureLying    = "lying"
 )
 
+// BloodPressure is a blood pressure reading in mmHg. The pulse, arm,
+// posture and irregular heartbeat indicator are optional, as they were
+// added after the first readings were stored.
 type BloodPressure struct {
 	gorm.Model
-	Sys    uint16    `gorm:"not null"`
-	Dia    uint16    `gorm:"not null"`
-	UserID uint      `gorm:"not null"`
-	Time   time.Time `gorm:"not null"`
+	Sys                uint16 `gorm:"not null"`
+	Dia                uint16 `gorm:"not null"`
+	Pulse              *uint16
+	Arm                string
+	Posture            string
+	IrregularHeartbeat *bool
+	Reading
 }
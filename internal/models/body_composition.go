@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+// BodyComposition is a body composition reading, typically from a smart
+// scale. Masses are in kilograms. VisceralFat is the rating reported by
+// the scale.
+type BodyComposition struct {
+	gorm.Model
+	WeightKg       float64 `gorm:"not null"`
+	BodyFatPercent *float64
+	MuscleMassKg   *float64
+	WaterPercent   *float64
+	BoneMassKg     *float64
+	VisceralFat    *float64
+	Reading
+}
+
+// BodyMeasurement is a set of body circumferences in centimeters. Any of
+// them may be missing.
+type BodyMeasurement struct {
+	gorm.Model
+	WaistCm *float64
+	HipCm   *float64
+	ChestCm *float64
+	NeckCm  *float64
+	Reading
+}
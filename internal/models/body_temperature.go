@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// BodyTemperature is a measured body temperature. The value is stored in
+// degrees Celsius; Unit is the unit the reading was submitted in and is
+// used as the default unit when it is returned.
+type BodyTemperature struct {
+	gorm.Model
+	Celsius float64 `gorm:"not null"`
+	Unit    string  `gorm:"not null"`
+	Site    string
+	Reading
+}
@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+// Heart rate contexts describe the activity level at the time of a reading.
+const (
+	HeartRateResting  = "resting"
+	HeartRateActive   = "active"
+	HeartRateRecovery = "recovery"
+)
+
+type HeartRate struct {
+	gorm.Model
+	Bpm     uint16 `gorm:"not null"`
+	Context string
+	Reading
+}
@@ -0,0 +1,31 @@
+package models
+
+import "gorm.io/gorm"
+
+// JournalEntry is a symptom and mood journal entry. Mood and energy are
+// scores from 1 to 5.
+type JournalEntry struct {
+	gorm.Model
+	Mood     *uint8
+	Energy   *uint8
+	Notes    string
+	Symptoms []JournalSymptom `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Links    []JournalLink    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Reading
+}
+
+// JournalSymptom is a symptom recorded in a journal entry.
+type JournalSymptom struct {
+	ID             uint   `gorm:"primarykey"`
+	JournalEntryID uint   `gorm:"not null;index"`
+	Name           string `gorm:"not null;index"`
+}
+
+// JournalLink links a journal entry to a reading of another vital.
+// ReadingType is the path name of the vital, e.g. "blood-pressure".
+type JournalLink struct {
+	ID             uint   `gorm:"primarykey"`
+	JournalEntryID uint   `gorm:"not null;index"`
+	ReadingType    string `gorm:"not null;index:idx_journal_link_reading"`
+	ReadingID      uint   `gorm:"not null;index:idx_journal_link_reading"`
+}
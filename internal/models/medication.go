@@ -0,0 +1,34 @@
+package models
+
+import "gorm.io/gorm"
+
+// Dose statuses.
+const (
+	DoseTaken   = "taken"
+	DoseSkipped = "skipped"
+	DoseLate    = "late"
+)
+
+// Medication is a medication a user takes on a daily schedule. Schedule is
+// a comma separated list of dose times in HH:MM, e.g. "08:00,20:00", and
+// is empty for medications taken as needed. StartDate and EndDate are
+// YYYY-MM-DD dates; a nil EndDate means the medication is ongoing.
+type Medication struct {
+	gorm.Model
+	UserID    uint   `gorm:"not null;index"`
+	Name      string `gorm:"not null"`
+	Dose      string
+	Schedule  string
+	StartDate string `gorm:"not null"`
+	EndDate   *string
+	DoseList  []Dose `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// Dose is a logged dose of a medication. Reading.Time is when the dose
+// was taken, or when it was due if it was skipped.
+type Dose struct {
+	gorm.Model
+	MedicationID uint   `gorm:"not null;index"`
+	Status       string `gorm:"not null"`
+	Reading
+}
@@ -0,0 +1,45 @@
+package models
+
+import "gorm.io/gorm"
+
+// Metric value kinds.
+const (
+	MetricNumber  = "number"
+	MetricInteger = "integer"
+	MetricBoolean = "boolean"
+	MetricEnum    = "enum"
+	MetricPair    = "pair"
+)
+
+// MetricDefinition is a custom metric type. Definitions without a user are
+// global and are available to every user; a user's own definition takes
+// precedence over a global one with the same key. Min and Max limit number,
+// integer and pair values. Options is a comma separated list of the values
+// of an enum and Labels a comma separated pair of names for the two values
+// of a pair, e.g. "left,right".
+type MetricDefinition struct {
+	gorm.Model
+	UserID    *uint  `gorm:"index"`
+	Key       string `gorm:"not null;index"`
+	Name      string `gorm:"not null"`
+	Unit      string
+	Kind      string `gorm:"not null"`
+	Min       *float64
+	Max       *float64
+	Options   string
+	Labels    string
+	ValueList []MetricValue `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// MetricValue is a recorded value of a custom metric. Numbers, integers
+// and the first value of a pair are stored in Number and the second value
+// of a pair in Number2. Booleans are stored as 0 or 1 in Number and enum
+// values in Text.
+type MetricValue struct {
+	gorm.Model
+	MetricDefinitionID uint `gorm:"not null;index"`
+	Number             *float64
+	Number2            *float64
+	Text               string
+	Reading
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// Meal types.
+const (
+	MealBreakfast = "breakfast"
+	MealLunch     = "lunch"
+	MealDinner    = "dinner"
+	MealSnack     = "snack"
+)
+
+// NutritionEntry is a food or meal. Nutrients are in grams except sodium,
+// which is in milligrams. Any of them may be missing.
+type NutritionEntry struct {
+	gorm.Model
+	Name     string `gorm:"not null"`
+	MealType string
+	Calories *float64
+	ProteinG *float64
+	CarbsG   *float64
+	FatG     *float64
+	FiberG   *float64
+	SugarG   *float64
+	SodiumMg *float64
+	Reading
+}
+
+// AfterSave keeps a sugar intake record in sync with the sugar of the
+// entry, so that sugar logged with a meal shows up in the sugar intake
+// views without being entered twice.
+func (n *NutritionEntry) AfterSave(tx *gorm.DB) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+
+	var sugar SugarIntake
+	err := db.Where("nutrition_entry_id = ?", n.ID).First(&sugar).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	grams := uint(0)
+	if n.SugarG != nil {
+		grams = uint(math.Round(*n.SugarG))
+	}
+
+	if grams == 0 {
+		if sugar.ID == 0 {
+			return nil
+		}
+		return db.Unscoped().Delete(&sugar).Error
+	}
+
+	sugar.Grams = grams
+	sugar.NutritionEntryID = &n.ID
+	sugar.UserID = n.UserID
+	sugar.SetTime(n.LocalTime())
+
+	return db.Save(&sugar).Error
+}
+
+// AfterDelete removes the sugar intake record of the entry.
+func (n *NutritionEntry) AfterDelete(tx *gorm.DB) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	return db.Unscoped().Where("nutrition_entry_id = ?", n.ID).Delete(&SugarIntake{}).Error
+}
@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// OxygenSaturation is a pulse oximeter reading of the blood oxygen
+// saturation (SpO2) in percent and optionally the pulse.
+type OxygenSaturation struct {
+	gorm.Model
+	Percent uint8 `gorm:"not null"`
+	Pulse   *uint16
+	Reading
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reading holds the fields shared by every vital record: the user the
+// record belongs to, the time it was taken and an optional note, tags and
+// source giving its context, e.g. "after-coffee" and "Apple Watch". Tags are stored as a comma
+// separated list wrapped in commas, e.g. ",after-coffee,new-scale,", so a
+// single tag can be matched with TagPattern. The time is stored in UTC, so
+// that SQLite, which compares times as text, orders and filters readings
+// submitted with different offsets correctly; the UTC offset it was
+// submitted with is kept in UtcOffset, in seconds.
+type Reading struct {
+	UserID    uint      `gorm:"not null"`
+	Time      time.Time `gorm:"not null"`
+	UtcOffset int       `gorm:"not null;default:0"`
+	Note      string
+	Tags      string
+	Source    string
+}
+
+// SetTime sets the time of the reading in UTC and the UTC offset of t.
+func (r *Reading) SetTime(t time.Time) {
+	_, offset := t.Zone()
+	r.Time = t.UTC()
+	r.UtcOffset = offset
+}
+
+// LocalTime returns the time of the reading in the UTC offset it was
+// submitted with.
+func (r Reading) LocalTime() time.Time {
+	return r.Time.In(time.FixedZone("", r.UtcOffset))
+}
+
+// GetReading returns the embedded reading so generic code can set the
+// owner and time of any vital record.
+func (r *Reading) GetReading() *Reading {
+	return r
+}
+
+// TagList returns the tags of the reading.
+func (r Reading) TagList() []string {
+	if r.Tags == "" {
+		return nil
+	}
+
+	return strings.Split(strings.Trim(r.Tags, ","), ",")
+}
+
+// SetTags replaces the tags of the reading.
+func (r *Reading) SetTags(tags []string) {
+	r.Tags = JoinTags(tags)
+}
+
+// JoinTags returns tags in the format of Reading.Tags.
+func JoinTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	return "," + strings.Join(tags, ",") + ","
+}
+
+// TagPattern returns a LIKE pattern matching the readings with the tag.
+func TagPattern(tag string) string {
+	return "%," + tag + ",%"
+}
+
+// TaggedReadings returns a model of every vital whose readings can have a
+// note and tags.
+func TaggedReadings() []any {
+	return []any{
+		&BloodPressure{}, &Weight{}, &WaterIntake{}, &SugarIntake{},
+		&HeartRate{}, &BloodGlucose{}, &SleepSession{}, &Activity{},
+		&BodyTemperature{}, &OxygenSaturation{}, &Dose{}, &BodyComposition{},
+		&BodyMeasurement{}, &NutritionEntry{}, &MetricValue{},
+	}
+}
+
+// Converts the times of readings stored with a UTC offset before times were
+// stored in UTC. Only SQLite keeps the offset; Postgres stores instants.
+func normalizeReadingTimes(db *gorm.DB) error {
+	if db.Dialector.Name() != "sqlite" {
+		return nil
+	}
+
+	models := append(TaggedReadings(), &JournalEntry{})
+	for _, model := range models {
+		if err := normalizeTimes(db, model, "time"); err != nil {
+			return err
+		}
+	}
+
+	return normalizeTimes(db, &SleepSession{}, "end_time")
+}
+
+// Rewrites the values of a time column that are not in UTC.
+func normalizeTimes(db *gorm.DB, model any, column string) error {
+	var rows []struct {
+		ID   uint
+		Time time.Time
+	}
+
+	err := db.Unscoped().Model(model).Select("id, "+column+" AS time").Where(column+" NOT LIKE ?", "%+00:00").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		err := db.Unscoped().Model(model).Where("id = ?", r.ID).UpdateColumn(column, r.Time.UTC()).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
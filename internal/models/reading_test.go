@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestReadingTimesMigration(t *testing.T) {
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+
+	// Keep a connection open so the in-memory database outlives the setup.
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := conn.DB()
+	require.NoError(t, err)
+	defer db.Close()
+
+	InitializeSqliteDatabase(dsn)
+	require.NotNil(t, DB)
+
+	// Readings saved with their UTC offset before times were stored in UTC.
+	require.NoError(t, conn.Exec(`INSERT INTO weights (id, user_id, time, utc_offset, kilograms, unit) VALUES (1, 1, '2024-01-02 08:00:00+09:00', 32400, 70, 'kg')`).Error)
+	require.NoError(t, conn.Exec(`INSERT INTO sleep_sessions (id, user_id, time, end_time, night_of) VALUES (1, 1, '2024-01-01 22:00:00-05:00', '2024-01-02 06:00:00-05:00', '2024-01-01')`).Error)
+
+	InitializeSqliteDatabase(dsn)
+
+	var times []string
+	require.NoError(t, conn.Raw(`SELECT CAST(time AS TEXT) FROM weights UNION ALL SELECT CAST(time AS TEXT) FROM sleep_sessions UNION ALL SELECT CAST(end_time AS TEXT) FROM sleep_sessions`).Scan(&times).Error)
+	assert.Equal(t, []string{"2024-01-01 23:00:00+00:00", "2024-01-02 03:00:00+00:00", "2024-01-02 11:00:00+00:00"}, times)
+
+	var w Weight
+	require.NoError(t, DB.First(&w, 1).Error)
+	assert.Equal(t, "2024-01-02T08:00:00+09:00", w.LocalTime().Format("2006-01-02T15:04:05Z07:00"))
+}
@@ -11,18 +11,20 @@ import (
 var DB *gorm.DB
 
 func InitializeDatabase(host string, user string, password string, dbname string) {
-	// TODO: Add support for different database types.
-	const databaseType = "postgres"
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s", host, user, password, dbname)
+	initialize(postgres.Open(dsn))
+}
 
-	var database *gorm.DB
-	var err error
+// InitializeSqliteDatabase opens a SQLite database at the given path or DSN,
+// e.g. "./vitals.db" or "file::memory:" for tests.
+func InitializeSqliteDatabase(dsn string) {
+	initialize(sqlite.Open(dsn))
+}
 
-	if databaseType == "postgres" {
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s", host, user, password, dbname)
-		database, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	} else if databaseType == "sqlite" {
-		database, err = gorm.Open(sqlite.Open("./vitals.db"), &gorm.Config{})
-	}
+func initialize(dialector gorm.Dialector) {
+	// TranslateError converts driver specific constraint violations into
+	// gorm.ErrDuplicatedKey and gorm.ErrForeignKeyViolated.
+	database, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
 
 	if err != nil {
 		panic("Failed to connect to database.")
@@ -33,25 +35,120 @@ func InitializeDatabase(host string, user string, password string, dbname string
 		return
 	}
 
+	err = renameColumn(database, &Weight{}, "weight", "kilograms")
+	if err != nil {
+		return
+	}
+
 	err = database.AutoMigrate(&Weight{})
 	if err != nil {
 		return
 	}
 
+	err = convertLegacyUnits(database, &Weight{}, "kilograms", DefaultWeightUnit, KilogramsPer[DefaultWeightUnit])
+	if err != nil {
+		return
+	}
+
+	err = renameColumn(database, &WaterIntake{}, "cups", "milliliters")
+	if err != nil {
+		return
+	}
+
 	err = database.AutoMigrate(&WaterIntake{})
 	if err != nil {
 		return
 	}
 
+	err = convertLegacyUnits(database, &WaterIntake{}, "milliliters", DefaultWaterUnit, MillilitersPer[DefaultWaterUnit])
+	if err != nil {
+		return
+	}
+
 	err = database.AutoMigrate(&SugarIntake{})
 	if err != nil {
 		return
 	}
 
+	err = database.AutoMigrate(&HeartRate{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&BloodGlucose{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&SleepSession{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&Activity{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&BodyTemperature{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&OxygenSaturation{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&Medication{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&Dose{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&JournalEntry{}, &JournalSymptom{}, &JournalLink{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&BodyComposition{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&BodyMeasurement{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&NutritionEntry{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&MetricDefinition{}, &MetricValue{})
+	if err != nil {
+		return
+	}
+
+	err = database.AutoMigrate(&Attachment{})
+	if err != nil {
+		return
+	}
+
 	err = database.AutoMigrate(&User{})
 	if err != nil {
 		return
 	}
 
+	err = normalizeReadingTimes(database)
+	if err != nil {
+		return
+	}
+
 	DB = database
 }
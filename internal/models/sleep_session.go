@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SleepSession is a period of sleep. Reading.Time is the start of the
+// session and NightOf is the YYYY-MM-DD date of the night it belongs to.
+// The stage breakdown and quality are optional.
+type SleepSession struct {
+	gorm.Model
+	EndTime      time.Time `gorm:"not null"`
+	NightOf      string    `gorm:"not null;index"`
+	LightMinutes *uint16
+	DeepMinutes  *uint16
+	RemMinutes   *uint16
+	AwakeMinutes *uint16
+	Quality      *uint8
+	Reading
+}
@@ -1,14 +1,13 @@
 package models
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
+import "gorm.io/gorm"
 
+// SugarIntake is an amount of sugar consumed. NutritionEntryID is set on
+// records created from the sugar of a nutrition entry; those are kept in
+// sync with the entry and can not be changed on their own.
 type SugarIntake struct {
 	gorm.Model
-	Grams  uint      `gorm:"not null"`
-	UserID uint      `gorm:"not null"`
-	Time   time.Time `gorm:"not null"`
+	Grams            uint  `gorm:"not null"`
+	NutritionEntryID *uint `gorm:"uniqueIndex"`
+	Reading
 }
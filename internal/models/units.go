@@ -0,0 +1,63 @@
+package models
+
+import "gorm.io/gorm"
+
+// Weight units.
+const (
+	WeightKg = "kg"
+	WeightLb = "lb"
+	WeightSt = "st"
+)
+
+// Water units. Fluid ounces and cups are US customary units.
+const (
+	WaterMl   = "ml"
+	WaterL    = "l"
+	WaterFlOz = "fl oz"
+	WaterCups = "cups"
+)
+
+// KilogramsPer is the number of kilograms in each weight unit.
+var KilogramsPer = map[string]float64{
+	WeightKg: 1,
+	WeightLb: 0.45359237,
+	WeightSt: 6.35029318,
+}
+
+// MillilitersPer is the number of milliliters in each water unit.
+var MillilitersPer = map[string]float64{
+	WaterMl:   1,
+	WaterL:    1000,
+	WaterFlOz: 29.5735295625,
+	WaterCups: 236.5882365,
+}
+
+// DefaultWeightUnit and DefaultWaterUnit are the units of weights and
+// water intakes submitted without a unit. Readings recorded before units
+// were supported are converted from these units when the database is
+// initialized, so they must be configured before.
+var (
+	DefaultWeightUnit = WeightKg
+	DefaultWaterUnit  = WaterCups
+)
+
+// Renames a column of a model if it still has its old name, so that
+// AutoMigrate does not add a new column next to it.
+func renameColumn(db *gorm.DB, model any, from string, to string) error {
+	m := db.Migrator()
+
+	if m.HasTable(model) && m.HasColumn(model, from) && !m.HasColumn(model, to) {
+		return m.RenameColumn(model, from, to)
+	}
+
+	return nil
+}
+
+// Converts the readings recorded before units were supported, which have
+// no unit, from unit to the canonical unit of column. factor is the
+// canonical value of one unit.
+func convertLegacyUnits(db *gorm.DB, model any, column string, unit string, factor float64) error {
+	return db.Unscoped().Model(model).
+		Where("unit = '' OR unit IS NULL").
+		UpdateColumns(map[string]any{column: gorm.Expr(column+" * ?", factor), "unit": unit}).Error
+}
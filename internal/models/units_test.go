@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestLegacyUnitsMigration(t *testing.T) {
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+
+	// Keep a connection open so the in-memory database outlives the setup.
+	legacy, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	db, err := legacy.DB()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The tables as they were before units were supported.
+	require.NoError(t, legacy.Table("weights").AutoMigrate(&struct {
+		gorm.Model
+		Reading
+		Weight float64 `gorm:"not null"`
+	}{}))
+	require.NoError(t, legacy.Table("water_intakes").AutoMigrate(&struct {
+		gorm.Model
+		Reading
+		Cups float64 `gorm:"not null"`
+	}{}))
+	require.NoError(t, legacy.Exec(`INSERT INTO weights (id, user_id, time, weight) VALUES (1, 1, '2024-01-01', 150)`).Error)
+	require.NoError(t, legacy.Exec(`INSERT INTO water_intakes (id, user_id, time, cups) VALUES (1, 1, '2024-01-01', 2)`).Error)
+
+	weightUnit := DefaultWeightUnit
+	DefaultWeightUnit = WeightLb
+	defer func() { DefaultWeightUnit = weightUnit }()
+
+	InitializeSqliteDatabase(dsn)
+	require.NotNil(t, DB)
+
+	var w Weight
+	require.NoError(t, DB.First(&w, 1).Error)
+	assert.InDelta(t, 68.04, w.Kilograms, 0.01)
+	assert.Equal(t, WeightLb, w.Unit)
+
+	var wi WaterIntake
+	require.NoError(t, DB.First(&wi, 1).Error)
+	assert.InDelta(t, 473.18, wi.Milliliters, 0.01)
+	assert.Equal(t, WaterCups, wi.Unit)
+
+	// Initializing again must not convert the readings twice.
+	InitializeSqliteDatabase(dsn)
+	require.NoError(t, DB.First(&w, 1).Error)
+	assert.InDelta(t, 68.04, w.Kilograms, 0.01)
+}
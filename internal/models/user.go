@@ -2,15 +2,38 @@ package models
 
 import "gorm.io/gorm"
 
+// RoleAdmin is the role of users that can manage shared settings such as
+// the global custom metrics.
+const RoleAdmin = "admin"
+
 type User struct {
 	gorm.Model
-	FirstName         string
-	LastName          string
-	Role              string
-	UserName          string `gorm:"uniqueIndex,not null"`
-	PasswordHash      string
-	BloodPressureList []BloodPressure `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
-	WeightList        []Weight        `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
-	WaterIntakeList   []WaterIntake   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
-	SugarIntakeList   []SugarIntake   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	FirstName            string
+	LastName             string
+	Role                 string
+	UserName             string `gorm:"uniqueIndex,not null"`
+	PasswordHash         string
+	HeightCm             *float64
+	WeightUnit           string
+	WaterUnit            string
+	TimeZone             string
+	BloodPressureList    []BloodPressure    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	WeightList           []Weight           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	WaterIntakeList      []WaterIntake      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	SugarIntakeList      []SugarIntake      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	HeartRateList        []HeartRate        `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	BloodGlucoseList     []BloodGlucose     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	SleepSessionList     []SleepSession     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	ActivityList         []Activity         `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	BodyTemperatureList  []BodyTemperature  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	OxygenSaturationList []OxygenSaturation `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	MedicationList       []Medication       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	DoseList             []Dose             `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	JournalEntryList     []JournalEntry     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	BodyCompositionList  []BodyComposition  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	BodyMeasurementList  []BodyMeasurement  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	NutritionEntryList   []NutritionEntry   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	MetricDefinitionList []MetricDefinition `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	MetricValueList      []MetricValue      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	AttachmentList       []Attachment       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 }
@@ -1,14 +1,13 @@
 package models
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
+import "gorm.io/gorm"
 
+// WaterIntake is an amount of water drunk. The amount is stored in
+// milliliters; Unit is the unit the intake was submitted in and is used as
+// the default unit when it is returned.
 type WaterIntake struct {
 	gorm.Model
-	Cups   float32   `gorm:"not null"`
-	UserID uint      `gorm:"not null"`
-	Time   time.Time `gorm:"not null"`
+	Milliliters float64 `gorm:"not null"`
+	Unit        string  `gorm:"not null;default:''"`
+	Reading
 }
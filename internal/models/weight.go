@@ -1,14 +1,13 @@
 package models
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
+import "gorm.io/gorm"
 
+// Weight is a body weight reading. The weight is stored in kilograms; Unit
+// is the unit the reading was submitted in and is used as the default unit
+// when it is returned.
 type Weight struct {
 	gorm.Model
-	Weight float32   `gorm:"not null"`
-	UserID uint      `gorm:"not null"`
-	Time   time.Time `gorm:"not null"`
+	Kilograms float64 `gorm:"not null"`
+	Unit      string  `gorm:"not null;default:''"`
+	Reading
 }
@@ -0,0 +1,95 @@
+package payload
+
+import (
+	"math"
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+type ActivityRequest struct {
+	Type             string    `json:"type" binding:"required,oneof=walk run cycle swim hike strength yoga other" enums:"walk,run,cycle,swim,hike,strength,yoga,other"`
+	DurationMinutes  uint16    `json:"durationMinutes" binding:"required"`
+	DistanceKm       *float64  `json:"distanceKm"`
+	Calories         *float64  `json:"calories"`
+	AverageHeartRate *uint16   `json:"averageHeartRate"`
+	Time             time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r ActivityRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+type ActivityResponse struct {
+	Id                uint      `json:"id"`
+	Type              string    `json:"type"`
+	DurationMinutes   uint16    `json:"durationMinutes"`
+	DistanceKm        *float64  `json:"distanceKm,omitempty"`
+	Calories          *float64  `json:"calories,omitempty"`
+	CaloriesEstimated bool      `json:"caloriesEstimated"`
+	AverageHeartRate  *uint16   `json:"averageHeartRate,omitempty"`
+	Time              time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+func MapActivityResponse(a models.Activity) ActivityResponse {
+	return ActivityResponse{
+		Id:                a.ID,
+		Type:              a.Type,
+		DurationMinutes:   a.DurationMinutes,
+		DistanceKm:        a.DistanceKm,
+		Calories:          a.Calories,
+		CaloriesEstimated: a.CaloriesEstimated,
+		AverageHeartRate:  a.AvgHeartRate,
+		Time:              a.Time,
+	}
+}
+
+// ApplyActivityRequest copies the activity values of a request onto a.
+// Calories that are not provided are estimated before the activity is
+// saved.
+func ApplyActivityRequest(a *models.Activity, r ActivityRequest) {
+	a.Type = r.Type
+	a.DurationMinutes = r.DurationMinutes
+	a.DistanceKm = r.DistanceKm
+	a.Calories = r.Calories
+	a.CaloriesEstimated = false
+	a.AvgHeartRate = r.AverageHeartRate
+}
+
+// EstimateActivityCalories returns the kilocalories burned by a person
+// weighing kg during an activity, i.e. MET x kg x hours, rounded to whole
+// kilocalories.
+func EstimateActivityCalories(activityType string, kg float64, minutes uint16) float64 {
+	met, ok := models.ActivityMETs[activityType]
+	if !ok {
+		met = models.ActivityMETs[models.ActivityOther]
+	}
+
+	return math.Round(met * kg * float64(minutes) / 60)
+}
+
+// ActivityWeekResponse summarizes the activities of a week starting on
+// Monday.
+type ActivityWeekResponse struct {
+	WeekStart     string  `json:"weekStart" example:"2024-01-01"`
+	Sessions      int     `json:"sessions"`
+	ActiveMinutes int     `json:"activeMinutes"`
+	DistanceKm    float64 `json:"distanceKm"`
+	Calories      float64 `json:"calories"`
+
+	// Minutes of activities of 3 to 6 METs and of 6 METs or more.
+	ModerateMinutes int `json:"moderateMinutes"`
+	VigorousMinutes int `json:"vigorousMinutes"`
+
+	// Moderate minutes plus twice the vigorous minutes. The WHO recommends
+	// at least 150 per week.
+	GoalMinutes int  `json:"goalMinutes"`
+	GoalMet     bool `json:"goalMet"`
+
+	// Minutes per activity type.
+	Types map[string]int `json:"types"`
+}
@@ -0,0 +1,35 @@
+package payload
+
+import (
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+// AttachmentResponse describes an attached file. Url and ThumbnailUrl are
+// signed download URLs that do not need an Authorization header, so they
+// can be used in e.g. an img tag. They expire at UrlExpires.
+type AttachmentResponse struct {
+	Id           uint      `json:"id"`
+	ReadingType  string    `json:"readingType"`
+	ReadingId    uint      `json:"readingId"`
+	FileName     string    `json:"fileName"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	Url          string    `json:"url"`
+	ThumbnailUrl string    `json:"thumbnailUrl,omitempty"`
+	UrlExpires   time.Time `json:"urlExpires"`
+	Time         time.Time `json:"time"`
+}
+
+func MapAttachmentResponse(a models.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		Id:          a.ID,
+		ReadingType: a.ReadingType,
+		ReadingId:   a.ReadingID,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Time:        a.CreatedAt,
+	}
+}
@@ -0,0 +1,119 @@
+package payload
+
+import (
+	"math"
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+// Blood glucose units.
+const (
+	GlucoseMgDl  = "mg/dL"
+	GlucoseMmolL = "mmol/L"
+)
+
+// mg/dL per mmol/L of glucose, derived from its molar mass of 180.16 g/mol.
+const glucoseMgDlPerMmolL = 18.016
+
+type BloodGlucoseRequest struct {
+	Value       float64   `json:"value" binding:"required"`
+	Unit        string    `json:"unit" binding:"required,oneof=mg/dL mmol/L" enums:"mg/dL,mmol/L"`
+	MealContext string    `json:"mealContext" binding:"omitempty,oneof=fasting pre-meal post-meal bedtime" enums:"fasting,pre-meal,post-meal,bedtime"`
+	Method      string    `json:"method" binding:"omitempty,oneof=fingerstick cgm" enums:"fingerstick,cgm"`
+	Time        time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r BloodGlucoseRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+// MgDl returns the requested value in mg/dL.
+func (r BloodGlucoseRequest) MgDl() float64 {
+	return GlucoseToMgDl(r.Value, r.Unit)
+}
+
+type BloodGlucoseResponse struct {
+	Id          uint      `json:"id"`
+	Value       float64   `json:"value"`
+	Unit        string    `json:"unit"`
+	MealContext string    `json:"mealContext,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	Time        time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+
+	// The stored value, converted without the rounding of Value.
+	mgDl float64
+}
+
+// ConvertTo converts the value of the response to unit.
+func (r *BloodGlucoseResponse) ConvertTo(unit string) {
+	r.Value = GlucoseFromMgDl(r.mgDl, unit)
+	r.Unit = unit
+}
+
+func MapBloodGlucoseResponse(bg models.BloodGlucose) BloodGlucoseResponse {
+	return BloodGlucoseResponse{
+		Id:          bg.ID,
+		Value:       GlucoseFromMgDl(bg.MgDl, bg.Unit),
+		Unit:        bg.Unit,
+		MealContext: bg.MealContext,
+		Method:      bg.Method,
+		Time:        bg.Time,
+		mgDl:        bg.MgDl,
+	}
+}
+
+// ApplyBloodGlucoseRequest copies the blood glucose values of a request onto bg.
+func ApplyBloodGlucoseRequest(bg *models.BloodGlucose, r BloodGlucoseRequest) {
+	bg.MgDl = r.MgDl()
+	bg.Unit = r.Unit
+	bg.MealContext = r.MealContext
+	bg.Method = r.Method
+}
+
+// GlucoseToMgDl converts a glucose value in unit to mg/dL.
+func GlucoseToMgDl(value float64, unit string) float64 {
+	if unit == GlucoseMmolL {
+		return value * glucoseMgDlPerMmolL
+	}
+
+	return value
+}
+
+// GlucoseFromMgDl converts a glucose value in mg/dL to unit, rounded to
+// the precision glucometers display: whole numbers for mg/dL and one
+// decimal for mmol/L.
+func GlucoseFromMgDl(mgdl float64, unit string) float64 {
+	if unit == GlucoseMmolL {
+		return math.Round(mgdl/glucoseMgDlPerMmolL*10) / 10
+	}
+
+	return math.Round(mgdl)
+}
+
+// BloodGlucoseStatsResponse summarizes the readings in a date window,
+// including the share of readings in each glucose range.
+type BloodGlucoseStatsResponse struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Unit       string    `json:"unit"`
+	Count      int       `json:"count"`
+	Mean       float64   `json:"mean"`
+	Min        float64   `json:"min"`
+	Max        float64   `json:"max"`
+	TargetLow  float64   `json:"targetLow"`
+	TargetHigh float64   `json:"targetHigh"`
+
+	// Percentage of readings in each range. In range is between the
+	// target limits inclusive; very low is below 54 mg/dL and very high
+	// above 250 mg/dL.
+	VeryLowPercent  float64 `json:"veryLowPercent"`
+	LowPercent      float64 `json:"lowPercent"`
+	InRangePercent  float64 `json:"inRangePercent"`
+	HighPercent     float64 `json:"highPercent"`
+	VeryHighPercent float64 `json:"veryHighPercent"`
+}
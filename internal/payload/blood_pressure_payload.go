@@ -1,29 +1,72 @@
 package payload
 
 import (
+	"math"
 	"time"
 
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 )
 
 type BloodPressureRequest struct {
-	Sys  uint16    `json:"systolic" binding:"required"`
-	Dia  uint16    `json:"diastolic" binding:"required"`
-	Time time.Time `json:"time"`
+	Sys                uint16    `json:"systolic" binding:"required"`
+	Dia                uint16    `json:"diastolic" binding:"required"`
+	Pulse              *uint16   `json:"pulse"`
+	Arm                string    `json:"arm" binding:"omitempty,oneof=left right" enums:"left,right"`
+	Posture            string    `json:"posture" binding:"omitempty,oneof=seated standing lying" enums:"seated,standing,lying"`
+	IrregularHeartbeat *bool     `json:"irregularHeartbeat"`
+	Time               time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r BloodPressureRequest) ReadingTime() time.Time {
+	return r.Time
 }
 
 type BloodPressureResponse struct {
-	Id   uint      `json:"id"`
-	Sys  uint16    `json:"systolic"`
-	Dia  uint16    `json:"diastolic"`
+	Id                 uint    `json:"id"`
+	Sys                uint16  `json:"systolic"`
+	Dia                uint16  `json:"diastolic"`
+	Pulse              *uint16 `json:"pulse,omitempty"`
+	Arm                string  `json:"arm,omitempty"`
+	Posture            string  `json:"posture,omitempty"`
+	IrregularHeartbeat *bool   `json:"irregularHeartbeat,omitempty"`
+
+	// Systolic minus diastolic pressure.
+	PulsePressure int `json:"pulsePressure"`
+
+	// Mean arterial pressure, estimated as the diastolic pressure plus a
+	// third of the pulse pressure.
+	MeanArterialPressure int `json:"meanArterialPressure"`
+
 	Time time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
 }
 
 func MapBloodPressureResponse(bp models.BloodPressure) BloodPressureResponse {
+	pulsePressure := int(bp.Sys) - int(bp.Dia)
+
 	return BloodPressureResponse{
-		Id:   bp.ID,
-		Sys:  bp.Sys,
-		Dia:  bp.Dia,
-		Time: bp.Time,
+		Id:                   bp.ID,
+		Sys:                  bp.Sys,
+		Dia:                  bp.Dia,
+		Pulse:                bp.Pulse,
+		Arm:                  bp.Arm,
+		Posture:              bp.Posture,
+		IrregularHeartbeat:   bp.IrregularHeartbeat,
+		PulsePressure:        pulsePressure,
+		MeanArterialPressure: int(math.Round(float64(bp.Dia) + float64(pulsePressure)/3)),
+		Time:                 bp.Time,
 	}
 }
+
+// ApplyBloodPressureRequest copies the blood pressure values of a request onto bp.
+func ApplyBloodPressureRequest(bp *models.BloodPressure, r BloodPressureRequest) {
+	bp.Sys = r.Sys
+	bp.Dia = r.Dia
+	bp.Pulse = r.Pulse
+	bp.Arm = r.Arm
+	bp.Posture = r.Posture
+	bp.IrregularHeartbeat = r.IrregularHeartbeat
+}
@@ -0,0 +1,152 @@
+package payload
+
+import (
+	"math"
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+type BodyCompositionRequest struct {
+	WeightKg       float64   `json:"weightKg" binding:"required" example:"72.5"`
+	BodyFatPercent *float64  `json:"bodyFatPercent" example:"18.2"`
+	MuscleMassKg   *float64  `json:"muscleMassKg" example:"56.1"`
+	WaterPercent   *float64  `json:"waterPercent" example:"55.4"`
+	BoneMassKg     *float64  `json:"boneMassKg" example:"3.1"`
+	VisceralFat    *float64  `json:"visceralFat" example:"7"`
+	Time           time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r BodyCompositionRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+type BodyCompositionResponse struct {
+	Id             uint     `json:"id"`
+	WeightKg       float64  `json:"weightKg"`
+	BodyFatPercent *float64 `json:"bodyFatPercent,omitempty"`
+	MuscleMassKg   *float64 `json:"muscleMassKg,omitempty"`
+	WaterPercent   *float64 `json:"waterPercent,omitempty"`
+	BoneMassKg     *float64 `json:"boneMassKg,omitempty"`
+	VisceralFat    *float64 `json:"visceralFat,omitempty"`
+
+	// Derived from the weight and the body fat percentage.
+	LeanMassKg *float64 `json:"leanMassKg,omitempty"`
+
+	// Derived from the weight and the height on the user profile.
+	Bmi *float64 `json:"bmi,omitempty"`
+
+	Time time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+// SetHeight adds the metrics derived from the user's height.
+func (r *BodyCompositionResponse) SetHeight(heightCm *float64) {
+	if heightCm != nil && *heightCm > 0 {
+		r.Bmi = roundPtr(r.WeightKg/math.Pow(*heightCm/100, 2), 1)
+	}
+}
+
+func MapBodyCompositionResponse(bc models.BodyComposition) BodyCompositionResponse {
+	res := BodyCompositionResponse{
+		Id:             bc.ID,
+		WeightKg:       bc.WeightKg,
+		BodyFatPercent: bc.BodyFatPercent,
+		MuscleMassKg:   bc.MuscleMassKg,
+		WaterPercent:   bc.WaterPercent,
+		BoneMassKg:     bc.BoneMassKg,
+		VisceralFat:    bc.VisceralFat,
+		Time:           bc.Time,
+	}
+
+	if bc.BodyFatPercent != nil {
+		res.LeanMassKg = roundPtr(bc.WeightKg*(1-*bc.BodyFatPercent/100), 1)
+	}
+
+	return res
+}
+
+// ApplyBodyCompositionRequest copies the body composition values of a request onto bc.
+func ApplyBodyCompositionRequest(bc *models.BodyComposition, r BodyCompositionRequest) {
+	bc.WeightKg = r.WeightKg
+	bc.BodyFatPercent = r.BodyFatPercent
+	bc.MuscleMassKg = r.MuscleMassKg
+	bc.WaterPercent = r.WaterPercent
+	bc.BoneMassKg = r.BoneMassKg
+	bc.VisceralFat = r.VisceralFat
+}
+
+type BodyMeasurementRequest struct {
+	WaistCm *float64  `json:"waistCm" example:"82"`
+	HipCm   *float64  `json:"hipCm" example:"98"`
+	ChestCm *float64  `json:"chestCm" example:"100"`
+	NeckCm  *float64  `json:"neckCm" example:"38"`
+	Time    time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r BodyMeasurementRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+type BodyMeasurementResponse struct {
+	Id      uint     `json:"id"`
+	WaistCm *float64 `json:"waistCm,omitempty"`
+	HipCm   *float64 `json:"hipCm,omitempty"`
+	ChestCm *float64 `json:"chestCm,omitempty"`
+	NeckCm  *float64 `json:"neckCm,omitempty"`
+
+	// Derived from the waist and hip circumferences.
+	WaistToHipRatio *float64 `json:"waistToHipRatio,omitempty"`
+
+	// Derived from the waist circumference and the height on the user
+	// profile.
+	WaistToHeightRatio *float64 `json:"waistToHeightRatio,omitempty"`
+
+	Time time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+// SetHeight adds the metrics derived from the user's height.
+func (r *BodyMeasurementResponse) SetHeight(heightCm *float64) {
+	if heightCm != nil && *heightCm > 0 && r.WaistCm != nil {
+		r.WaistToHeightRatio = roundPtr(*r.WaistCm / *heightCm, 2)
+	}
+}
+
+func MapBodyMeasurementResponse(bm models.BodyMeasurement) BodyMeasurementResponse {
+	res := BodyMeasurementResponse{
+		Id:      bm.ID,
+		WaistCm: bm.WaistCm,
+		HipCm:   bm.HipCm,
+		ChestCm: bm.ChestCm,
+		NeckCm:  bm.NeckCm,
+		Time:    bm.Time,
+	}
+
+	if bm.WaistCm != nil && bm.HipCm != nil && *bm.HipCm > 0 {
+		res.WaistToHipRatio = roundPtr(*bm.WaistCm / *bm.HipCm, 2)
+	}
+
+	return res
+}
+
+// ApplyBodyMeasurementRequest copies the body measurement values of a request onto bm.
+func ApplyBodyMeasurementRequest(bm *models.BodyMeasurement, r BodyMeasurementRequest) {
+	bm.WaistCm = r.WaistCm
+	bm.HipCm = r.HipCm
+	bm.ChestCm = r.ChestCm
+	bm.NeckCm = r.NeckCm
+}
+
+// Rounds f to the given number of decimals and returns a pointer to it.
+func roundPtr(f float64, decimals int) *float64 {
+	p := math.Pow(10, float64(decimals))
+	r := math.Round(f*p) / p
+	return &r
+}
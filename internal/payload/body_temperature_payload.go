@@ -0,0 +1,94 @@
+package payload
+
+import (
+	"math"
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+// Body temperature units.
+const (
+	TemperatureCelsius    = "C"
+	TemperatureFahrenheit = "F"
+)
+
+// FeverCelsius is the temperature from which a reading is flagged as a
+// fever.
+const FeverCelsius = 38.0
+
+type BodyTemperatureRequest struct {
+	Value float64   `json:"value" binding:"required" example:"37.2"`
+	Unit  string    `json:"unit" binding:"required,oneof=C F" enums:"C,F"`
+	Site  string    `json:"site" binding:"omitempty,oneof=oral ear forehead armpit rectal" enums:"oral,ear,forehead,armpit,rectal"`
+	Time  time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r BodyTemperatureRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+// Celsius returns the requested value in degrees Celsius.
+func (r BodyTemperatureRequest) Celsius() float64 {
+	return TemperatureToCelsius(r.Value, r.Unit)
+}
+
+type BodyTemperatureResponse struct {
+	Id    uint      `json:"id"`
+	Value float64   `json:"value"`
+	Unit  string    `json:"unit"`
+	Site  string    `json:"site,omitempty"`
+	Fever bool      `json:"fever"`
+	Time  time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+
+	// The stored temperature, converted without the rounding of Value.
+	celsius float64
+}
+
+// ConvertTo converts the value of the response to unit.
+func (r *BodyTemperatureResponse) ConvertTo(unit string) {
+	r.Value = TemperatureFromCelsius(r.celsius, unit)
+	r.Unit = unit
+}
+
+func MapBodyTemperatureResponse(bt models.BodyTemperature) BodyTemperatureResponse {
+	return BodyTemperatureResponse{
+		Id:      bt.ID,
+		Value:   TemperatureFromCelsius(bt.Celsius, bt.Unit),
+		Unit:    bt.Unit,
+		Site:    bt.Site,
+		Fever:   bt.Celsius >= FeverCelsius,
+		Time:    bt.Time,
+		celsius: bt.Celsius,
+	}
+}
+
+// ApplyBodyTemperatureRequest copies the body temperature values of a request onto bt.
+func ApplyBodyTemperatureRequest(bt *models.BodyTemperature, r BodyTemperatureRequest) {
+	bt.Celsius = r.Celsius()
+	bt.Unit = r.Unit
+	bt.Site = r.Site
+}
+
+// TemperatureToCelsius converts a temperature in unit to degrees Celsius.
+func TemperatureToCelsius(value float64, unit string) float64 {
+	if unit == TemperatureFahrenheit {
+		return (value - 32) * 5 / 9
+	}
+
+	return value
+}
+
+// TemperatureFromCelsius converts a temperature in degrees Celsius to
+// unit, rounded to one decimal as thermometers display it.
+func TemperatureFromCelsius(celsius float64, unit string) float64 {
+	if unit == TemperatureFahrenheit {
+		celsius = celsius*9/5 + 32
+	}
+
+	return math.Round(celsius*10) / 10
+}
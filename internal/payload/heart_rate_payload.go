@@ -0,0 +1,43 @@
+package payload
+
+import (
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+type HeartRateRequest struct {
+	Bpm     uint16    `json:"bpm" binding:"required"`
+	Context string    `json:"context" binding:"omitempty,oneof=resting active recovery" enums:"resting,active,recovery"`
+	Time    time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r HeartRateRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+type HeartRateResponse struct {
+	Id      uint      `json:"id"`
+	Bpm     uint16    `json:"bpm"`
+	Context string    `json:"context,omitempty"`
+	Time    time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+func MapHeartRateResponse(hr models.HeartRate) HeartRateResponse {
+	return HeartRateResponse{
+		Id:      hr.ID,
+		Bpm:     hr.Bpm,
+		Context: hr.Context,
+		Time:    hr.Time,
+	}
+}
+
+// ApplyHeartRateRequest copies the heart rate values of a request onto hr.
+func ApplyHeartRateRequest(hr *models.HeartRate, r HeartRateRequest) {
+	hr.Bpm = r.Bpm
+	hr.Context = r.Context
+}
@@ -0,0 +1,79 @@
+package payload
+
+import (
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+type JournalRequest struct {
+	Mood     *uint8        `json:"mood" binding:"omitempty,min=1,max=5" minimum:"1" maximum:"5"`
+	Energy   *uint8        `json:"energy" binding:"omitempty,min=1,max=5" minimum:"1" maximum:"5"`
+	Notes    string        `json:"notes" binding:"max=5000"`
+	Symptoms []string      `json:"symptoms" binding:"max=50" example:"headache,fatigue"`
+	Links    []JournalLink `json:"links" binding:"max=20,dive"`
+	Time     time.Time     `json:"time"`
+}
+
+// ReadingTime implements VitalRequest.
+func (r JournalRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+// JournalLink refers to a reading of another vital by the path name of the
+// vital and the id of the reading, e.g. {"type": "blood-pressure", "id": 12}.
+type JournalLink struct {
+	Type string `json:"type" binding:"required" example:"blood-pressure"`
+	Id   uint   `json:"id" binding:"required"`
+}
+
+type JournalResponse struct {
+	Id       uint          `json:"id"`
+	Mood     *uint8        `json:"mood,omitempty"`
+	Energy   *uint8        `json:"energy,omitempty"`
+	Notes    string        `json:"notes,omitempty"`
+	Symptoms []string      `json:"symptoms"`
+	Links    []JournalLink `json:"links"`
+	Time     time.Time     `json:"time"`
+	VitalWarnings
+}
+
+func MapJournalResponse(j models.JournalEntry) JournalResponse {
+	res := JournalResponse{
+		Id:       j.ID,
+		Mood:     j.Mood,
+		Energy:   j.Energy,
+		Notes:    j.Notes,
+		Symptoms: make([]string, 0, len(j.Symptoms)),
+		Links:    make([]JournalLink, 0, len(j.Links)),
+		Time:     j.Time,
+	}
+
+	for _, s := range j.Symptoms {
+		res.Symptoms = append(res.Symptoms, s.Name)
+	}
+
+	for _, l := range j.Links {
+		res.Links = append(res.Links, JournalLink{Type: l.ReadingType, Id: l.ReadingID})
+	}
+
+	return res
+}
+
+// ApplyJournalRequest copies the journal values of a request onto j. The
+// symptoms and links replace the existing ones.
+func ApplyJournalRequest(j *models.JournalEntry, r JournalRequest) {
+	j.Mood = r.Mood
+	j.Energy = r.Energy
+	j.Notes = r.Notes
+
+	j.Symptoms = make([]models.JournalSymptom, 0, len(r.Symptoms))
+	for _, s := range r.Symptoms {
+		j.Symptoms = append(j.Symptoms, models.JournalSymptom{Name: s})
+	}
+
+	j.Links = make([]models.JournalLink, 0, len(r.Links))
+	for _, l := range r.Links {
+		j.Links = append(j.Links, models.JournalLink{ReadingType: l.Type, ReadingID: l.Id})
+	}
+}
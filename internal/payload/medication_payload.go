@@ -0,0 +1,143 @@
+package payload
+
+import (
+	"strings"
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+type MedicationRequest struct {
+	Name      string   `json:"name" binding:"required,max=200" example:"Lisinopril"`
+	Dose      string   `json:"dose" binding:"max=100" example:"10 mg"`
+	Schedule  []string `json:"schedule" binding:"max=24,dive,datetime=15:04" example:"08:00,20:00"`
+	StartDate string   `json:"startDate" binding:"omitempty,datetime=2006-01-02" example:"2024-01-01"`
+	EndDate   *string  `json:"endDate" binding:"omitempty,datetime=2006-01-02" example:"2024-12-31"`
+}
+
+type MedicationResponse struct {
+	Id        uint     `json:"id"`
+	Name      string   `json:"name"`
+	Dose      string   `json:"dose,omitempty"`
+	Schedule  []string `json:"schedule"`
+	StartDate string   `json:"startDate"`
+	EndDate   *string  `json:"endDate,omitempty"`
+}
+
+func MapMedicationResponse(m models.Medication) MedicationResponse {
+	return MedicationResponse{
+		Id:        m.ID,
+		Name:      m.Name,
+		Dose:      m.Dose,
+		Schedule:  MedicationSchedule(m),
+		StartDate: m.StartDate,
+		EndDate:   m.EndDate,
+	}
+}
+
+// ApplyMedicationRequest copies the medication values of a request onto m.
+// The start date defaults to today.
+func ApplyMedicationRequest(m *models.Medication, r MedicationRequest) {
+	m.Name = r.Name
+	m.Dose = r.Dose
+	m.Schedule = strings.Join(r.Schedule, ",")
+	m.EndDate = r.EndDate
+
+	m.StartDate = r.StartDate
+	if m.StartDate == "" {
+		m.StartDate = time.Now().Format(time.DateOnly)
+	}
+}
+
+// MedicationSchedule returns the daily dose times of m.
+func MedicationSchedule(m models.Medication) []string {
+	if m.Schedule == "" {
+		return []string{}
+	}
+
+	return strings.Split(m.Schedule, ",")
+}
+
+type DoseRequest struct {
+	MedicationId uint      `json:"medicationId" binding:"required"`
+	Status       string    `json:"status" binding:"required,oneof=taken skipped late" enums:"taken,skipped,late"`
+	Time         time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r DoseRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+type DoseResponse struct {
+	Id           uint      `json:"id"`
+	MedicationId uint      `json:"medicationId"`
+	Status       string    `json:"status"`
+	Time         time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+func MapDoseResponse(d models.Dose) DoseResponse {
+	return DoseResponse{
+		Id:           d.ID,
+		MedicationId: d.MedicationID,
+		Status:       d.Status,
+		Time:         d.Time,
+	}
+}
+
+// ApplyDoseRequest copies the dose values of a request onto d.
+func ApplyDoseRequest(d *models.Dose, r DoseRequest) {
+	d.MedicationID = r.MedicationId
+	d.Status = r.Status
+}
+
+// AdherenceResponse reports how many of the scheduled doses in a date
+// window were taken.
+type AdherenceResponse struct {
+	From string `json:"from" example:"2024-01-01"`
+	To   string `json:"to" example:"2024-01-31"`
+
+	// Totals over all scheduled medications.
+	MedicationAdherence
+
+	Medications []MedicationAdherenceResponse `json:"medications"`
+
+	// Average blood pressure on days all scheduled doses were taken
+	// compared to days with missed doses.
+	BloodPressure AdherenceBloodPressure `json:"bloodPressure"`
+}
+
+// MedicationAdherence counts the doses of one or more medications.
+// Missed doses are scheduled doses that were not logged at all. Late
+// doses count as taken.
+type MedicationAdherence struct {
+	Scheduled        int     `json:"scheduled"`
+	Taken            int     `json:"taken"`
+	Late             int     `json:"late"`
+	Skipped          int     `json:"skipped"`
+	Missed           int     `json:"missed"`
+	AdherencePercent float64 `json:"adherencePercent"`
+}
+
+type MedicationAdherenceResponse struct {
+	MedicationId uint   `json:"medicationId"`
+	Name         string `json:"name"`
+	MedicationAdherence
+}
+
+type AdherenceBloodPressure struct {
+	AdherentDays    BloodPressureAverage `json:"adherentDays"`
+	NonAdherentDays BloodPressureAverage `json:"nonAdherentDays"`
+}
+
+// BloodPressureAverage is the average of the blood pressure readings on a
+// set of days. The averages are omitted if there are no readings.
+type BloodPressureAverage struct {
+	Days      int      `json:"days"`
+	Readings  int      `json:"readings"`
+	Systolic  *float64 `json:"systolic,omitempty"`
+	Diastolic *float64 `json:"diastolic,omitempty"`
+}
@@ -0,0 +1,186 @@
+package payload
+
+import (
+	"strings"
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+type MetricDefinitionRequest struct {
+	Key     string   `json:"key" binding:"required,max=50" example:"peak-flow"`
+	Name    string   `json:"name" binding:"required,max=100" example:"Peak Flow"`
+	Unit    string   `json:"unit" binding:"max=20" example:"L/min"`
+	Kind    string   `json:"kind" binding:"required,oneof=number integer boolean enum pair" enums:"number,integer,boolean,enum,pair"`
+	Min     *float64 `json:"min" example:"50"`
+	Max     *float64 `json:"max" example:"900"`
+	Options []string `json:"options" binding:"max=50,dive,required,max=50"`
+	Labels  []string `json:"labels" binding:"omitempty,len=2,dive,required,max=50"`
+}
+
+type MetricDefinitionResponse struct {
+	Key     string   `json:"key"`
+	Name    string   `json:"name"`
+	Unit    string   `json:"unit,omitempty"`
+	Kind    string   `json:"kind"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	Options []string `json:"options,omitempty"`
+	Labels  []string `json:"labels,omitempty"`
+	Global  bool     `json:"global"`
+}
+
+func MapMetricDefinitionResponse(d models.MetricDefinition) MetricDefinitionResponse {
+	return MetricDefinitionResponse{
+		Key:     d.Key,
+		Name:    d.Name,
+		Unit:    d.Unit,
+		Kind:    d.Kind,
+		Min:     d.Min,
+		Max:     d.Max,
+		Options: MetricOptions(d),
+		Labels:  splitList(d.Labels),
+		Global:  d.UserID == nil,
+	}
+}
+
+// ApplyMetricDefinitionRequest copies the definition values of a request
+// onto d. Limits, options and labels are only kept for the kinds that use
+// them.
+func ApplyMetricDefinitionRequest(d *models.MetricDefinition, r MetricDefinitionRequest) {
+	d.Key = r.Key
+	d.Name = r.Name
+	d.Unit = r.Unit
+	d.Kind = r.Kind
+	d.Min, d.Max, d.Options, d.Labels = nil, nil, "", ""
+
+	switch r.Kind {
+	case models.MetricNumber, models.MetricInteger:
+		d.Min, d.Max = r.Min, r.Max
+	case models.MetricPair:
+		d.Min, d.Max = r.Min, r.Max
+		d.Labels = strings.Join(r.Labels, ",")
+	case models.MetricEnum:
+		d.Options = strings.Join(r.Options, ",")
+	}
+}
+
+// MetricOptions returns the values of an enum metric.
+func MetricOptions(d models.MetricDefinition) []string {
+	return splitList(d.Options)
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
+
+// MetricValueRequest records a value of a custom metric. The JSON type of
+// the value depends on the kind of the metric: a number for number and
+// integer metrics, true or false for boolean metrics, one of the options
+// for enum metrics and an array of two numbers for pair metrics.
+type MetricValueRequest struct {
+	Value any       `json:"value" swaggertype:"object"`
+	Time  time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r MetricValueRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+// Number returns the value if it is a number.
+func (r MetricValueRequest) Number() (float64, bool) {
+	f, ok := r.Value.(float64)
+	return f, ok
+}
+
+// Bool returns the value if it is a boolean.
+func (r MetricValueRequest) Bool() (bool, bool) {
+	b, ok := r.Value.(bool)
+	return b, ok
+}
+
+// Text returns the value if it is a string.
+func (r MetricValueRequest) Text() (string, bool) {
+	s, ok := r.Value.(string)
+	return s, ok
+}
+
+// Pair returns the value if it is an array of two numbers.
+func (r MetricValueRequest) Pair() ([2]float64, bool) {
+	var pair [2]float64
+
+	a, ok := r.Value.([]any)
+	if !ok || len(a) != 2 {
+		return pair, false
+	}
+
+	for i, e := range a {
+		if pair[i], ok = e.(float64); !ok {
+			return pair, false
+		}
+	}
+
+	return pair, true
+}
+
+type MetricValueResponse struct {
+	Id        uint      `json:"id"`
+	MetricKey string    `json:"metricKey"`
+	Value     any       `json:"value" swaggertype:"object"`
+	Unit      string    `json:"unit,omitempty"`
+	Time      time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+// MapMetricValueResponse maps a value of the metric d to its response,
+// converting the stored value back to the JSON type of the kind.
+func MapMetricValueResponse(d models.MetricDefinition, v models.MetricValue) MetricValueResponse {
+	res := MetricValueResponse{Id: v.ID, MetricKey: d.Key, Unit: d.Unit, Time: v.Time}
+
+	switch {
+	case d.Kind == models.MetricEnum:
+		res.Value = v.Text
+	case v.Number == nil:
+	case d.Kind == models.MetricInteger:
+		res.Value = int64(*v.Number)
+	case d.Kind == models.MetricBoolean:
+		res.Value = *v.Number != 0
+	case d.Kind == models.MetricPair && v.Number2 != nil:
+		res.Value = []float64{*v.Number, *v.Number2}
+	default:
+		res.Value = *v.Number
+	}
+
+	return res
+}
+
+// ApplyMetricValueRequest copies the value of a request for the metric d
+// onto v. The request must have been validated against d.
+func ApplyMetricValueRequest(d models.MetricDefinition, v *models.MetricValue, r MetricValueRequest) {
+	v.MetricDefinitionID = d.ID
+	v.Number, v.Number2, v.Text = nil, nil, ""
+
+	switch d.Kind {
+	case models.MetricNumber, models.MetricInteger:
+		f, _ := r.Number()
+		v.Number = &f
+	case models.MetricBoolean:
+		var f float64
+		if b, _ := r.Bool(); b {
+			f = 1
+		}
+		v.Number = &f
+	case models.MetricEnum:
+		v.Text, _ = r.Text()
+	case models.MetricPair:
+		p, _ := r.Pair()
+		v.Number, v.Number2 = &p[0], &p[1]
+	}
+}
@@ -0,0 +1,88 @@
+package payload
+
+import (
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+type NutritionRequest struct {
+	Name     string    `json:"name" binding:"required,max=200" example:"Oatmeal with berries"`
+	MealType string    `json:"mealType" binding:"omitempty,oneof=breakfast lunch dinner snack" enums:"breakfast,lunch,dinner,snack"`
+	Calories *float64  `json:"calories" example:"320"`
+	ProteinG *float64  `json:"proteinG" example:"10"`
+	CarbsG   *float64  `json:"carbsG" example:"54"`
+	FatG     *float64  `json:"fatG" example:"6"`
+	FiberG   *float64  `json:"fiberG" example:"8"`
+	SugarG   *float64  `json:"sugarG" example:"12"`
+	SodiumMg *float64  `json:"sodiumMg" example:"150"`
+	Time     time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r NutritionRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+type NutritionResponse struct {
+	Id       uint      `json:"id"`
+	Name     string    `json:"name"`
+	MealType string    `json:"mealType,omitempty"`
+	Calories *float64  `json:"calories,omitempty"`
+	ProteinG *float64  `json:"proteinG,omitempty"`
+	CarbsG   *float64  `json:"carbsG,omitempty"`
+	FatG     *float64  `json:"fatG,omitempty"`
+	FiberG   *float64  `json:"fiberG,omitempty"`
+	SugarG   *float64  `json:"sugarG,omitempty"`
+	SodiumMg *float64  `json:"sodiumMg,omitempty"`
+	Time     time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+func MapNutritionResponse(n models.NutritionEntry) NutritionResponse {
+	return NutritionResponse{
+		Id:       n.ID,
+		Name:     n.Name,
+		MealType: n.MealType,
+		Calories: n.Calories,
+		ProteinG: n.ProteinG,
+		CarbsG:   n.CarbsG,
+		FatG:     n.FatG,
+		FiberG:   n.FiberG,
+		SugarG:   n.SugarG,
+		SodiumMg: n.SodiumMg,
+		Time:     n.Time,
+	}
+}
+
+// ApplyNutritionRequest copies the nutrition values of a request onto n.
+func ApplyNutritionRequest(n *models.NutritionEntry, r NutritionRequest) {
+	n.Name = r.Name
+	n.MealType = r.MealType
+	n.Calories = r.Calories
+	n.ProteinG = r.ProteinG
+	n.CarbsG = r.CarbsG
+	n.FatG = r.FatG
+	n.FiberG = r.FiberG
+	n.SugarG = r.SugarG
+	n.SodiumMg = r.SodiumMg
+}
+
+// NutritionDayResponse is the total of the nutrition entries of a day.
+// Missing nutrients count as zero.
+type NutritionDayResponse struct {
+	Date     string  `json:"date" example:"2024-01-01"`
+	Entries  int     `json:"entries"`
+	Calories float64 `json:"calories"`
+	ProteinG float64 `json:"proteinG"`
+	CarbsG   float64 `json:"carbsG"`
+	FatG     float64 `json:"fatG"`
+	FiberG   float64 `json:"fiberG"`
+	SugarG   float64 `json:"sugarG"`
+	SodiumMg float64 `json:"sodiumMg"`
+
+	// Calories per meal type. Entries without a meal type are not included.
+	MealCalories map[string]float64 `json:"mealCalories"`
+}
@@ -0,0 +1,49 @@
+package payload
+
+import (
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+// LowOxygenPercent is the saturation below which a reading is flagged as
+// low.
+const LowOxygenPercent = 92
+
+type OxygenSaturationRequest struct {
+	Percent uint8     `json:"percent" binding:"required" example:"97"`
+	Pulse   *uint16   `json:"pulse"`
+	Time    time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r OxygenSaturationRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+type OxygenSaturationResponse struct {
+	Id        uint      `json:"id"`
+	Percent   uint8     `json:"percent"`
+	Pulse     *uint16   `json:"pulse,omitempty"`
+	LowOxygen bool      `json:"lowOxygen"`
+	Time      time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+func MapOxygenSaturationResponse(o models.OxygenSaturation) OxygenSaturationResponse {
+	return OxygenSaturationResponse{
+		Id:        o.ID,
+		Percent:   o.Percent,
+		Pulse:     o.Pulse,
+		LowOxygen: o.Percent < LowOxygenPercent,
+		Time:      o.Time,
+	}
+}
+
+// ApplyOxygenSaturationRequest copies the oxygen saturation values of a request onto o.
+func ApplyOxygenSaturationRequest(o *models.OxygenSaturation, r OxygenSaturationRequest) {
+	o.Percent = r.Percent
+	o.Pulse = r.Pulse
+}
@@ -0,0 +1,116 @@
+package payload
+
+import (
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+// SleepStages is the number of minutes spent in each sleep stage.
+type SleepStages struct {
+	Light uint16 `json:"light"`
+	Deep  uint16 `json:"deep"`
+	Rem   uint16 `json:"rem"`
+	Awake uint16 `json:"awake"`
+}
+
+// Total returns the minutes of all stages.
+func (s SleepStages) Total() int {
+	return int(s.Light) + int(s.Deep) + int(s.Rem) + int(s.Awake)
+}
+
+type SleepSessionRequest struct {
+	Start   time.Time    `json:"start" binding:"required"`
+	End     time.Time    `json:"end" binding:"required"`
+	Stages  *SleepStages `json:"stages"`
+	Quality *uint8       `json:"quality" binding:"omitempty,min=1,max=5" minimum:"1" maximum:"5"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r SleepSessionRequest) ReadingTime() time.Time {
+	return r.Start
+}
+
+// ReadingTimeField implements ReadingTimeFielder.
+func (r SleepSessionRequest) ReadingTimeField() string {
+	return "start"
+}
+
+type SleepSessionResponse struct {
+	Id              uint         `json:"id"`
+	Start           time.Time    `json:"start"`
+	End             time.Time    `json:"end"`
+	NightOf         string       `json:"nightOf" example:"2024-01-01"`
+	DurationMinutes int          `json:"durationMinutes"`
+	Stages          *SleepStages `json:"stages,omitempty"`
+	Quality         *uint8       `json:"quality,omitempty"`
+	ReadingContextResponse
+	VitalWarnings
+}
+
+func MapSleepSessionResponse(s models.SleepSession) SleepSessionResponse {
+	res := SleepSessionResponse{
+		Id:              s.ID,
+		Start:           s.Time,
+		End:             s.EndTime.In(s.Time.Location()),
+		NightOf:         s.NightOf,
+		DurationMinutes: int(s.EndTime.Sub(s.Time).Minutes()),
+		Quality:         s.Quality,
+	}
+
+	if s.LightMinutes != nil {
+		res.Stages = &SleepStages{
+			Light: *s.LightMinutes,
+			Deep:  *s.DeepMinutes,
+			Rem:   *s.RemMinutes,
+			Awake: *s.AwakeMinutes,
+		}
+	}
+
+	return res
+}
+
+// ApplySleepSessionRequest copies the sleep session values of a request onto s.
+func ApplySleepSessionRequest(s *models.SleepSession, r SleepSessionRequest) {
+	s.EndTime = r.End.UTC()
+	s.NightOf = SleepNightOf(r.Start)
+	s.Quality = r.Quality
+	s.LightMinutes, s.DeepMinutes, s.RemMinutes, s.AwakeMinutes = nil, nil, nil, nil
+
+	if r.Stages != nil {
+		stages := *r.Stages
+		s.LightMinutes = &stages.Light
+		s.DeepMinutes = &stages.Deep
+		s.RemMinutes = &stages.Rem
+		s.AwakeMinutes = &stages.Awake
+	}
+}
+
+// SleepNightOf returns the date of the night a session starting at start
+// belongs to, in the time zone of start. Sessions starting before noon
+// belong to the previous night, so sleep from 23:00 or 01:00 until 07:00
+// are both the night of the first day.
+func SleepNightOf(start time.Time) string {
+	if start.Hour() < 12 {
+		start = start.AddDate(0, 0, -1)
+	}
+
+	return start.Format(time.DateOnly)
+}
+
+// SleepNightResponse summarizes the sleep sessions of a single night.
+type SleepNightResponse struct {
+	NightOf          string `json:"nightOf" example:"2024-01-01"`
+	Sessions         int    `json:"sessions"`
+	TimeInBedMinutes int    `json:"timeInBedMinutes"`
+
+	// Time in bed less the awake minutes of sessions with a stage breakdown.
+	AsleepMinutes int `json:"asleepMinutes"`
+
+	// Sum of the stage breakdowns of the sessions that have one.
+	Stages *SleepStages `json:"stages,omitempty"`
+
+	// Average quality of the sessions that have one.
+	Quality *float64 `json:"quality,omitempty"`
+}
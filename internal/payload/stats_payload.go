@@ -0,0 +1,27 @@
+package payload
+
+import "time"
+
+// StatsPeriodResponse summarizes the readings of a vital in one period,
+// e.g. a day. Periods without readings have a count of zero and no
+// fields.
+type StatsPeriodResponse struct {
+	Start  time.Time             `json:"start"`
+	End    time.Time             `json:"end"`
+	Count  int                   `json:"count"`
+	Fields map[string]FieldStats `json:"fields"`
+}
+
+// FieldStats summarizes the values of one field in a period. The standard
+// deviation is that of the population of values. Sum is only set for
+// intakes, and Unit only for vitals whose values can be converted.
+type FieldStats struct {
+	Count  int      `json:"count"`
+	Min    float64  `json:"min"`
+	Max    float64  `json:"max"`
+	Mean   float64  `json:"mean"`
+	Median float64  `json:"median"`
+	StdDev float64  `json:"stdDev"`
+	Sum    *float64 `json:"sum,omitempty"`
+	Unit   string   `json:"unit,omitempty"`
+}
@@ -9,12 +9,24 @@ import (
 type SugarIntakeRequest struct {
 	Grams uint      `json:"grams" binding:"required"`
 	Time  time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r SugarIntakeRequest) ReadingTime() time.Time {
+	return r.Time
 }
 
 type SugarIntakeResponse struct {
 	Id    uint      `json:"id" binding:"required"`
 	Grams uint      `json:"grams" binding:"required"`
 	Time  time.Time `json:"time"`
+
+	// Set if the sugar was logged with a nutrition entry.
+	NutritionEntryId *uint `json:"nutritionEntryId,omitempty"`
+
+	ReadingContextResponse
+	VitalWarnings
 }
 
 func MapSugarIntakeResponse(si models.SugarIntake) SugarIntakeResponse {
@@ -22,5 +34,12 @@ func MapSugarIntakeResponse(si models.SugarIntake) SugarIntakeResponse {
 		Id:    si.ID,
 		Grams: si.Grams,
 		Time:  si.Time,
+
+		NutritionEntryId: si.NutritionEntryID,
 	}
 }
+
+// ApplySugarIntakeRequest copies the sugar intake values of a request onto si.
+func ApplySugarIntakeRequest(si *models.SugarIntake, r SugarIntakeRequest) {
+	si.Grams = r.Grams
+}
@@ -0,0 +1,12 @@
+package payload
+
+type TagRequest struct {
+	Name string `json:"name" binding:"required" example:"after-coffee"`
+}
+
+// TagResponse is a tag and the number of readings across all vitals that
+// have it.
+type TagResponse struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,13 @@
+package payload
+
+import "time"
+
+// TimelineEntry is a reading of any vital in the timeline of a user. Data
+// is the reading as returned by the endpoint of its type, e.g. a
+// WeightResponse for the type "weight".
+type TimelineEntry struct {
+	Type string    `json:"type" example:"weight"`
+	Id   uint      `json:"id"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data" swaggertype:"object"`
+}
@@ -0,0 +1,42 @@
+package payload
+
+import "time"
+
+// TrendResponse smooths the daily values of a vital between From and To.
+type TrendResponse struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Unit string    `json:"unit" example:"kg"`
+
+	// Window is the number of days of the simple moving average and Alpha
+	// the smoothing factor of the exponential moving average.
+	Window int     `json:"window" example:"7"`
+	Alpha  float64 `json:"alpha" example:"0.1"`
+
+	Fields map[string]FieldTrend `json:"fields"`
+}
+
+// FieldTrend is the trend of one field, e.g. the systolic pressure.
+type FieldTrend struct {
+	Days []TrendDay `json:"days"`
+
+	// Slope of the linear regression of the daily values, in units per
+	// week. Missing with fewer than two days of readings.
+	SlopePerWeek *float64 `json:"slopePerWeek,omitempty"`
+
+	// Projected date the exponential moving average reaches the target at
+	// the current slope. Missing without a target, when the trend moves
+	// away from it or when it is more than ten years away.
+	Target     *float64 `json:"target,omitempty"`
+	TargetDate *string  `json:"targetDate,omitempty" example:"2024-06-01"`
+}
+
+// TrendDay is a day with readings. Value is the mean of the readings of
+// the day; the moving averages end on the day.
+type TrendDay struct {
+	Date                     string  `json:"date" example:"2024-01-01"`
+	Count                    int     `json:"count"`
+	Value                    float64 `json:"value"`
+	MovingAverage            float64 `json:"movingAverage"`
+	ExponentialMovingAverage float64 `json:"exponentialMovingAverage"`
+}
@@ -6,27 +6,69 @@ import (
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 )
 
+// UserRequest updates the profile of a user. Fields that are left out keep
+// their value.
 type UserRequest struct {
-	FirstName string `json:"first_name" binding:"required"`
-	LastName  string `json:"last_name" binding:"required"`
+	FirstName *string  `json:"firstName" binding:"omitempty,min=1"`
+	LastName  *string  `json:"lastName" binding:"omitempty,min=1"`
+	HeightCm  *float64 `json:"heightCm" example:"175"`
+
+	// The units weights and water intakes are returned in. Empty means the
+	// unit each reading was submitted in.
+	WeightUnit *string `json:"weightUnit" binding:"omitempty,oneof='' kg lb st" enums:",kg,lb,st"`
+	WaterUnit  *string `json:"waterUnit" binding:"omitempty,oneof='' ml l 'fl oz' cups" enums:",ml,l,fl oz,cups"`
+
+	// The IANA time zone days are counted in, e.g. for nightly sleep and
+	// daily nutrition summaries. Empty means UTC.
+	TimeZone *string `json:"timeZone" example:"Europe/Berlin"`
 }
 
 type UserResponse struct {
-	ID        uint      `json:"id"`
-	FirstName string    `json:"firstName"`
-	LastName  string    `json:"lastName"`
-	Role      string    `json:"role"`
-	UserName  string    `json:"username"`
-	UserSince time.Time `json:"userSince"`
+	ID         uint      `json:"id"`
+	FirstName  string    `json:"firstName"`
+	LastName   string    `json:"lastName"`
+	Role       string    `json:"role"`
+	UserName   string    `json:"username"`
+	HeightCm   *float64  `json:"heightCm,omitempty"`
+	WeightUnit string    `json:"weightUnit,omitempty"`
+	WaterUnit  string    `json:"waterUnit,omitempty"`
+	TimeZone   string    `json:"timeZone,omitempty"`
+	UserSince  time.Time `json:"userSince"`
 }
 
 func MapUserResponse(u models.User) UserResponse {
 	return UserResponse{
-		ID:        u.ID,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Role:      u.Role,
-		UserName:  u.UserName,
-		UserSince: u.CreatedAt,
+		ID:         u.ID,
+		FirstName:  u.FirstName,
+		LastName:   u.LastName,
+		Role:       u.Role,
+		UserName:   u.UserName,
+		HeightCm:   u.HeightCm,
+		WeightUnit: u.WeightUnit,
+		WaterUnit:  u.WaterUnit,
+		TimeZone:   u.TimeZone,
+		UserSince:  u.CreatedAt,
+	}
+}
+
+// ApplyUserRequest copies the profile values present in a request onto u.
+func ApplyUserRequest(u *models.User, r UserRequest) {
+	if r.FirstName != nil {
+		u.FirstName = *r.FirstName
+	}
+	if r.LastName != nil {
+		u.LastName = *r.LastName
+	}
+	if r.HeightCm != nil {
+		u.HeightCm = r.HeightCm
+	}
+	if r.WeightUnit != nil {
+		u.WeightUnit = *r.WeightUnit
+	}
+	if r.WaterUnit != nil {
+		u.WaterUnit = *r.WaterUnit
+	}
+	if r.TimeZone != nil {
+		u.TimeZone = *r.TimeZone
 	}
 }
@@ -0,0 +1,67 @@
+package payload
+
+import (
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+// VitalRequest is implemented by every vital request payload.
+type VitalRequest interface {
+	// ReadingTime returns the time the reading was taken, or the zero
+	// time if the client did not provide one.
+	ReadingTime() time.Time
+}
+
+// ReadingTimeFielder is implemented by vital requests that send the
+// reading time in a JSON field other than "time".
+type ReadingTimeFielder interface {
+	ReadingTimeField() string
+}
+
+// VitalWarnings lists values that were accepted but are unusual, e.g. a blood
+// pressure at hypertensive crisis levels. Vital responses embed it and it
+// is only filled in by create and update.
+type VitalWarnings struct {
+	Warnings []FieldError `json:"warnings,omitempty"`
+}
+
+// SetWarnings sets the warnings of a response.
+func (w *VitalWarnings) SetWarnings(warnings []FieldError) {
+	w.Warnings = warnings
+}
+
+// ReadingContext is the optional note, tags and source of a vital reading,
+// e.g. the device or app that recorded it. Vital requests embed it.
+type ReadingContext struct {
+	Note   string   `json:"note" binding:"max=500" example:"Right after my morning coffee"`
+	Tags   []string `json:"tags" binding:"max=20" example:"after-coffee"`
+	Source string   `json:"source" binding:"max=100" example:"Apple Watch"`
+}
+
+// GetReadingContext returns the note, tags and source of a request.
+func (c ReadingContext) GetReadingContext() ReadingContext {
+	return c
+}
+
+// ReadingContextual is implemented by vital requests that embed
+// ReadingContext.
+type ReadingContextual interface {
+	GetReadingContext() ReadingContext
+}
+
+// ReadingContextResponse is the note, tags and source of a vital reading.
+// Vital responses embed it.
+type ReadingContextResponse struct {
+	Note   string   `json:"note,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Source string   `json:"source,omitempty"`
+}
+
+// SetReadingContext sets the note, tags and source of a response from a
+// reading.
+func (c *ReadingContextResponse) SetReadingContext(r models.Reading) {
+	c.Note = r.Note
+	c.Tags = r.TagList()
+	c.Source = r.Source
+}
@@ -1,26 +1,91 @@
 package payload
 
 import (
+	"math"
 	"time"
 
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 )
 
+// WaterIntakeRequest is an amount of water in a unit. Cups is deprecated:
+// it is the amount in cups and is only used if there is no amount.
 type WaterIntakeRequest struct {
-	Cups float32   `json:"cups" binding:"required"`
-	Time time.Time `json:"time"`
+	Amount float64   `json:"amount" binding:"required_without=Cups" example:"500"`
+	Unit   string    `json:"unit" binding:"omitempty,oneof=ml l 'fl oz' cups" enums:"ml,l,fl oz,cups"`
+	Cups   *float64  `json:"cups" binding:"required_without=Amount"`
+	Time   time.Time `json:"time"`
+	ReadingContext
 }
 
+// ReadingTime implements VitalRequest.
+func (r WaterIntakeRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+// Volume returns the amount and unit of the request, and the JSON field of
+// the amount. Requests without a unit are in the default water unit.
+func (r WaterIntakeRequest) Volume() (float64, string, string) {
+	if r.Amount == 0 && r.Cups != nil {
+		return *r.Cups, models.WaterCups, "cups"
+	}
+
+	if r.Unit == "" {
+		return r.Amount, models.DefaultWaterUnit, "amount"
+	}
+
+	return r.Amount, r.Unit, "amount"
+}
+
+// WaterIntakeResponse is the amount of water in the requested unit. Cups
+// is deprecated and is always the amount in cups.
 type WaterIntakeResponse struct {
-	Id   uint      `json:"id" binding:"required"`
-	Cups float32   `json:"cups" binding:"required"`
-	Time time.Time `json:"time"`
+	Id     uint      `json:"id"`
+	Amount float64   `json:"amount"`
+	Unit   string    `json:"unit"`
+	Cups   float64   `json:"cups"`
+	Time   time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+
+	// The stored amount, converted without the rounding of Amount.
+	milliliters float64
+}
+
+// ConvertTo converts the amount of the response to unit.
+func (r *WaterIntakeResponse) ConvertTo(unit string) {
+	r.Amount = WaterFromMl(r.milliliters, unit)
+	r.Unit = unit
 }
 
 func MapWaterIntakeResponse(wi models.WaterIntake) WaterIntakeResponse {
 	return WaterIntakeResponse{
-		Id:   wi.ID,
-		Cups: wi.Cups,
-		Time: wi.Time,
+		Id:          wi.ID,
+		Amount:      WaterFromMl(wi.Milliliters, wi.Unit),
+		Unit:        wi.Unit,
+		Cups:        WaterFromMl(wi.Milliliters, models.WaterCups),
+		Time:        wi.Time,
+		milliliters: wi.Milliliters,
 	}
 }
+
+// ApplyWaterIntakeRequest copies the water intake values of a request onto wi.
+func ApplyWaterIntakeRequest(wi *models.WaterIntake, r WaterIntakeRequest) {
+	amount, unit, _ := r.Volume()
+	wi.Milliliters = WaterToMl(amount, unit)
+	wi.Unit = unit
+}
+
+// WaterToMl converts an amount of water in unit to milliliters.
+func WaterToMl(value float64, unit string) float64 {
+	return value * models.MillilitersPer[unit]
+}
+
+// WaterFromMl converts an amount of water in milliliters to unit, rounded
+// to whole milliliters or two decimals for the other units.
+func WaterFromMl(ml float64, unit string) float64 {
+	if unit == models.WaterMl {
+		return math.Round(ml)
+	}
+
+	return math.Round(ml/models.MillilitersPer[unit]*100) / 100
+}
@@ -1,26 +1,75 @@
 package payload
 
 import (
+	"math"
 	"time"
 
 	"github.com/zenkimoto/vitals-server-api/internal/models"
 )
 
 type WeightRequest struct {
-	Weight float32   `json:"weight" binding:"required"`
+	Weight float64   `json:"weight" binding:"required" example:"72.5"`
+	Unit   string    `json:"unit" binding:"omitempty,oneof=kg lb st" enums:"kg,lb,st"`
 	Time   time.Time `json:"time"`
+	ReadingContext
+}
+
+// ReadingTime implements VitalRequest.
+func (r WeightRequest) ReadingTime() time.Time {
+	return r.Time
+}
+
+// WeightUnit returns the unit of the request, or the default weight unit
+// if the request has none.
+func (r WeightRequest) WeightUnit() string {
+	if r.Unit == "" {
+		return models.DefaultWeightUnit
+	}
+
+	return r.Unit
 }
 
 type WeightResponse struct {
 	Id     uint      `json:"id"`
-	Weight float32   `json:"weight"`
+	Weight float64   `json:"weight"`
+	Unit   string    `json:"unit"`
 	Time   time.Time `json:"time"`
+	ReadingContextResponse
+	VitalWarnings
+
+	// The stored weight, converted without the rounding of Weight.
+	kilograms float64
+}
+
+// ConvertTo converts the weight of the response to unit.
+func (r *WeightResponse) ConvertTo(unit string) {
+	r.Weight = WeightFromKg(r.kilograms, unit)
+	r.Unit = unit
 }
 
 func MapWeightResponse(w models.Weight) WeightResponse {
 	return WeightResponse{
-		Id:     w.ID,
-		Weight: w.Weight,
-		Time:   w.Time,
+		Id:        w.ID,
+		Weight:    WeightFromKg(w.Kilograms, w.Unit),
+		Unit:      w.Unit,
+		Time:      w.Time,
+		kilograms: w.Kilograms,
 	}
 }
+
+// ApplyWeightRequest copies the weight values of a request onto w.
+func ApplyWeightRequest(w *models.Weight, r WeightRequest) {
+	w.Unit = r.WeightUnit()
+	w.Kilograms = WeightToKg(r.Weight, w.Unit)
+}
+
+// WeightToKg converts a weight in unit to kilograms.
+func WeightToKg(value float64, unit string) float64 {
+	return value * models.KilogramsPer[unit]
+}
+
+// WeightFromKg converts a weight in kilograms to unit, rounded to two
+// decimals.
+func WeightFromKg(kg float64, unit string) float64 {
+	return math.Round(kg/models.KilogramsPer[unit]*100) / 100
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/controllers"
+	"github.com/zenkimoto/vitals-server-api/internal/env"
+	"github.com/zenkimoto/vitals-server-api/internal/middleware"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+)
+
+// V1 is the path prefix of version 1 of the API.
+const V1 = "/v1"
+
+// The unversioned root routes were deprecated when /v1 was introduced.
+var legacyDeprecation = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// Register adds every version of the API to the router.
+//
+// The unversioned root routes are aliases of v1 that are kept for clients
+// released before versioning. Their responses carry Deprecation and Sunset
+// headers pointing to the /v1 routes.
+func Register(router *gin.Engine) {
+	// Errors, including unknown routes and panics, are returned as
+	// problem details responses that carry the request id.
+	router.Use(middleware.RequestId(), gin.CustomRecovery(func(c *gin.Context, err any) {
+		apierror.Abort(c, apierror.Internal(fmt.Errorf("panic: %v", err)))
+	}))
+	router.NoRoute(func(c *gin.Context) {
+		apierror.Abort(c, apierror.NotFound("Route"))
+	})
+
+	registerV1(router.Group(V1))
+	registerV1(router.Group("/", middleware.Deprecated(legacyDeprecation, env.GetLegacyApiSunset(), V1)))
+}
+
+// Adds the public and JWT protected v1 routes to the router.
+func registerV1(router gin.IRouter) {
+	// Public Routes
+	router.GET("/health-check", controllers.HealthCheck)
+
+	router.POST("/auth", controllers.Login)
+	router.POST("/token/validate", controllers.ValidateToken)
+	router.POST("/token/refresh", controllers.RefreshToken)
+
+	// Signed download URLs of attachments
+	router.GET("/attachments/:attachmentId/:variant", controllers.GetAttachmentContent)
+
+	// Protected Routes
+	protected := router.Group("/", middleware.JwtAuth())
+
+	protected.GET("/users", controllers.GetUsers)
+	protected.GET("/users/:id", controllers.GetUserById)
+	protected.PUT("/users/:id", controllers.PutUserById)
+
+	controllers.RegisterVitals(protected)
+
+	protected.GET("/users/:id/blood-pressure/trend", controllers.GetBloodPressureTrendByUserId)
+	protected.GET("/users/:id/weight/trend", controllers.GetWeightTrendByUserId)
+	protected.GET("/users/:id/glucose/stats", controllers.GetBloodGlucoseStatsByUserId)
+	protected.GET("/users/:id/sleep/nightly", controllers.GetSleepNightlyByUserId)
+	protected.GET("/users/:id/activities/weekly", controllers.GetActivityWeeklyByUserId)
+	protected.GET("/users/:id/nutrition/daily", controllers.GetNutritionDailyByUserId)
+	protected.GET("/symptoms", controllers.GetSymptoms)
+
+	protected.GET("/users/:id/medications", controllers.GetMedicationsByUserId)
+	protected.GET("/users/:id/medications/:recordId", controllers.GetMedicationById)
+	protected.GET("/users/:id/medications/adherence", controllers.GetMedicationAdherenceByUserId)
+	protected.POST("/users/:id/medications", controllers.PostMedicationByUserId)
+	protected.PUT("/users/:id/medications/:recordId", controllers.PutMedicationByUserId)
+	protected.DELETE("/users/:id/medications/:recordId", controllers.DeleteMedicationByUserId)
+
+	protected.GET("/users/:id/metric-types", controllers.GetMetricTypesByUserId)
+	protected.GET("/users/:id/metric-types/:metricKey", controllers.GetMetricTypeByUserId)
+	protected.POST("/users/:id/metric-types", controllers.PostMetricTypeByUserId)
+	protected.PUT("/users/:id/metric-types/:metricKey", controllers.PutMetricTypeByUserId)
+	protected.DELETE("/users/:id/metric-types/:metricKey", controllers.DeleteMetricTypeByUserId)
+
+	protected.GET("/users/:id/metrics/:metricKey", controllers.GetMetricValuesByUserId)
+	protected.GET("/users/:id/metrics/:metricKey/:recordId", controllers.GetMetricValueById)
+	protected.POST("/users/:id/metrics/:metricKey", controllers.PostMetricValueByUserId)
+	protected.PUT("/users/:id/metrics/:metricKey/:recordId", controllers.PutMetricValueByUserId)
+	protected.DELETE("/users/:id/metrics/:metricKey/:recordId", controllers.DeleteMetricValueByUserId)
+
+	protected.GET("/metric-types", controllers.GetMetricTypes)
+
+	protected.GET("/users/:id/timeline", controllers.GetTimelineByUserId)
+
+	for _, vital := range controllers.StatsReadings() {
+		protected.GET("/users/:id/"+vital+"/stats", controllers.GetStatsByReading(vital))
+	}
+
+	protected.GET("/users/:id/tags", controllers.GetTagsByUserId)
+	protected.PUT("/users/:id/tags/:tag", controllers.PutTagByUserId)
+
+	controllers.RegisterAttachments(protected)
+
+	// Admin Routes
+	admin := protected.Group("/", middleware.RequireRole(models.RoleAdmin))
+
+	admin.POST("/metric-types", controllers.PostMetricType)
+	admin.PUT("/metric-types/:metricKey", controllers.PutMetricType)
+	admin.DELETE("/metric-types/:metricKey", controllers.DeleteMetricType)
+}
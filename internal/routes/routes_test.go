@@ -0,0 +1,2328 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/blobstore"
+	"github.com/zenkimoto/vitals-server-api/internal/controllers"
+	"github.com/zenkimoto/vitals-server-api/internal/env"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+	"github.com/zenkimoto/vitals-server-api/internal/util"
+)
+
+const testPassword = "secret-password"
+
+// setupRouter boots the API routes against a fresh in-memory SQLite
+// database that only lives for the duration of the test.
+func setupRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	models.InitializeSqliteDatabase(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	require.NotNil(t, models.DB, "database was not initialized")
+
+	t.Cleanup(func() {
+		db, err := models.DB.DB()
+		if err == nil {
+			db.Close()
+		}
+	})
+
+	blobstore.Configure(blobstore.NewLocal(t.TempDir()))
+	t.Cleanup(func() { blobstore.Configure(nil) })
+
+	router := gin.New()
+	Register(router)
+
+	return router
+}
+
+func createUser(t *testing.T, userName string) models.User {
+	t.Helper()
+
+	hash, err := util.HashPassword(testPassword)
+	require.NoError(t, err)
+
+	user := models.User{FirstName: "Test", LastName: "User", UserName: userName, PasswordHash: hash}
+	require.NoError(t, models.DB.Create(&user).Error)
+
+	return user
+}
+
+func login(t *testing.T, router *gin.Engine, userName string) string {
+	t.Helper()
+
+	w := request(router, http.MethodPost, V1+"/auth", "", map[string]string{"username": userName, "password": testPassword})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var res struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &res)
+
+	return res.Token
+}
+
+func request(router *gin.Engine, method string, path string, token string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+// problem decodes a problem details response and checks the status,
+// content type and error code.
+func problem(t *testing.T, w *httptest.ResponseRecorder, status int, code apierror.Code) payload.Problem {
+	t.Helper()
+
+	require.Equal(t, status, w.Code, w.Body.String())
+	assert.Equal(t, apierror.ContentType, w.Header().Get("Content-Type"))
+
+	var p payload.Problem
+	decode(t, w, &p)
+	assert.Equal(t, status, p.Status)
+	assert.Equal(t, string(code), p.Code)
+
+	return p
+}
+
+// upload posts data as the file field of a multipart form.
+func upload(router *gin.Engine, path string, token string, fileName string, data []byte) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, _ := form.CreateFormFile("file", fileName)
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), v), w.Body.String())
+}
+
+func TestHealthCheck(t *testing.T) {
+	router := setupRouter(t)
+
+	w := request(router, http.MethodGet, V1+"/health-check", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestAuthFailures(t *testing.T) {
+	router := setupRouter(t)
+	createUser(t, "alice")
+
+	w := request(router, http.MethodPost, V1+"/auth", "", map[string]string{"username": "alice", "password": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = request(router, http.MethodPost, V1+"/auth", "", map[string]string{"username": "nobody", "password": testPassword})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = request(router, http.MethodPost, V1+"/auth", "", map[string]string{"username": "alice"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = request(router, http.MethodGet, V1+"/users", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = request(router, http.MethodGet, V1+"/users", "not-a-jwt", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = request(router, http.MethodPost, V1+"/token/validate", "", map[string]string{"token": "not-a-jwt"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestProblemDetails(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	w := request(router, http.MethodGet, V1+"/users", "", nil)
+	p := problem(t, w, http.StatusUnauthorized, apierror.CodeUnauthorized)
+	assert.Equal(t, V1+"/users", p.Instance)
+	assert.NotEmpty(t, p.RequestId)
+	assert.Equal(t, w.Header().Get(apierror.RequestIdHeader), p.RequestId)
+
+	path := fmt.Sprintf(V1+"/users/%d/blood-pressure", alice.ID)
+
+	w = request(router, http.MethodPost, path, token, map[string]any{"systolic": 120})
+	p = problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, []payload.FieldError{{Field: "diastolic", Code: "required", Message: "is required"}}, p.Errors)
+
+	w = request(router, http.MethodPost, path, token, map[string]any{"systolic": "high", "diastolic": 80})
+	p = problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+	require.Len(t, p.Errors, 1)
+	assert.Equal(t, "systolic", p.Errors[0].Field)
+
+	w = request(router, http.MethodPost, path, token, nil)
+	problem(t, w, http.StatusBadRequest, apierror.CodeBadRequest)
+
+	w = request(router, http.MethodGet, path+"/999", token, nil)
+	p = problem(t, w, http.StatusNotFound, apierror.CodeNotFound)
+	assert.Equal(t, "Blood Pressure not found", p.Detail)
+
+	w = request(router, http.MethodGet, V1+"/unknown", token, nil)
+	problem(t, w, http.StatusNotFound, apierror.CodeNotFound)
+
+	w = request(router, http.MethodPost, V1+"/auth", "", map[string]string{"username": "alice", "password": "wrong"})
+	problem(t, w, http.StatusUnauthorized, apierror.CodeUnauthorized)
+}
+
+func TestVitalPlausibility(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	path := fmt.Sprintf(V1+"/users/%d/blood-pressure", alice.ID)
+
+	w := request(router, http.MethodPost, path, token, map[string]any{"systolic": 5, "diastolic": 900})
+	p := problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+	assert.Len(t, p.Errors, 3)
+
+	future := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	w = request(router, http.MethodPost, path, token, map[string]any{"systolic": 120, "diastolic": 80, "time": future})
+	p = problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "time", p.Errors[0].Field)
+
+	w = request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/weight", alice.ID), token, map[string]any{"weight": -10})
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+
+	// Unusual values are saved and returned with warnings
+	w = request(router, http.MethodPost, path, token, map[string]any{"systolic": 200, "diastolic": 100})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var res payload.BloodPressureResponse
+	decode(t, w, &res)
+	assert.NotZero(t, res.Id)
+	require.Len(t, res.Warnings, 1)
+	assert.Equal(t, "systolic", res.Warnings[0].Field)
+
+	w = request(router, http.MethodGet, fmt.Sprintf("%s/%d", path, res.Id), token, nil)
+	assert.NotContains(t, w.Body.String(), "warnings")
+}
+
+func TestBloodPressureDetails(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/blood-pressure", alice.ID)
+
+	w := request(router, http.MethodPost, base, token, map[string]any{
+		"systolic": 130, "diastolic": 85, "pulse": 72, "arm": "left", "posture": "seated", "irregularHeartbeat": false,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var bp map[string]any
+	decode(t, w, &bp)
+	assert.Equal(t, 72.0, bp["pulse"])
+	assert.Equal(t, "left", bp["arm"])
+	assert.Equal(t, "seated", bp["posture"])
+	assert.Equal(t, false, bp["irregularHeartbeat"])
+	assert.Equal(t, 45.0, bp["pulsePressure"])
+	assert.Equal(t, 100.0, bp["meanArterialPressure"])
+
+	// Readings without the optional fields keep the original shape
+	w = request(router, http.MethodPost, base, token, map[string]any{"systolic": 120, "diastolic": 80})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var legacy map[string]any
+	decode(t, w, &legacy)
+	assert.NotContains(t, legacy, "pulse")
+	assert.NotContains(t, legacy, "arm")
+	assert.NotContains(t, legacy, "irregularHeartbeat")
+	assert.Equal(t, 93.0, legacy["meanArterialPressure"])
+
+	p := problem(t, request(router, http.MethodPost, base, token, map[string]any{"systolic": 120, "diastolic": 80, "posture": "walking"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "posture", p.Errors[0].Field)
+
+	p = problem(t, request(router, http.MethodPost, base, token, map[string]any{"systolic": 120, "diastolic": 80, "pulse": 400}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "pulse", p.Errors[0].Field)
+}
+
+func TestBloodGlucose(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/glucose", alice.ID)
+
+	readings := []map[string]any{
+		{"value": 5.5, "unit": "mmol/L", "time": "2024-03-01T07:00:00Z"},
+		{"value": 100, "unit": "mg/dL", "time": "2024-03-01T12:00:00Z"},
+		{"value": 200, "unit": "mg/dL", "time": "2024-03-02T12:00:00Z"},
+		{"value": 50, "unit": "mg/dL", "time": "2024-03-03T12:00:00Z"},
+		{"value": 300, "unit": "mg/dL", "time": "2024-04-01T12:00:00Z"},
+	}
+
+	var first payload.BloodGlucoseResponse
+	for i, r := range readings {
+		w := request(router, http.MethodPost, base, token, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		if i == 0 {
+			decode(t, w, &first)
+		}
+	}
+
+	// Values are returned in the submitted unit unless another is requested
+	assert.Equal(t, 5.5, first.Value)
+	assert.Equal(t, "mmol/L", first.Unit)
+
+	w := request(router, http.MethodGet, fmt.Sprintf("%s/%d?unit=mg/dL", base, first.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var converted payload.BloodGlucoseResponse
+	decode(t, w, &converted)
+	assert.Equal(t, 99.0, converted.Value)
+	assert.Equal(t, "mg/dL", converted.Unit)
+
+	w = request(router, http.MethodGet, base+"?unit=mmol/L", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list []payload.BloodGlucoseResponse
+	decode(t, w, &list)
+	require.Len(t, list, 5)
+	assert.Equal(t, 16.7, list[0].Value)
+
+	w = request(router, http.MethodGet, base+"?unit=g", token, nil)
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+
+	// Time in range over March
+	w = request(router, http.MethodGet, base+"/stats?from=2024-03-01&to=2024-03-31", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var stats payload.BloodGlucoseStatsResponse
+	decode(t, w, &stats)
+	assert.Equal(t, 4, stats.Count)
+	assert.Equal(t, "mg/dL", stats.Unit)
+	assert.Equal(t, 50.0, stats.Min)
+	assert.Equal(t, 200.0, stats.Max)
+	assert.Equal(t, 112.0, stats.Mean)
+	assert.Equal(t, 25.0, stats.VeryLowPercent)
+	assert.Equal(t, 0.0, stats.LowPercent)
+	assert.Equal(t, 50.0, stats.InRangePercent)
+	assert.Equal(t, 25.0, stats.HighPercent)
+	assert.Equal(t, 0.0, stats.VeryHighPercent)
+
+	w = request(router, http.MethodGet, base+"/stats?from=2024-03-01&to=2024-03-31&unit=mmol/L&low=4&high=6", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &stats)
+	assert.Equal(t, 4.0, stats.TargetLow)
+	assert.Equal(t, 6.0, stats.TargetHigh)
+	assert.Equal(t, 50.0, stats.InRangePercent)
+	assert.Equal(t, 25.0, stats.HighPercent)
+
+	// A custom target moves readings between the low, target and high ranges
+	w = request(router, http.MethodGet, base+"/stats?from=2024-03-01&to=2024-03-31&low=100&high=190", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &stats)
+	assert.Equal(t, 100.0, stats.TargetLow)
+	assert.Equal(t, 190.0, stats.TargetHigh)
+	assert.Equal(t, 25.0, stats.VeryLowPercent)
+	assert.Equal(t, 25.0, stats.LowPercent)
+	assert.Equal(t, 25.0, stats.InRangePercent)
+	assert.Equal(t, 25.0, stats.HighPercent)
+	assert.Equal(t, 0.0, stats.VeryHighPercent)
+
+	// The target must lie between the very low and very high limits
+	p := problem(t, request(router, http.MethodGet, base+"/stats?low=50", token, nil),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "low", p.Errors[0].Field)
+
+	p = problem(t, request(router, http.MethodGet, base+"/stats?unit=mmol/L&high=15", token, nil),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "high", p.Errors[0].Field)
+
+	w = request(router, http.MethodGet, base+"/stats?from=2024-04-01&to=2024-03-01", token, nil)
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+
+	// Conversions start from the stored value, not the rounded one
+	w = request(router, http.MethodPost, base+"?unit=mg/dL", token, map[string]any{"value": 5.55, "unit": "mmol/L"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &converted)
+	assert.Equal(t, 100.0, converted.Value)
+}
+
+func TestSleepSessions(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/sleep", alice.ID)
+
+	w := request(router, http.MethodPost, base, token, map[string]any{
+		"start":   "2024-03-01T23:00:00+01:00",
+		"end":     "2024-03-02T07:00:00+01:00",
+		"stages":  map[string]any{"light": 240, "deep": 90, "rem": 120, "awake": 30},
+		"quality": 4,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var night payload.SleepSessionResponse
+	decode(t, w, &night)
+	assert.Equal(t, "2024-03-01", night.NightOf)
+	assert.Equal(t, 480, night.DurationMinutes)
+	require.NotNil(t, night.Stages)
+	assert.Equal(t, uint16(90), night.Stages.Deep)
+
+	// A session after midnight belongs to the previous night
+	w = request(router, http.MethodPost, base, token, map[string]any{
+		"start":   "2024-03-03T01:30:00+01:00",
+		"end":     "2024-03-03T06:30:00+01:00",
+		"quality": 2,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var late payload.SleepSessionResponse
+	decode(t, w, &late)
+	assert.Equal(t, "2024-03-02", late.NightOf)
+	assert.Nil(t, late.Stages)
+
+	// Overlapping sessions are rejected
+	w = request(router, http.MethodPost, base, token, map[string]any{
+		"start": "2024-03-02T06:00:00+01:00",
+		"end":   "2024-03-02T08:00:00+01:00",
+	})
+	problem(t, w, http.StatusConflict, apierror.CodeConflict)
+
+	// A nap on the same day
+	w = request(router, http.MethodPost, base, token, map[string]any{
+		"start": "2024-03-02T14:00:00+01:00",
+		"end":   "2024-03-02T14:30:00+01:00",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Updating a session does not conflict with itself
+	w = request(router, http.MethodPut, fmt.Sprintf("%s/%d", base, late.Id), token, map[string]any{
+		"start":   "2024-03-03T01:00:00+01:00",
+		"end":     "2024-03-03T06:30:00+01:00",
+		"quality": 3,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	p := problem(t, request(router, http.MethodPost, base, token, map[string]any{
+		"start":  "2024-03-05T23:00:00+01:00",
+		"end":    "2024-03-05T22:00:00+01:00",
+		"stages": map[string]any{"light": 600},
+	}), http.StatusBadRequest, apierror.CodeValidation)
+	require.Len(t, p.Errors, 1)
+	assert.Equal(t, "end", p.Errors[0].Field)
+
+	p = problem(t, request(router, http.MethodPost, base, token, map[string]any{
+		"start":  "2024-03-05T23:00:00+01:00",
+		"end":    "2024-03-06T06:00:00+01:00",
+		"stages": map[string]any{"light": 600},
+	}), http.StatusBadRequest, apierror.CodeValidation)
+	require.Len(t, p.Errors, 1)
+	assert.Equal(t, "stages", p.Errors[0].Field)
+
+	p = problem(t, request(router, http.MethodPost, base, token, map[string]any{"end": "2024-03-06T06:00:00+01:00"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "start", p.Errors[0].Field)
+
+	w = request(router, http.MethodGet, base+"/nightly?from=2024-03-01&to=2024-03-02", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var nights []payload.SleepNightResponse
+	decode(t, w, &nights)
+	require.Len(t, nights, 2)
+
+	assert.Equal(t, "2024-03-01", nights[0].NightOf)
+	assert.Equal(t, 1, nights[0].Sessions)
+	assert.Equal(t, 480, nights[0].TimeInBedMinutes)
+	assert.Equal(t, 450, nights[0].AsleepMinutes)
+	require.NotNil(t, nights[0].Quality)
+	assert.Equal(t, 4.0, *nights[0].Quality)
+
+	assert.Equal(t, "2024-03-02", nights[1].NightOf)
+	assert.Equal(t, 2, nights[1].Sessions)
+	assert.Equal(t, 360, nights[1].TimeInBedMinutes)
+	assert.Nil(t, nights[1].Stages)
+	assert.Equal(t, 3.0, *nights[1].Quality)
+
+	// The range is capped like the periods of the stats endpoint
+	p = problem(t, request(router, http.MethodGet, base+"/nightly?from=2020-01-01&to=2024-03-02", token, nil),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "from", p.Errors[0].Field)
+}
+
+func TestActivities(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/activities", alice.ID)
+
+	post := func(body map[string]any) payload.ActivityResponse {
+		t.Helper()
+
+		w := request(router, http.MethodPost, base, token, body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res payload.ActivityResponse
+		decode(t, w, &res)
+		return res
+	}
+
+	// Without a weight the calories can not be estimated
+	res := post(map[string]any{"type": "walk", "durationMinutes": 30, "time": "2024-01-01T08:00:00Z"})
+	assert.Nil(t, res.Calories)
+	assert.False(t, res.CaloriesEstimated)
+
+	for _, weight := range []map[string]any{
+		{"weight": 70, "time": "2024-03-01T08:00:00Z"},
+		{"weight": 80, "time": "2024-04-01T08:00:00Z"},
+	} {
+		w := request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/weight", alice.ID), token, weight)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// The weight at the time of the activity is used
+	res = post(map[string]any{"type": "run", "durationMinutes": 30, "time": "2024-03-04T07:00:00Z"})
+	require.NotNil(t, res.Calories)
+	assert.Equal(t, 343.0, *res.Calories)
+	assert.True(t, res.CaloriesEstimated)
+
+	// Activities before the first weight use the latest weight
+	res = post(map[string]any{"type": "walk", "durationMinutes": 60, "time": "2024-02-15T07:00:00Z"})
+	assert.Equal(t, 280.0, *res.Calories)
+
+	// Provided calories are kept
+	res = post(map[string]any{"type": "cycle", "durationMinutes": 45, "calories": 500, "time": "2024-03-12T18:00:00Z"})
+	assert.Equal(t, 500.0, *res.Calories)
+	assert.False(t, res.CaloriesEstimated)
+
+	post(map[string]any{"type": "walk", "durationMinutes": 60, "distanceKm": 5.2, "time": "2024-03-06T18:00:00Z"})
+	post(map[string]any{"type": "yoga", "durationMinutes": 60, "time": "2024-03-10T18:00:00Z"})
+
+	p := problem(t, request(router, http.MethodPost, base, token, map[string]any{"type": "dance", "durationMinutes": 30}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "type", p.Errors[0].Field)
+
+	w := request(router, http.MethodPost, base, token, map[string]any{"type": "walk", "durationMinutes": 600})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &res)
+	require.Len(t, res.Warnings, 1)
+	assert.Equal(t, "durationMinutes", res.Warnings[0].Field)
+
+	w = request(router, http.MethodGet, base+"/weekly?from=2024-03-04&to=2024-03-24", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var weeks []payload.ActivityWeekResponse
+	decode(t, w, &weeks)
+	require.Len(t, weeks, 3)
+
+	assert.Equal(t, "2024-03-04", weeks[0].WeekStart)
+	assert.Equal(t, 3, weeks[0].Sessions)
+	assert.Equal(t, 150, weeks[0].ActiveMinutes)
+	assert.Equal(t, 60, weeks[0].ModerateMinutes)
+	assert.Equal(t, 30, weeks[0].VigorousMinutes)
+	assert.Equal(t, 120, weeks[0].GoalMinutes)
+	assert.False(t, weeks[0].GoalMet)
+	assert.Equal(t, 5.2, weeks[0].DistanceKm)
+	assert.Equal(t, 343.0+245+175, weeks[0].Calories)
+	assert.Equal(t, map[string]int{"run": 30, "walk": 60, "yoga": 60}, weeks[0].Types)
+
+	assert.Equal(t, "2024-03-11", weeks[1].WeekStart)
+	assert.Equal(t, 90, weeks[1].GoalMinutes)
+
+	assert.Equal(t, "2024-03-18", weeks[2].WeekStart)
+	assert.Equal(t, 0, weeks[2].Sessions)
+
+	// The range is limited to 400 weeks
+	p = problem(t, request(router, http.MethodGet, base+"/weekly?from=2010-01-01&to=2024-03-24", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "from", p.Errors[0].Field)
+}
+
+func TestIllnessVitals(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	temperature := fmt.Sprintf(V1+"/users/%d/temperature", alice.ID)
+
+	w := request(router, http.MethodPost, temperature, token, map[string]any{"value": 101.3, "unit": "F", "site": "oral"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var fever payload.BodyTemperatureResponse
+	decode(t, w, &fever)
+	assert.Equal(t, 101.3, fever.Value)
+	assert.True(t, fever.Fever)
+
+	w = request(router, http.MethodGet, fmt.Sprintf("%s/%d?unit=C", temperature, fever.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decode(t, w, &fever)
+	assert.Equal(t, 38.5, fever.Value)
+	assert.Equal(t, "C", fever.Unit)
+
+	// Conversions start from the stored temperature, not the rounded one
+	w = request(router, http.MethodPost, temperature+"?unit=F", token, map[string]any{"value": 37.04, "unit": "C", "time": "2020-01-01T08:00:00Z"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &fever)
+	assert.Equal(t, 98.7, fever.Value)
+
+	w = request(router, http.MethodPost, temperature, token, map[string]any{"value": 36.6, "unit": "C"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var normal payload.BodyTemperatureResponse
+	decode(t, w, &normal)
+	assert.False(t, normal.Fever)
+	assert.Empty(t, normal.Warnings)
+
+	// 36.6 °F is plausible as Celsius but not as Fahrenheit
+	p := problem(t, request(router, http.MethodPost, temperature, token, map[string]any{"value": 36.6, "unit": "F"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "value", p.Errors[0].Field)
+
+	w = request(router, http.MethodGet, temperature+"?unit=K", token, nil)
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+
+	spo2 := fmt.Sprintf(V1+"/users/%d/spo2", alice.ID)
+
+	w = request(router, http.MethodPost, spo2, token, map[string]any{"percent": 89, "pulse": 110})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var low payload.OxygenSaturationResponse
+	decode(t, w, &low)
+	assert.True(t, low.LowOxygen)
+	assert.Equal(t, uint16(110), *low.Pulse)
+
+	w = request(router, http.MethodPost, spo2, token, map[string]any{"percent": 98})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var ok payload.OxygenSaturationResponse
+	decode(t, w, &ok)
+	assert.False(t, ok.LowOxygen)
+	assert.Nil(t, ok.Pulse)
+
+	p = problem(t, request(router, http.MethodPost, spo2, token, map[string]any{"percent": 101}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "percent", p.Errors[0].Field)
+}
+
+func TestMedications(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	bob := createUser(t, "bob")
+	token := login(t, router, "alice")
+
+	medications := fmt.Sprintf(V1+"/users/%d/medications", alice.ID)
+	doses := fmt.Sprintf(V1+"/users/%d/doses", alice.ID)
+
+	w := request(router, http.MethodPost, medications, token, map[string]any{
+		"name": "Lisinopril", "dose": "10 mg", "schedule": []string{"08:00", "20:00"}, "startDate": "2024-03-01",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var lisinopril payload.MedicationResponse
+	decode(t, w, &lisinopril)
+	assert.Equal(t, []string{"08:00", "20:00"}, lisinopril.Schedule)
+	assert.Nil(t, lisinopril.EndDate)
+
+	w = request(router, http.MethodPost, medications, token, map[string]any{"name": "Ibuprofen", "dose": "200 mg"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var ibuprofen payload.MedicationResponse
+	decode(t, w, &ibuprofen)
+	assert.Empty(t, ibuprofen.Schedule)
+	assert.Equal(t, time.Now().Format(time.DateOnly), ibuprofen.StartDate)
+
+	p := problem(t, request(router, http.MethodPost, medications, token, map[string]any{"name": "Metoprolol", "schedule": []string{"8am"}}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "schedule[0]", p.Errors[0].Field)
+
+	p = problem(t, request(router, http.MethodPost, medications, token, map[string]any{"name": "Metoprolol", "startDate": "2024-03-01", "endDate": "2024-02-01"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "endDate", p.Errors[0].Field)
+
+	// A dose time can only be scheduled once
+	p = problem(t, request(router, http.MethodPost, medications, token, map[string]any{"name": "Metoprolol", "schedule": []string{"08:00", "20:00", "08:00"}}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "schedule[2]", p.Errors[0].Field)
+	assert.Equal(t, "unique", p.Errors[0].Code)
+
+	// Updates keep the start date unless a new one is given
+	w = request(router, http.MethodPut, fmt.Sprintf("%s/%d", medications, lisinopril.Id), token, map[string]any{
+		"name": "Lisinopril", "dose": "20 mg", "schedule": []string{"08:00", "20:00"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &lisinopril)
+	assert.Equal(t, "20 mg", lisinopril.Dose)
+	assert.Equal(t, "2024-03-01", lisinopril.StartDate)
+
+	w = request(router, http.MethodGet, medications, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list []payload.MedicationResponse
+	decode(t, w, &list)
+	require.Len(t, list, 2)
+	assert.Equal(t, "Ibuprofen", list[0].Name)
+
+	w = request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/medications/%d", bob.ID, lisinopril.Id), token, nil)
+	problem(t, w, http.StatusNotFound, apierror.CodeNotFound)
+
+	for _, dose := range []map[string]any{
+		{"status": "taken", "time": "2024-03-01T08:05:00Z"},
+		{"status": "late", "time": "2024-03-01T21:30:00Z"},
+		{"status": "taken", "time": "2024-03-02T08:00:00Z"},
+		{"status": "skipped", "time": "2024-03-02T20:00:00Z"},
+		{"status": "taken", "time": "2024-03-03T08:00:00Z"},
+	} {
+		dose["medicationId"] = lisinopril.Id
+		w := request(router, http.MethodPost, doses, token, dose)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = request(router, http.MethodPost, doses, token, map[string]any{"medicationId": ibuprofen.Id, "status": "taken", "time": "2024-03-02T12:00:00Z"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Doses must reference one of the user's medications
+	other := models.Medication{UserID: bob.ID, Name: "Aspirin", StartDate: "2024-01-01"}
+	require.NoError(t, models.DB.Create(&other).Error)
+
+	p = problem(t, request(router, http.MethodPost, doses, token, map[string]any{"medicationId": other.ID, "status": "taken"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "medicationId", p.Errors[0].Field)
+
+	for _, bp := range []map[string]any{
+		{"systolic": 130, "diastolic": 85, "time": "2024-03-01T09:00:00Z"},
+		{"systolic": 120, "diastolic": 75, "time": "2024-03-01T19:00:00Z"},
+		{"systolic": 150, "diastolic": 95, "time": "2024-03-02T09:00:00Z"},
+		{"systolic": 140, "diastolic": 90, "time": "2024-03-03T09:00:00Z"},
+	} {
+		w := request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/blood-pressure", alice.ID), token, bp)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = request(router, http.MethodGet, medications+"/adherence?from=2024-03-01&to=2024-03-03", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var report payload.AdherenceResponse
+	decode(t, w, &report)
+	assert.Equal(t, "2024-03-01", report.From)
+	assert.Equal(t, "2024-03-03", report.To)
+
+	// As needed medications are not scheduled
+	require.Len(t, report.Medications, 1)
+	assert.Equal(t, lisinopril.Id, report.Medications[0].MedicationId)
+
+	assert.Equal(t, 6, report.Scheduled)
+	assert.Equal(t, 4, report.Taken)
+	assert.Equal(t, 1, report.Late)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Missed)
+	assert.Equal(t, 66.7, report.AdherencePercent)
+
+	adherent := report.BloodPressure.AdherentDays
+	assert.Equal(t, 1, adherent.Days)
+	assert.Equal(t, 2, adherent.Readings)
+	assert.Equal(t, 125.0, *adherent.Systolic)
+	assert.Equal(t, 80.0, *adherent.Diastolic)
+
+	nonAdherent := report.BloodPressure.NonAdherentDays
+	assert.Equal(t, 2, nonAdherent.Days)
+	assert.Equal(t, 2, nonAdherent.Readings)
+	assert.Equal(t, 145.0, *nonAdherent.Systolic)
+	assert.Equal(t, 93.0, *nonAdherent.Diastolic)
+
+	// Doses due after the end of the window are not counted
+	w = request(router, http.MethodGet, medications+"/adherence?from=2024-03-01T00:00:00Z&to=2024-03-01T12:00:00Z", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &report)
+	assert.Equal(t, 1, report.Scheduled)
+	assert.Equal(t, 100.0, report.AdherencePercent)
+
+	// The range is capped like the periods of the stats endpoint
+	p = problem(t, request(router, http.MethodGet, medications+"/adherence?from=2020-01-01&to=2024-03-03", token, nil),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "from", p.Errors[0].Field)
+
+	// Deleting a medication deletes its doses
+	w = request(router, http.MethodDelete, fmt.Sprintf("%s/%d", medications, lisinopril.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(router, http.MethodGet, doses, token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var remaining []payload.DoseResponse
+	decode(t, w, &remaining)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, ibuprofen.Id, remaining[0].MedicationId)
+
+	w = request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/timeline?type=doses", alice.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var timeline []payload.TimelineEntry
+	decode(t, w, &timeline)
+	assert.Len(t, timeline, 1)
+}
+
+func TestJournal(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	bob := createUser(t, "bob")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/journal", alice.ID)
+
+	w := request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/blood-pressure", alice.ID), token, map[string]any{"systolic": 165, "diastolic": 100})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var bp payload.BloodPressureResponse
+	decode(t, w, &bp)
+
+	post := func(body map[string]any) payload.JournalResponse {
+		t.Helper()
+
+		w := request(router, http.MethodPost, base, token, body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res payload.JournalResponse
+		decode(t, w, &res)
+		return res
+	}
+
+	headache := post(map[string]any{
+		"mood":     2,
+		"symptoms": []string{"headache", "dizziness"},
+		"links":    []map[string]any{{"type": "blood-pressure", "id": bp.Id}},
+		"time":     "2024-03-01T09:00:00Z",
+	})
+	assert.Equal(t, []string{"headache", "dizziness"}, headache.Symptoms)
+	assert.Equal(t, []payload.JournalLink{{Type: "blood-pressure", Id: bp.Id}}, headache.Links)
+
+	post(map[string]any{"mood": 3, "symptoms": []string{"fatigue"}, "time": "2024-03-02T09:00:00Z"})
+	post(map[string]any{"mood": 4, "notes": "Feeling good", "time": "2024-03-03T09:00:00Z"})
+	post(map[string]any{"symptoms": []string{"headache"}, "time": "2024-03-04T09:00:00Z"})
+
+	list := func(query string) []payload.JournalResponse {
+		t.Helper()
+
+		w := request(router, http.MethodGet, base+query, token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res []payload.JournalResponse
+		decode(t, w, &res)
+		return res
+	}
+
+	assert.Len(t, list(""), 4)
+	assert.Len(t, list("?symptom=headache"), 2)
+	assert.Len(t, list("?symptom=headache&symptom=fatigue"), 3)
+	assert.Len(t, list("?symptom=headache&from=2024-03-02"), 1)
+	assert.Len(t, list("?from=2024-03-02&to=2024-03-03"), 2)
+
+	linked := list(fmt.Sprintf("?reading=blood-pressure:%d", bp.Id))
+	require.Len(t, linked, 1)
+	assert.Equal(t, headache.Id, linked[0].Id)
+
+	problem(t, request(router, http.MethodGet, base+"?reading=blood-pressure", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+	problem(t, request(router, http.MethodGet, base+"?from=yesterday", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+
+	// Updates replace the symptoms and links
+	w = request(router, http.MethodPut, fmt.Sprintf("%s/%d", base, headache.Id), token, map[string]any{"mood": 3, "symptoms": []string{"fatigue"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(router, http.MethodGet, fmt.Sprintf("%s/%d", base, headache.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var updated payload.JournalResponse
+	decode(t, w, &updated)
+	assert.Equal(t, []string{"fatigue"}, updated.Symptoms)
+	assert.Empty(t, updated.Links)
+	assert.Len(t, list("?symptom=headache"), 1)
+
+	p := problem(t, request(router, http.MethodPost, base, token, map[string]any{"symptoms": []string{"headache", "grumpiness", "headache"}}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, []string{"symptoms[1]", "symptoms[2]"}, []string{p.Errors[0].Field, p.Errors[1].Field})
+
+	p = problem(t, request(router, http.MethodPost, base, token, map[string]any{"mood": 3, "links": []map[string]any{{"type": "mood", "id": 1}}}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "links[0].type", p.Errors[0].Field)
+
+	// Links must refer to the user's own readings
+	other := models.BloodPressure{Sys: 120, Dia: 80, Reading: models.Reading{UserID: bob.ID, Time: time.Now()}}
+	require.NoError(t, models.DB.Create(&other).Error)
+
+	p = problem(t, request(router, http.MethodPost, base, token, map[string]any{"mood": 3, "links": []map[string]any{{"type": "blood-pressure", "id": other.ID}}}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "links[0].id", p.Errors[0].Field)
+
+	w = request(router, http.MethodGet, V1+"/symptoms", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var symptoms []string
+	decode(t, w, &symptoms)
+	assert.Contains(t, symptoms, "headache")
+}
+
+func TestBodyComposition(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	composition := fmt.Sprintf(V1+"/users/%d/body-composition", alice.ID)
+	measurements := fmt.Sprintf(V1+"/users/%d/measurements", alice.ID)
+
+	// Metrics derived from the height are omitted until the user has one
+	w := request(router, http.MethodPost, composition, token, map[string]any{"weightKg": 80, "bodyFatPercent": 25})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var bc payload.BodyCompositionResponse
+	decode(t, w, &bc)
+	assert.Equal(t, 60.0, *bc.LeanMassKg)
+	assert.Nil(t, bc.Bmi)
+
+	w = request(router, http.MethodPost, measurements, token, map[string]any{"waistCm": 90, "hipCm": 100})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var bm payload.BodyMeasurementResponse
+	decode(t, w, &bm)
+	assert.Equal(t, 0.9, *bm.WaistToHipRatio)
+	assert.Nil(t, bm.WaistToHeightRatio)
+
+	w = request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", alice.ID), token, map[string]any{"firstName": "Alice", "lastName": "Smith", "heightCm": 180})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(router, http.MethodGet, fmt.Sprintf("%s/%d", composition, bc.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decode(t, w, &bc)
+	assert.Equal(t, 24.7, *bc.Bmi)
+
+	w = request(router, http.MethodGet, measurements, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list []payload.BodyMeasurementResponse
+	decode(t, w, &list)
+	require.Len(t, list, 1)
+	assert.Equal(t, 0.5, *list[0].WaistToHeightRatio)
+
+	// Without a body fat percentage there is no lean mass
+	w = request(router, http.MethodPost, composition, token, map[string]any{"weightKg": 80})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var weightOnly payload.BodyCompositionResponse
+	decode(t, w, &weightOnly)
+	assert.Nil(t, weightOnly.LeanMassKg)
+
+	p := problem(t, request(router, http.MethodPost, composition, token, map[string]any{"weightKg": 80, "muscleMassKg": 90}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "muscleMassKg", p.Errors[0].Field)
+
+	p = problem(t, request(router, http.MethodPost, measurements, token, map[string]any{"neckCm": 400}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "neckCm", p.Errors[0].Field)
+}
+
+func TestNutrition(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/nutrition", alice.ID)
+	sugar := fmt.Sprintf(V1+"/users/%d/sugar", alice.ID)
+
+	sugarList := func() []payload.SugarIntakeResponse {
+		t.Helper()
+
+		w := request(router, http.MethodGet, sugar, token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res []payload.SugarIntakeResponse
+		decode(t, w, &res)
+		return res
+	}
+
+	w := request(router, http.MethodPost, base, token, map[string]any{
+		"name": "Oatmeal", "mealType": "breakfast", "calories": 320, "carbsG": 54, "sugarG": 12.4, "time": "2024-03-01T08:00:00Z",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var oatmeal payload.NutritionResponse
+	decode(t, w, &oatmeal)
+
+	w = request(router, http.MethodPost, base, token, map[string]any{
+		"name": "Pasta", "mealType": "dinner", "calories": 650, "proteinG": 22, "carbsG": 90, "fatG": 18, "sodiumMg": 800, "time": "2024-03-01T19:00:00Z",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(router, http.MethodPost, base, token, map[string]any{"name": "Apple", "calories": 95, "sugarG": 19, "time": "2024-03-02T15:00:00Z"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(router, http.MethodPost, sugar, token, map[string]any{"grams": 30, "time": "2024-03-02T16:00:00Z"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Sugar logged with a meal shows up in the sugar intake views
+	list := sugarList()
+	require.Len(t, list, 3)
+	assert.Equal(t, uint(12), list[2].Grams)
+	require.NotNil(t, list[2].NutritionEntryId)
+	assert.Equal(t, oatmeal.Id, *list[2].NutritionEntryId)
+	assert.Nil(t, list[0].NutritionEntryId)
+
+	// and can only be changed through the nutrition entry
+	linked := fmt.Sprintf("%s/%d", sugar, list[2].Id)
+	problem(t, request(router, http.MethodPut, linked, token, map[string]any{"grams": 5}), http.StatusConflict, apierror.CodeConflict)
+	problem(t, request(router, http.MethodDelete, linked, token, nil), http.StatusConflict, apierror.CodeConflict)
+
+	w = request(router, http.MethodPut, fmt.Sprintf("%s/%d", base, oatmeal.Id), token, map[string]any{
+		"name": "Oatmeal", "mealType": "breakfast", "calories": 350, "carbsG": 60, "sugarG": 20, "time": "2024-03-01T08:30:00Z",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	list = sugarList()
+	require.Len(t, list, 3)
+	assert.Equal(t, uint(20), list[2].Grams)
+	assert.Equal(t, "2024-03-01T08:30:00Z", list[2].Time.Format(time.RFC3339))
+
+	// Removing the sugar removes the sugar intake record
+	w = request(router, http.MethodPut, fmt.Sprintf("%s/%d", base, oatmeal.Id), token, map[string]any{"name": "Oatmeal", "calories": 350})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Len(t, sugarList(), 2)
+
+	w = request(router, http.MethodPut, fmt.Sprintf("%s/%d", base, oatmeal.Id), token, map[string]any{"name": "Oatmeal", "mealType": "breakfast", "calories": 320, "carbsG": 54, "sugarG": 12})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Len(t, sugarList(), 3)
+
+	w = request(router, http.MethodGet, base+"/daily?from=2024-03-01&to=2024-03-03", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var days []payload.NutritionDayResponse
+	decode(t, w, &days)
+	require.Len(t, days, 3)
+
+	assert.Equal(t, "2024-03-01", days[0].Date)
+	assert.Equal(t, 2, days[0].Entries)
+	assert.Equal(t, 970.0, days[0].Calories)
+	assert.Equal(t, 144.0, days[0].CarbsG)
+	assert.Equal(t, 12.0, days[0].SugarG)
+	assert.Equal(t, 800.0, days[0].SodiumMg)
+	assert.Equal(t, map[string]float64{"breakfast": 320, "dinner": 650}, days[0].MealCalories)
+
+	assert.Equal(t, 1, days[1].Entries)
+	assert.Equal(t, 19.0, days[1].SugarG)
+	assert.Equal(t, 0, days[2].Entries)
+
+	// The range is capped like the periods of the stats endpoint
+	p := problem(t, request(router, http.MethodGet, base+"/daily?from=2020-01-01&to=2024-03-03", token, nil),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "from", p.Errors[0].Field)
+
+	// Deleting the entry deletes its sugar intake
+	w = request(router, http.MethodDelete, fmt.Sprintf("%s/%d", base, oatmeal.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Len(t, sugarList(), 2)
+
+	p = problem(t, request(router, http.MethodPost, base, token, map[string]any{"name": "Cake", "carbsG": 10, "sugarG": 30}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "sugarG", p.Errors[0].Field)
+}
+
+func TestCustomMetrics(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	bob := createUser(t, "bob")
+	token := login(t, router, "alice")
+	bobToken := login(t, router, "bob")
+
+	require.NoError(t, models.DB.Model(&alice).Update("role", models.RoleAdmin).Error)
+
+	types := fmt.Sprintf(V1+"/users/%d/metric-types", bob.ID)
+	metrics := fmt.Sprintf(V1+"/users/%d/metrics", bob.ID)
+
+	// Only admins can define global metric types
+	peakFlow := map[string]any{"key": "peak-flow", "name": "Peak Flow", "unit": "L/min", "kind": "integer", "min": 50, "max": 900}
+	problem(t, request(router, http.MethodPost, V1+"/metric-types", bobToken, peakFlow), http.StatusForbidden, apierror.CodeForbidden)
+
+	w := request(router, http.MethodPost, V1+"/metric-types", token, peakFlow)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	problem(t, request(router, http.MethodPost, V1+"/metric-types", token, peakFlow), http.StatusConflict, apierror.CodeConflict)
+
+	w = request(router, http.MethodPost, types, bobToken, map[string]any{
+		"key": "migraine-aura", "name": "Migraine Aura", "kind": "enum", "options": []string{"none", "visual", "sensory"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(router, http.MethodPost, types, bobToken, map[string]any{
+		"key": "grip-strength", "name": "Grip Strength", "unit": "kg", "kind": "pair", "labels": []string{"left", "right"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(router, http.MethodGet, types, bobToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var list []payload.MetricDefinitionResponse
+	decode(t, w, &list)
+	require.Len(t, list, 3)
+	assert.Equal(t, "grip-strength", list[0].Key)
+	assert.Equal(t, []string{"left", "right"}, list[0].Labels)
+	assert.True(t, list[2].Global)
+
+	// Users can not change global metric types, nor the kind of their own
+	problem(t, request(router, http.MethodDelete, types+"/peak-flow", bobToken, nil), http.StatusForbidden, apierror.CodeForbidden)
+	problem(t, request(router, http.MethodPut, types+"/grip-strength", bobToken, map[string]any{"key": "grip-strength", "name": "Grip", "kind": "number"}),
+		http.StatusConflict, apierror.CodeConflict)
+
+	// Values are typed by the kind of the metric
+	w = request(router, http.MethodPost, metrics+"/peak-flow", bobToken, map[string]any{"value": 420})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var flow map[string]any
+	decode(t, w, &flow)
+	assert.Equal(t, "peak-flow", flow["metricKey"])
+	assert.Equal(t, 420.0, flow["value"])
+	assert.Equal(t, "L/min", flow["unit"])
+
+	p := problem(t, request(router, http.MethodPost, metrics+"/peak-flow", bobToken, map[string]any{"value": 1000}), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "value", p.Errors[0].Field)
+	problem(t, request(router, http.MethodPost, metrics+"/peak-flow", bobToken, map[string]any{"value": 420.5}), http.StatusBadRequest, apierror.CodeValidation)
+	problem(t, request(router, http.MethodPost, metrics+"/migraine-aura", bobToken, map[string]any{"value": "auditory"}), http.StatusBadRequest, apierror.CodeValidation)
+	problem(t, request(router, http.MethodPost, metrics+"/unknown", bobToken, map[string]any{"value": 1}), http.StatusNotFound, apierror.CodeNotFound)
+
+	w = request(router, http.MethodPost, metrics+"/grip-strength", bobToken, map[string]any{"value": []float64{38.5, 41}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var grip struct {
+		Id    uint      `json:"id"`
+		Value []float64 `json:"value"`
+	}
+	decode(t, w, &grip)
+	assert.Equal(t, []float64{38.5, 41}, grip.Value)
+
+	w = request(router, http.MethodPut, fmt.Sprintf("%s/grip-strength/%d", metrics, grip.Id), bobToken, map[string]any{"value": []float64{40, 42}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Values are only found under their own metric
+	problem(t, request(router, http.MethodGet, fmt.Sprintf("%s/peak-flow/%d", metrics, grip.Id), bobToken, nil), http.StatusNotFound, apierror.CodeNotFound)
+
+	w = request(router, http.MethodGet, metrics+"/peak-flow", bobToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var flows []map[string]any
+	decode(t, w, &flows)
+	assert.Len(t, flows, 1)
+
+	// Deleting a metric type deletes its values
+	w = request(router, http.MethodDelete, types+"/grip-strength", bobToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	problem(t, request(router, http.MethodGet, metrics+"/grip-strength", bobToken, nil), http.StatusNotFound, apierror.CodeNotFound)
+
+	var count int64
+	models.DB.Model(&models.MetricValue{}).Where("id = ?", grip.Id).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestNotesAndTags(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	weight := fmt.Sprintf(V1+"/users/%d/weight", alice.ID)
+	bp := fmt.Sprintf(V1+"/users/%d/blood-pressure", alice.ID)
+	tags := fmt.Sprintf(V1+"/users/%d/tags", alice.ID)
+
+	w := request(router, http.MethodPost, weight, token, map[string]any{"weight": 70, "note": "First weigh-in", "tags": []string{"new-scale", "morning"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var created payload.WeightResponse
+	decode(t, w, &created)
+	assert.Equal(t, "First weigh-in", created.Note)
+	assert.Equal(t, []string{"new-scale", "morning"}, created.Tags)
+
+	require.Equal(t, http.StatusOK, request(router, http.MethodPost, weight, token, map[string]any{"weight": 71}).Code)
+	require.Equal(t, http.StatusOK, request(router, http.MethodPost, bp, token, map[string]any{"systolic": 130, "diastolic": 85, "tags": []string{"after-coffee", "morning"}}).Code)
+	require.Equal(t, http.StatusOK, request(router, http.MethodPost, bp, token, map[string]any{"systolic": 120, "diastolic": 80, "tags": []string{"coffee"}}).Code)
+
+	p := problem(t, request(router, http.MethodPost, weight, token, map[string]any{"weight": 70, "tags": []string{"After Coffee", "morning", "morning"}}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	require.Len(t, p.Errors, 2)
+	assert.Equal(t, "tags[0]", p.Errors[0].Field)
+	assert.Equal(t, "tags[2]", p.Errors[1].Field)
+
+	// Lists are filtered by every tag given
+	var list []payload.WeightResponse
+	w = request(router, http.MethodGet, weight+"?tag=new-scale", token, nil)
+	decode(t, w, &list)
+	require.Len(t, list, 1)
+	assert.Equal(t, created.Id, list[0].Id)
+
+	var pressures []payload.BloodPressureResponse
+	decode(t, request(router, http.MethodGet, bp+"?tag=morning&tag=after-coffee", token, nil), &pressures)
+	assert.Len(t, pressures, 1)
+
+	var none []payload.BloodPressureResponse
+	decode(t, request(router, http.MethodGet, bp+"?tag=morning&tag=coffee", token, nil), &none)
+	assert.Empty(t, none)
+
+	// Tags are counted across vitals
+	var counts []payload.TagResponse
+	decode(t, request(router, http.MethodGet, tags, token, nil), &counts)
+	assert.Equal(t, []payload.TagResponse{{Tag: "after-coffee", Count: 1}, {Tag: "coffee", Count: 1}, {Tag: "morning", Count: 2}, {Tag: "new-scale", Count: 1}}, counts)
+
+	// Renaming to a tag in use merges them
+	w = request(router, http.MethodPut, tags+"/after-coffee", token, map[string]any{"name": "coffee"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var merged payload.TagResponse
+	decode(t, w, &merged)
+	assert.Equal(t, payload.TagResponse{Tag: "coffee", Count: 2}, merged)
+
+	var renamed []payload.BloodPressureResponse
+	decode(t, request(router, http.MethodGet, bp+"?tag=coffee", token, nil), &renamed)
+	require.Len(t, renamed, 2)
+	assert.ElementsMatch(t, []string{"coffee", "morning"}, renamed[1].Tags)
+
+	problem(t, request(router, http.MethodPut, tags+"/after-coffee", token, map[string]any{"name": "coffee"}), http.StatusNotFound, apierror.CodeNotFound)
+	problem(t, request(router, http.MethodPut, tags+"/coffee", token, map[string]any{"name": "Coffee!"}), http.StatusBadRequest, apierror.CodeValidation)
+
+	// Updates replace the note and tags
+	w = request(router, http.MethodPut, fmt.Sprintf("%s/%d", weight, created.Id), token, map[string]any{"weight": 70, "tags": []string{"morning"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var updated map[string]any
+	decode(t, w, &updated)
+	assert.NotContains(t, updated, "note")
+	assert.Equal(t, []any{"morning"}, updated["tags"])
+}
+
+func TestAttachments(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	bob := createUser(t, "bob")
+	token := login(t, router, "alice")
+
+	var photo bytes.Buffer
+	require.NoError(t, png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 600, 300))))
+
+	w := request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/blood-pressure", alice.ID), token, map[string]any{"systolic": 120, "diastolic": 80})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var bp payload.BloodPressureResponse
+	decode(t, w, &bp)
+	attachments := fmt.Sprintf(V1+"/users/%d/blood-pressure/%d/attachments", alice.ID, bp.Id)
+
+	w = upload(router, attachments, token, "cuff.png", photo.Bytes())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var a payload.AttachmentResponse
+	decode(t, w, &a)
+	assert.Equal(t, "blood-pressure", a.ReadingType)
+	assert.Equal(t, bp.Id, a.ReadingId)
+	assert.Equal(t, "cuff.png", a.FileName)
+	assert.Equal(t, "image/png", a.ContentType)
+	assert.Equal(t, int64(photo.Len()), a.Size)
+	require.NotEmpty(t, a.ThumbnailUrl)
+
+	// Downloads are authorized by the signed URL
+	w = request(router, http.MethodGet, a.Url, "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, photo.Bytes(), w.Body.Bytes())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+
+	w = request(router, http.MethodGet, a.ThumbnailUrl, "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	thumbnail, format, err := image.Decode(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 256, 128), thumbnail.Bounds())
+
+	problem(t, request(router, http.MethodGet, a.Url+"0", "", nil), http.StatusForbidden, apierror.CodeForbidden)
+
+	// URLs are not signed with the key that signs tokens
+	u, err := url.Parse(a.Url)
+	require.NoError(t, err)
+	message := fmt.Sprintf("attachment:%d:%d:file:%s", a.Id, alice.ID, u.Query().Get("expires"))
+	assert.False(t, util.Verify(env.GetJWTKey(), message, u.Query().Get("signature")))
+	problem(t, request(router, http.MethodGet, fmt.Sprintf(V1+"/attachments/%d/file?user=%d&expires=%d&signature=x", a.Id, bob.ID, time.Now().Add(time.Hour).Unix()), "", nil),
+		http.StatusForbidden, apierror.CodeForbidden)
+
+	// PDFs are accepted without a thumbnail, other files are rejected
+	w = upload(router, attachments, token, "report.pdf", []byte("%PDF-1.4\n%EOF"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var pdf payload.AttachmentResponse
+	decode(t, w, &pdf)
+	assert.Equal(t, "application/pdf", pdf.ContentType)
+	assert.Empty(t, pdf.ThumbnailUrl)
+
+	p := problem(t, upload(router, attachments, token, "cuff.png", []byte("not a photo")), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "file", p.Errors[0].Field)
+
+	// Images declaring more pixels than the limit are not decoded
+	huge := bytes.Clone(photo.Bytes())
+	binary.BigEndian.PutUint32(huge[16:], 100_000)
+	binary.BigEndian.PutUint32(huge[20:], 100_000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+	p = problem(t, upload(router, attachments, token, "huge.png", huge), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "max", p.Errors[0].Code)
+
+	t.Setenv("ATTACHMENT_MAX_BYTES", "100")
+	p = problem(t, upload(router, attachments, token, "cuff.png", photo.Bytes()), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "max", p.Errors[0].Code)
+
+	// Attachments belong to readings of the user of the token
+	bobToken := login(t, router, "bob")
+	problem(t, upload(router, fmt.Sprintf(V1+"/users/%d/blood-pressure/%d/attachments", bob.ID, bp.Id), bobToken, "cuff.png", photo.Bytes()),
+		http.StatusNotFound, apierror.CodeNotFound)
+	problem(t, upload(router, fmt.Sprintf(V1+"/users/%d/blood-pressure/%d/attachments", bob.ID, bp.Id), token, "cuff.png", photo.Bytes()),
+		http.StatusForbidden, apierror.CodeForbidden)
+	problem(t, upload(router, attachments, bobToken, "cuff.png", photo.Bytes()), http.StatusForbidden, apierror.CodeForbidden)
+	problem(t, request(router, http.MethodGet, attachments, bobToken, nil), http.StatusForbidden, apierror.CodeForbidden)
+	problem(t, request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/attachments/%d", alice.ID, a.Id), bobToken, nil),
+		http.StatusForbidden, apierror.CodeForbidden)
+	problem(t, request(router, http.MethodDelete, fmt.Sprintf(V1+"/users/%d/attachments/%d", alice.ID, a.Id), bobToken, nil),
+		http.StatusForbidden, apierror.CodeForbidden)
+
+	var list []payload.AttachmentResponse
+	decode(t, request(router, http.MethodGet, attachments, token, nil), &list)
+	require.Len(t, list, 2)
+	assert.Equal(t, a.Id, list[0].Id)
+
+	w = request(router, http.MethodDelete, fmt.Sprintf(V1+"/users/%d/attachments/%d", alice.ID, a.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	problem(t, request(router, http.MethodGet, a.Url, "", nil), http.StatusNotFound, apierror.CodeNotFound)
+
+	// Deleting the reading deletes its attachments and their files
+	var stored models.Attachment
+	require.NoError(t, models.DB.First(&stored, pdf.Id).Error)
+
+	w = request(router, http.MethodDelete, fmt.Sprintf(V1+"/users/%d/blood-pressure/%d", alice.ID, bp.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	problem(t, request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/attachments/%d", alice.ID, pdf.Id), token, nil), http.StatusNotFound, apierror.CodeNotFound)
+
+	_, err = blobstore.Current().Open(stored.BlobKey)
+	assert.ErrorIs(t, err, blobstore.ErrNotFound)
+
+	// Journal entries and values of custom metrics take attachments too
+	t.Setenv("ATTACHMENT_MAX_BYTES", "")
+	w = request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/journal", alice.ID), token, map[string]any{"mood": 3})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var entry payload.JournalResponse
+	decode(t, w, &entry)
+
+	w = upload(router, fmt.Sprintf(V1+"/users/%d/journal/%d/attachments", alice.ID, entry.Id), token, "rash.png", photo.Bytes())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &a)
+	assert.Equal(t, "journal", a.ReadingType)
+
+	w = request(router, http.MethodDelete, fmt.Sprintf(V1+"/users/%d/journal/%d", alice.ID, entry.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	problem(t, request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/attachments/%d", alice.ID, a.Id), token, nil), http.StatusNotFound, apierror.CodeNotFound)
+
+	w = request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/metric-types", alice.ID), token, map[string]any{"key": "steps", "name": "Steps", "kind": "integer"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/metrics/steps", alice.ID), token, map[string]any{"value": 8000})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var steps payload.MetricValueResponse
+	decode(t, w, &steps)
+
+	w = upload(router, fmt.Sprintf(V1+"/users/%d/metrics/steps/%d/attachments", alice.ID, steps.Id), token, "watch.png", photo.Bytes())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &a)
+	assert.Equal(t, "metrics", a.ReadingType)
+	problem(t, request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/metrics/sleep/%d/attachments", alice.ID, steps.Id), token, nil),
+		http.StatusNotFound, apierror.CodeNotFound)
+
+	// Deleting the metric type deletes the attachments of its values
+	w = request(router, http.MethodDelete, fmt.Sprintf(V1+"/users/%d/metric-types/steps", alice.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	problem(t, request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/attachments/%d", alice.ID, a.Id), token, nil), http.StatusNotFound, apierror.CodeNotFound)
+}
+
+func TestUnits(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	weights := fmt.Sprintf(V1+"/users/%d/weight", alice.ID)
+	water := fmt.Sprintf(V1+"/users/%d/water", alice.ID)
+
+	// Weights are returned in the submitted unit
+	w := request(router, http.MethodPost, weights, token, map[string]any{"weight": 150, "unit": "lb"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var weight payload.WeightResponse
+	decode(t, w, &weight)
+	assert.Equal(t, 150.0, weight.Weight)
+	assert.Equal(t, "lb", weight.Unit)
+
+	w = request(router, http.MethodPost, weights, token, map[string]any{"weight": 11, "unit": "st"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Limits are checked in kilograms, whatever the unit
+	p := problem(t, request(router, http.MethodPost, weights, token, map[string]any{"weight": 1600, "unit": "lb"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "weight", p.Errors[0].Field)
+
+	w = request(router, http.MethodPost, weights, token, map[string]any{"weight": 70, "unit": "g"})
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+
+	// The preference of the user converts every weight
+	w = request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", alice.ID), token,
+		map[string]any{"firstName": "Alice", "lastName": "Smith", "weightUnit": "kg", "waterUnit": "ml"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var user payload.UserResponse
+	decode(t, w, &user)
+	assert.Equal(t, "kg", user.WeightUnit)
+	assert.Equal(t, "ml", user.WaterUnit)
+
+	w = request(router, http.MethodGet, weights, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list []payload.WeightResponse
+	decode(t, w, &list)
+	require.Len(t, list, 2)
+	assert.Equal(t, 69.85, list[0].Weight)
+	assert.Equal(t, "kg", list[0].Unit)
+	assert.Equal(t, 68.04, list[1].Weight)
+
+	// The unit query parameter overrides the preference
+	w = request(router, http.MethodGet, fmt.Sprintf("%s/%d?unit=lb", weights, weight.Id), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decode(t, w, &weight)
+	assert.Equal(t, 150.0, weight.Weight)
+	assert.Equal(t, "lb", weight.Unit)
+
+	// Conversions start from the stored weight, not the rounded one
+	w = request(router, http.MethodPost, weights+"?unit=lb", token, map[string]any{"weight": 11.111, "unit": "st"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &weight)
+	assert.Equal(t, 155.55, weight.Weight)
+
+	w = request(router, http.MethodGet, weights+"?unit=g", token, nil)
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+
+	// Water amounts in any unit, and the deprecated cups field
+	var intake payload.WaterIntakeResponse
+	w = request(router, http.MethodPost, water+"?unit=l", token, map[string]any{"amount": 500, "unit": "ml"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &intake)
+	assert.Equal(t, 0.5, intake.Amount)
+	assert.Equal(t, "l", intake.Unit)
+	assert.Equal(t, 2.11, intake.Cups)
+
+	w = request(router, http.MethodPost, water, token, map[string]any{"cups": 2})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &intake)
+	assert.Equal(t, 473.0, intake.Amount)
+	assert.Equal(t, "ml", intake.Unit)
+	assert.Equal(t, 2.0, intake.Cups)
+
+	w = request(router, http.MethodPost, water, token, map[string]any{"amount": 16, "unit": "fl oz"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &intake)
+	assert.Equal(t, 473.0, intake.Amount)
+
+	w = request(router, http.MethodPost, water+"?unit=ml", token, map[string]any{"amount": 0.3333, "unit": "l"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &intake)
+	assert.Equal(t, 333.0, intake.Amount)
+
+	p = problem(t, request(router, http.MethodPost, water, token, map[string]any{"amount": 20, "unit": "l"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "amount", p.Errors[0].Field)
+
+	w = request(router, http.MethodPost, water, token, map[string]any{"unit": "ml"})
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+}
+
+func TestTimeZones(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	user := fmt.Sprintf(V1+"/users/%d", alice.ID)
+	base := user + "/nutrition"
+
+	// Readings keep the UTC offset they were submitted with
+	w := request(router, http.MethodPost, user+"/weight", token, map[string]any{"weight": 70, "time": "2024-03-01T08:00:00+09:00"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var reading map[string]any
+	decode(t, w, &reading)
+	assert.Equal(t, "2024-03-01T08:00:00+09:00", reading["time"])
+
+	w = request(router, http.MethodGet, fmt.Sprintf("%s/weight/%v", user, reading["id"]), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decode(t, w, &reading)
+	assert.Equal(t, "2024-03-01T08:00:00+09:00", reading["time"])
+
+	p := problem(t, request(router, http.MethodPut, user, token, map[string]any{"firstName": "Alice", "lastName": "Smith", "timeZone": "Mars/Olympus"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "timeZone", p.Errors[0].Field)
+
+	w = request(router, http.MethodPut, user, token, map[string]any{"firstName": "Alice", "lastName": "Smith", "timeZone": "America/New_York"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Days are counted in the user's time zone. Daylight saving time starts
+	// on 2024-03-10 in New York, so that day has 23 hours.
+	for _, at := range []string{"2024-03-10T03:30:00Z", "2024-03-10T05:30:00Z", "2024-03-11T03:30:00Z", "2024-03-11T04:30:00Z"} {
+		w := request(router, http.MethodPost, base, token, map[string]any{"name": "Tea", "calories": 10, "time": at})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = request(router, http.MethodGet, base+"/daily?from=2024-03-09&to=2024-03-11", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var days []payload.NutritionDayResponse
+	decode(t, w, &days)
+	require.Len(t, days, 3)
+	assert.Equal(t, "2024-03-09", days[0].Date)
+	assert.Equal(t, 1, days[0].Entries)
+	assert.Equal(t, 2, days[1].Entries)
+	assert.Equal(t, 1, days[2].Entries)
+
+	// A nap at 10:00 local time belongs to the night before, although it
+	// is after noon in UTC
+	w = request(router, http.MethodPost, user+"/sleep", token, map[string]any{"start": "2024-03-10T14:00:00Z", "end": "2024-03-10T15:30:00Z"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var session payload.SleepSessionResponse
+	decode(t, w, &session)
+	assert.Equal(t, "2024-03-09", session.NightOf)
+}
+
+func TestPagination(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	createUser(t, "bob")
+	createUser(t, "carol")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/heart-rate", alice.ID)
+
+	// Two readings share a time, so pages are also ordered by id
+	for i, at := range []string{"2024-03-01T08:00:00Z", "2024-03-02T08:00:00Z", "2024-03-02T08:00:00Z", "2024-03-03T08:00:00Z", "2024-03-04T08:00:00Z"} {
+		w := request(router, http.MethodPost, base, token, map[string]any{"bpm": 60 + i, "time": at})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var bpms []uint16
+	path := base + "?limit=2"
+	for pages := 0; path != ""; pages++ {
+		require.Less(t, pages, 3)
+
+		w := request(router, http.MethodGet, path, token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var list []payload.HeartRateResponse
+		decode(t, w, &list)
+		for _, r := range list {
+			bpms = append(bpms, r.Bpm)
+		}
+
+		path = ""
+		if cursor := w.Header().Get(controllers.NextCursorHeader); cursor != "" {
+			assert.Len(t, list, 2)
+			link := w.Header().Get("Link")
+			require.True(t, strings.HasPrefix(link, "<"+base+"?"), link)
+			path = link[1:strings.Index(link, ">")]
+			assert.Contains(t, path, "cursor="+cursor)
+		}
+	}
+	assert.Equal(t, []uint16{64, 63, 62, 61, 60}, bpms)
+
+	// Without a limit every reading fits on the default page
+	w := request(router, http.MethodGet, base, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(controllers.NextCursorHeader))
+
+	for _, query := range []string{"?limit=0", "?limit=501", "?limit=ten", "?cursor=not-a-cursor"} {
+		p := problem(t, request(router, http.MethodGet, base+query, token, nil), http.StatusBadRequest, apierror.CodeValidation)
+		assert.Contains(t, []string{"limit", "cursor"}, p.Errors[0].Field)
+	}
+
+	// Users are paged by id
+	w = request(router, http.MethodGet, V1+"/users?limit=2", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var users []payload.UserResponse
+	decode(t, w, &users)
+	require.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].UserName)
+
+	w = request(router, http.MethodGet, V1+"/users?limit=2&cursor="+w.Header().Get(controllers.NextCursorHeader), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decode(t, w, &users)
+	require.Len(t, users, 1)
+	assert.Equal(t, "carol", users[0].UserName)
+	assert.Empty(t, w.Header().Get("Link"))
+}
+
+func TestListQueries(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/blood-pressure", alice.ID)
+
+	readings := []map[string]any{
+		{"systolic": 120, "diastolic": 80, "arm": "left", "time": "2024-03-01T08:00:00Z"},
+		{"systolic": 145, "diastolic": 95, "arm": "right", "time": "2024-03-02T08:00:00Z", "source": "Omron"},
+		{"systolic": 150, "diastolic": 85, "arm": "left", "time": "2024-03-03T08:00:00Z", "tags": []string{"after-coffee"}},
+		{"systolic": 135, "diastolic": 88, "arm": "left", "time": "2024-03-04T08:00:00Z"},
+	}
+	for _, r := range readings {
+		w := request(router, http.MethodPost, base, token, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	list := func(query string) []payload.BloodPressureResponse {
+		t.Helper()
+
+		w := request(router, http.MethodGet, base+query, token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res []payload.BloodPressureResponse
+		decode(t, w, &res)
+		return res
+	}
+
+	systolic := func(list []payload.BloodPressureResponse) []uint16 {
+		return controllers.Map(list, func(r payload.BloodPressureResponse) uint16 { return r.Sys })
+	}
+
+	assert.Equal(t, []uint16{150, 145}, systolic(list("?sys_gte=140")))
+	assert.Equal(t, []uint16{145}, systolic(list("?sys_gte=140&dia_gt=90")))
+	assert.Equal(t, []uint16{135, 150, 120}, systolic(list("?arm=left")))
+	assert.Equal(t, []uint16{145, 120}, systolic(list("?sys=120&sys=145")))
+	assert.Equal(t, []uint16{150}, systolic(list("?tag=after-coffee")))
+	assert.Equal(t, []uint16{145}, systolic(list("?source=Omron")))
+	assert.Equal(t, "Omron", list("?source=Omron")[0].Source)
+	assert.Equal(t, []uint16{145, 150}, systolic(list("?from=2024-03-02&to=2024-03-03&sort=asc")))
+	assert.Equal(t, []uint16{120, 145}, systolic(list("?sort=asc&limit=2")))
+
+	// Sparse fields
+	w := request(router, http.MethodGet, base+"?fields=id,systolic&sys_lt=130", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var sparse []map[string]any
+	decode(t, w, &sparse)
+	require.Len(t, sparse, 1)
+	assert.Equal(t, map[string]any{"id": sparse[0]["id"], "systolic": 120.0}, sparse[0])
+
+	// Parameters are validated strictly
+	p := problem(t, request(router, http.MethodGet, base+"?sys_gte=high&unit=mmHg&fields=id,bogus&sort=up", token, nil),
+		http.StatusBadRequest, apierror.CodeValidation)
+	fields := controllers.Map(p.Errors, func(e payload.FieldError) string { return e.Field })
+	assert.ElementsMatch(t, []string{"sys_gte", "unit", "fields", "sort"}, fields)
+
+	w = request(router, http.MethodGet, base+"?arm_gte=left", token, nil)
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+
+	// Tags are matched with LIKE, so wildcards are rejected
+	for _, tag := range []string{"%25", "_", "after%25"} {
+		p := problem(t, request(router, http.MethodGet, base+"?tag="+tag, token, nil), http.StatusBadRequest, apierror.CodeValidation)
+		assert.Equal(t, "tag", p.Errors[0].Field)
+	}
+	p = problem(t, request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/timeline?tag=_", alice.ID), token, nil), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "tag", p.Errors[0].Field)
+
+	// Parameters of a presenter are allowed where it reads them
+	w = request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/weight?unit=lb&kilograms_gte=50", alice.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Every vital with tags can be filtered by source, but not journal entries
+	w = request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/timeline?source=Omron", alice.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var timeline []payload.TimelineEntry
+	decode(t, w, &timeline)
+	require.Len(t, timeline, 1)
+	assert.Equal(t, "blood-pressure", timeline[0].Type)
+
+	w = request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/journal?source=Omron", alice.ID), token, nil)
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+}
+
+func TestTimeline(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	bob := createUser(t, "bob")
+	token := login(t, router, "alice")
+
+	user := fmt.Sprintf(V1+"/users/%d", alice.ID)
+
+	posts := []struct {
+		path string
+		body map[string]any
+	}{
+		{"/blood-pressure", map[string]any{"systolic": 120, "diastolic": 80, "time": "2024-03-01T08:00:00Z"}},
+		{"/weight", map[string]any{"weight": 70, "unit": "kg", "time": "2024-03-01T08:00:00Z"}},
+		{"/water", map[string]any{"amount": 250, "unit": "ml", "time": "2024-03-01T09:00:00Z"}},
+		{"/sugar", map[string]any{"grams": 12, "time": "2024-03-02T10:00:00Z"}},
+		{"/blood-pressure", map[string]any{"systolic": 130, "diastolic": 85, "time": "2024-03-03T08:00:00Z"}},
+		{"/metric-types", map[string]any{"key": "steps", "name": "Steps", "kind": "integer"}},
+		{"/metrics/steps", map[string]any{"value": 8000, "tags": []string{"walk"}, "time": "2024-03-02T12:00:00Z"}},
+	}
+	for _, post := range posts {
+		w := request(router, http.MethodPost, user+post.path, token, post.body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Another user's readings are not in the timeline
+	w := request(router, http.MethodPost, fmt.Sprintf(V1+"/users/%d/weight", bob.ID), token, map[string]any{"weight": 80, "time": "2024-03-02T08:00:00Z"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Page through the timeline two entries at a time; readings at the same
+	// time are ordered by type
+	var types []string
+	var entries []map[string]any
+	path := user + "/timeline?limit=2"
+	for pages := 0; path != ""; pages++ {
+		require.Less(t, pages, 4)
+
+		w := request(router, http.MethodGet, path, token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var page []map[string]any
+		decode(t, w, &page)
+		for _, e := range page {
+			types = append(types, e["type"].(string))
+		}
+		entries = append(entries, page...)
+
+		path = ""
+		if cursor := w.Header().Get(controllers.NextCursorHeader); cursor != "" {
+			path = user + "/timeline?limit=2&cursor=" + cursor
+		}
+	}
+	assert.Equal(t, []string{"blood-pressure", "metrics", "sugar", "water", "blood-pressure", "weight"}, types)
+	assert.Equal(t, 130.0, entries[0]["data"].(map[string]any)["systolic"])
+	assert.Equal(t, 70.0, entries[5]["data"].(map[string]any)["weight"])
+
+	// Values of custom metrics are returned as by their metric endpoint
+	metric := entries[1]["data"].(map[string]any)
+	assert.Equal(t, "steps", metric["metricKey"])
+	assert.Equal(t, 8000.0, metric["value"])
+	assert.Equal(t, []any{"walk"}, metric["tags"])
+
+	// Filter by type and time range, oldest first
+	w = request(router, http.MethodGet, user+"/timeline?type=weight&type=water&from=2024-03-01T08:30:00Z&to=2024-03-02&sort=asc", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var filtered []payload.TimelineEntry
+	decode(t, w, &filtered)
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "water", filtered[0].Type)
+
+	w = request(router, http.MethodGet, user+"/timeline?type=metrics&tag=walk", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &filtered)
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "metrics", filtered[0].Type)
+
+	p := problem(t, request(router, http.MethodGet, user+"/timeline?type=steps", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "type", p.Errors[0].Field)
+
+	w = request(router, http.MethodGet, user+"/timeline?cursor=bogus", token, nil)
+	problem(t, w, http.StatusBadRequest, apierror.CodeValidation)
+}
+
+func TestStats(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	user := fmt.Sprintf(V1+"/users/%d", alice.ID)
+
+	w := request(router, http.MethodPut, user, token, map[string]any{"firstName": "Alice", "lastName": "Smith", "timeZone": "America/New_York", "weightUnit": "lb"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	posts := []struct {
+		path string
+		body map[string]any
+	}{
+		{"/water", map[string]any{"amount": 250, "unit": "ml", "time": "2024-02-01T08:00:00-05:00"}},
+		{"/water", map[string]any{"amount": 500, "unit": "ml", "time": "2024-02-01T23:30:00-05:00"}},
+		{"/water", map[string]any{"amount": 300, "unit": "ml", "time": "2024-02-02T07:00:00-05:00"}},
+		{"/blood-pressure", map[string]any{"systolic": 110, "diastolic": 70, "time": "2024-02-05T08:00:00-05:00"}},
+		{"/blood-pressure", map[string]any{"systolic": 120, "diastolic": 80, "time": "2024-02-07T08:00:00-05:00"}},
+		{"/blood-pressure", map[string]any{"systolic": 140, "diastolic": 90, "time": "2024-02-11T20:00:00-05:00"}},
+		{"/glucose", map[string]any{"value": 100, "unit": "mg/dL", "time": "2024-02-01T08:00:00-05:00"}},
+		{"/temperature", map[string]any{"value": 37, "unit": "C", "time": "2024-02-01T08:00:00-05:00"}},
+		{"/temperature", map[string]any{"value": 38, "unit": "C", "time": "2024-02-01T20:00:00-05:00"}},
+		{"/weight", map[string]any{"weight": 80, "unit": "kg", "time": "2024-02-01T08:00:00-05:00"}},
+	}
+	for _, post := range posts {
+		w := request(router, http.MethodPost, user+post.path, token, post.body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Days are in the time zone of the user; empty days are included
+	w = request(router, http.MethodGet, user+"/water/stats?from=2024-02-01&to=2024-02-03", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var periods []payload.StatsPeriodResponse
+	decode(t, w, &periods)
+	require.Len(t, periods, 3)
+	assert.Equal(t, "2024-02-01T00:00:00-05:00", periods[0].Start.Format(time.RFC3339))
+	assert.Equal(t, "2024-02-02T00:00:00-05:00", periods[0].End.Format(time.RFC3339))
+	assert.Equal(t, 2, periods[0].Count)
+
+	water := periods[0].Fields["milliliters"]
+	assert.Equal(t, 250.0, water.Min)
+	assert.Equal(t, 500.0, water.Max)
+	assert.Equal(t, 375.0, water.Mean)
+	assert.Equal(t, 375.0, water.Median)
+	require.NotNil(t, water.Sum)
+	assert.Equal(t, 750.0, *water.Sum)
+	assert.Equal(t, "ml", water.Unit)
+
+	assert.Equal(t, 1, periods[1].Count)
+	assert.Equal(t, 300.0, *periods[1].Fields["milliliters"].Sum)
+	assert.Equal(t, 0, periods[2].Count)
+	assert.Empty(t, periods[2].Fields)
+
+	w = request(router, http.MethodGet, user+"/water/stats?interval=month&from=2024-02-01&to=2024-02-29", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &periods)
+	require.Len(t, periods, 1)
+	assert.Equal(t, "2024-03-01T00:00:00-05:00", periods[0].End.Format(time.RFC3339))
+	assert.Equal(t, 1050.0, *periods[0].Fields["milliliters"].Sum)
+
+	// Weeks start on Monday; only intakes have sums
+	w = request(router, http.MethodGet, user+"/blood-pressure/stats?interval=week&from=2024-02-07&to=2024-02-11", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &periods)
+	require.Len(t, periods, 1)
+	assert.Equal(t, "2024-02-05T00:00:00-05:00", periods[0].Start.Format(time.RFC3339))
+	assert.Equal(t, 3, periods[0].Count)
+
+	sys := periods[0].Fields["sys"]
+	assert.Equal(t, 3, sys.Count)
+	assert.Equal(t, 123.33, sys.Mean)
+	assert.Equal(t, 120.0, sys.Median)
+	assert.Equal(t, 12.47, sys.StdDev)
+	assert.Nil(t, sys.Sum)
+	assert.Empty(t, sys.Unit)
+	assert.Equal(t, 80.0, periods[0].Fields["dia"].Median)
+
+	// The glucose stats endpoint returns periods when given an interval
+	w = request(router, http.MethodGet, user+"/glucose/stats?interval=day&from=2024-02-01&to=2024-02-01", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &periods)
+	require.Len(t, periods, 1)
+	assert.Equal(t, 100.0, periods[0].Fields["mgDl"].Mean)
+	assert.Equal(t, "mg/dL", periods[0].Fields["mgDl"].Unit)
+
+	// Values are converted to the unit parameter or the preference of the user
+	w = request(router, http.MethodGet, user+"/glucose/stats?interval=day&from=2024-02-01&to=2024-02-01&unit=mmol/L", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &periods)
+	assert.Equal(t, 5.55, periods[0].Fields["mgDl"].Mean)
+	assert.Equal(t, "mmol/L", periods[0].Fields["mgDl"].Unit)
+
+	w = request(router, http.MethodGet, user+"/water/stats?interval=month&from=2024-02-01&to=2024-02-29&unit=l", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &periods)
+	assert.Equal(t, 1.05, *periods[0].Fields["milliliters"].Sum)
+	assert.Equal(t, 0.3, periods[0].Fields["milliliters"].Median)
+
+	w = request(router, http.MethodGet, user+"/temperature/stats?from=2024-02-01&to=2024-02-01&unit=F", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &periods)
+
+	temperature := periods[0].Fields["celsius"]
+	assert.Equal(t, 98.6, temperature.Min)
+	assert.Equal(t, 99.5, temperature.Mean)
+	assert.Equal(t, 99.5, temperature.Median)
+	assert.Equal(t, 0.9, temperature.StdDev)
+	assert.Equal(t, "F", temperature.Unit)
+
+	w = request(router, http.MethodGet, user+"/weight/stats?from=2024-02-01&to=2024-02-01", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &periods)
+	assert.Equal(t, 176.37, periods[0].Fields["kilograms"].Mean)
+	assert.Equal(t, "lb", periods[0].Fields["kilograms"].Unit)
+
+	problem(t, request(router, http.MethodGet, user+"/temperature/stats?unit=K", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+
+	p := problem(t, request(router, http.MethodGet, user+"/weight/stats?interval=year", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "interval", p.Errors[0].Field)
+
+	p = problem(t, request(router, http.MethodGet, user+"/weight/stats?from=2000-01-01&to=2024-01-01", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "from", p.Errors[0].Field)
+}
+
+func TestTrend(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	user := fmt.Sprintf(V1+"/users/%d", alice.ID)
+
+	weights := []struct {
+		weight float64
+		at     string
+	}{
+		{80, "2024-03-01T07:00:00Z"},
+		{80.6, "2024-03-01T20:00:00Z"},
+		{80, "2024-03-02T07:00:00Z"},
+		{79.5, "2024-03-04T07:00:00Z"},
+		{79, "2024-03-08T07:00:00Z"},
+	}
+	for _, r := range weights {
+		w := request(router, http.MethodPost, user+"/weight", token, map[string]any{"weight": r.weight, "unit": "kg", "time": r.at})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Readings of a day are averaged; the simple moving average covers the
+	// days with readings in the window
+	w := request(router, http.MethodGet, user+"/weight/trend?from=2024-03-01&to=2024-03-31&window=3&target=78", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var trend payload.TrendResponse
+	decode(t, w, &trend)
+	assert.Equal(t, "kg", trend.Unit)
+	assert.Equal(t, 3, trend.Window)
+	assert.Equal(t, 0.1, trend.Alpha)
+
+	weight := trend.Fields["weight"]
+	require.Len(t, weight.Days, 4)
+	assert.Equal(t, payload.TrendDay{Date: "2024-03-01", Count: 2, Value: 80.3, MovingAverage: 80.3, ExponentialMovingAverage: 80.3}, weight.Days[0])
+	assert.Equal(t, payload.TrendDay{Date: "2024-03-02", Count: 1, Value: 80, MovingAverage: 80.15, ExponentialMovingAverage: 80.27}, weight.Days[1])
+	assert.Equal(t, payload.TrendDay{Date: "2024-03-04", Count: 1, Value: 79.5, MovingAverage: 79.75, ExponentialMovingAverage: 80.19}, weight.Days[2])
+	assert.Equal(t, payload.TrendDay{Date: "2024-03-08", Count: 1, Value: 79, MovingAverage: 79, ExponentialMovingAverage: 80.07}, weight.Days[3])
+
+	require.NotNil(t, weight.SlopePerWeek)
+	assert.Equal(t, -1.27, *weight.SlopePerWeek)
+	require.NotNil(t, weight.TargetDate)
+	assert.Equal(t, "2024-03-20", *weight.TargetDate)
+
+	// A target the trend moves away from is not projected
+	w = request(router, http.MethodGet, user+"/weight/trend?from=2024-03-01&to=2024-03-31&target=190&unit=lb", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &trend)
+	assert.Equal(t, "lb", trend.Unit)
+	assert.Equal(t, 177.03, trend.Fields["weight"].Days[0].Value)
+	assert.Nil(t, trend.Fields["weight"].TargetDate)
+
+	w = request(router, http.MethodGet, user+"/weight/trend?from=2024-04-01&to=2024-04-30", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &trend)
+	assert.Empty(t, trend.Fields["weight"].Days)
+	assert.Nil(t, trend.Fields["weight"].SlopePerWeek)
+
+	// The target of blood pressure is the systolic pressure
+	for _, r := range []map[string]any{
+		{"systolic": 130, "diastolic": 85, "time": "2024-03-01T08:00:00Z"},
+		{"systolic": 120, "diastolic": 80, "time": "2024-03-08T08:00:00Z"},
+	} {
+		w := request(router, http.MethodPost, user+"/blood-pressure", token, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = request(router, http.MethodGet, user+"/blood-pressure/trend?from=2024-03-01&to=2024-03-31&target=110", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &trend)
+	assert.Equal(t, "mmHg", trend.Unit)
+	assert.Equal(t, -10.0, *trend.Fields["systolic"].SlopePerWeek)
+	assert.Equal(t, "2024-03-22", *trend.Fields["systolic"].TargetDate)
+	assert.Equal(t, -5.0, *trend.Fields["diastolic"].SlopePerWeek)
+	assert.Nil(t, trend.Fields["diastolic"].TargetDate)
+
+	p := problem(t, request(router, http.MethodGet, user+"/weight/trend?window=0", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "window", p.Errors[0].Field)
+
+	p = problem(t, request(router, http.MethodGet, user+"/blood-pressure/trend?alpha=2", token, nil), http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "alpha", p.Errors[0].Field)
+}
+
+func TestMixedOffsets(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	base := fmt.Sprintf(V1+"/users/%d/weight", alice.ID)
+
+	// 23:00 UTC, then 23:30 UTC and 23:45 UTC
+	for _, r := range []map[string]any{
+		{"weight": 70, "time": "2024-01-02T08:00:00+09:00"},
+		{"weight": 71, "time": "2024-01-01T23:30:00Z"},
+		{"weight": 72, "time": "2024-01-01T18:45:00-05:00"},
+	} {
+		w := request(router, http.MethodPost, base, token, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var weights []payload.WeightResponse
+	w := request(router, http.MethodGet, base+"?sort=asc", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decode(t, w, &weights)
+	require.Len(t, weights, 3)
+	assert.Equal(t, []float64{70, 71, 72}, []float64{weights[0].Weight, weights[1].Weight, weights[2].Weight})
+	assert.Equal(t, "2024-01-02T08:00:00+09:00", weights[0].Time.Format(time.RFC3339))
+	assert.Equal(t, "2024-01-01T18:45:00-05:00", weights[2].Time.Format(time.RFC3339))
+
+	w = request(router, http.MethodGet, base+"?from=2024-01-01T23:10:00Z", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decode(t, w, &weights)
+	require.Len(t, weights, 2)
+	assert.Equal(t, 72.0, weights[0].Weight)
+	assert.Equal(t, 71.0, weights[1].Weight)
+
+	// Pages follow the instants, not the submitted offsets
+	var paged []float64
+	path := base + "?limit=1"
+	for pages := 0; path != ""; pages++ {
+		require.Less(t, pages, 4)
+
+		w := request(router, http.MethodGet, path, token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		decode(t, w, &weights)
+		for _, r := range weights {
+			paged = append(paged, r.Weight)
+		}
+
+		path = ""
+		if cursor := w.Header().Get(controllers.NextCursorHeader); cursor != "" {
+			path = base + "?limit=1&cursor=" + cursor
+		}
+	}
+	assert.Equal(t, []float64{72, 71, 70}, paged)
+
+	// The stats of the user's day, in UTC, hold all three
+	w = request(router, http.MethodGet, base+"/stats?from=2024-01-01&to=2024-01-02", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var periods []payload.StatsPeriodResponse
+	decode(t, w, &periods)
+	require.Len(t, periods, 2)
+	assert.Equal(t, 3, periods[0].Count)
+	assert.Equal(t, 0, periods[1].Count)
+}
+
+func TestTokens(t *testing.T) {
+	router := setupRouter(t)
+	user := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	w := request(router, http.MethodPost, V1+"/token/validate", "", map[string]string{"token": token})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"id":%d,"username":"alice"}`, user.ID), w.Body.String())
+
+	w = request(router, http.MethodPost, V1+"/token/refresh", "", map[string]string{"token": token})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &res)
+	assert.NotEmpty(t, res.Token)
+}
+
+func TestUsers(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	bob := createUser(t, "bob")
+	token := login(t, router, "alice")
+
+	w := request(router, http.MethodGet, V1+"/users", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var users []map[string]any
+	decode(t, w, &users)
+	assert.Len(t, users, 2)
+
+	w = request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d", alice.ID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var user map[string]any
+	decode(t, w, &user)
+	assert.Equal(t, "alice", user["username"])
+	assert.NotContains(t, user, "heightCm")
+
+	w = request(router, http.MethodGet, V1+"/users/999", token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", alice.ID), token, map[string]any{"firstName": "Alice", "lastName": "Smith", "heightCm": 168})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &user)
+	assert.Equal(t, "Alice", user["firstName"])
+	assert.Equal(t, 168.0, user["heightCm"])
+
+	p := problem(t, request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", alice.ID), token, map[string]any{"firstName": "Alice", "lastName": "Smith", "heightCm": 1.68}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "heightCm", p.Errors[0].Field)
+
+	// Fields that are left out keep their value; empty preferences reset them
+	w = request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", alice.ID), token, map[string]any{"weightUnit": "lb", "timeZone": "Europe/Berlin"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &user)
+	assert.Equal(t, "Alice", user["firstName"])
+	assert.Equal(t, "Smith", user["lastName"])
+	assert.Equal(t, 168.0, user["heightCm"])
+	assert.Equal(t, "lb", user["weightUnit"])
+	assert.Equal(t, "Europe/Berlin", user["timeZone"])
+
+	w = request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", alice.ID), token, map[string]any{"weightUnit": "", "timeZone": ""})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	user = nil
+	decode(t, w, &user)
+	assert.NotContains(t, user, "weightUnit")
+	assert.NotContains(t, user, "timeZone")
+	assert.Equal(t, 168.0, user["heightCm"])
+
+	p = problem(t, request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", alice.ID), token, map[string]any{"firstName": ""}),
+		http.StatusBadRequest, apierror.CodeValidation)
+	assert.Equal(t, "firstName", p.Errors[0].Field)
+	problem(t, request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", alice.ID), token, map[string]any{"waterUnit": "gallons"}),
+		http.StatusBadRequest, apierror.CodeValidation)
+
+	w = request(router, http.MethodPut, V1+"/users/999", token, map[string]any{"firstName": "Alice", "lastName": "Smith"})
+	problem(t, w, http.StatusNotFound, apierror.CodeNotFound)
+
+	// Users can only update their own profile
+	w = request(router, http.MethodPut, fmt.Sprintf(V1+"/users/%d", bob.ID), token, map[string]any{"firstName": "Mallory", "lastName": "Smith"})
+	problem(t, w, http.StatusForbidden, apierror.CodeForbidden)
+
+	var stored models.User
+	require.NoError(t, models.DB.First(&stored, bob.ID).Error)
+	assert.NotEqual(t, "Mallory", stored.FirstName)
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	router := setupRouter(t)
+	alice := createUser(t, "alice")
+	token := login(t, router, "alice")
+
+	w := request(router, http.MethodGet, V1+"/health-check", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+
+	path := fmt.Sprintf("/users/%d/weight", alice.ID)
+	w = request(router, http.MethodPost, path, token, map[string]any{"weight": 180})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, fmt.Sprintf("@%d", legacyDeprecation.Unix()), w.Header().Get("Deprecation"))
+	assert.NotEmpty(t, w.Header().Get("Sunset"))
+	assert.Equal(t, fmt.Sprintf("<%s%s>; rel=\"successor-version\"", V1, path), w.Header().Get("Link"))
+
+	w = request(router, http.MethodGet, V1+path, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list []map[string]any
+	decode(t, w, &list)
+	assert.Len(t, list, 1)
+
+	w = request(router, http.MethodPost, "/auth", "", map[string]string{"username": "alice", "password": testPassword})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Deprecation"))
+}
+
+// vitalTestCase describes one vital endpoint along with a request body to
+// create a record, a body to update it and the JSON field that holds the
+// value.
+type vitalTestCase struct {
+	path   string
+	field  string
+	create map[string]any
+	update map[string]any
+}
+
+var vitalTestCases = []vitalTestCase{
+	{
+		path:   "blood-pressure",
+		field:  "systolic",
+		create: map[string]any{"systolic": 120, "diastolic": 80, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"systolic": 130, "diastolic": 85},
+	},
+	{
+		path:   "weight",
+		field:  "weight",
+		create: map[string]any{"weight": 180.5, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"weight": 179},
+	},
+	{
+		path:   "water",
+		field:  "cups",
+		create: map[string]any{"cups": 2, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"cups": 3.5},
+	},
+	{
+		path:   "sugar",
+		field:  "grams",
+		create: map[string]any{"grams": 25, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"grams": 30},
+	},
+	{
+		path:   "heart-rate",
+		field:  "bpm",
+		create: map[string]any{"bpm": 62, "context": "resting", "source": "Chest strap", "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"bpm": 145, "context": "active"},
+	},
+	{
+		path:   "glucose",
+		field:  "value",
+		create: map[string]any{"value": 95, "unit": "mg/dL", "mealContext": "fasting", "method": "fingerstick", "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"value": 140, "unit": "mg/dL", "mealContext": "post-meal"},
+	},
+	{
+		path:   "activities",
+		field:  "durationMinutes",
+		create: map[string]any{"type": "run", "durationMinutes": 30, "distanceKm": 5, "calories": 320, "averageHeartRate": 150, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"type": "walk", "durationMinutes": 45},
+	},
+	{
+		path:   "temperature",
+		field:  "value",
+		create: map[string]any{"value": 36.8, "unit": "C", "site": "oral", "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"value": 101.3, "unit": "F", "site": "ear"},
+	},
+	{
+		path:   "spo2",
+		field:  "percent",
+		create: map[string]any{"percent": 97, "pulse": 64, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"percent": 94},
+	},
+	{
+		path:   "journal",
+		field:  "notes",
+		create: map[string]any{"mood": 4, "energy": 3, "notes": "Slept well", "symptoms": []string{"fatigue"}, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"mood": 2, "notes": "Headache after lunch", "symptoms": []string{"headache", "nausea"}},
+	},
+	{
+		path:   "body-composition",
+		field:  "weightKg",
+		create: map[string]any{"weightKg": 72.5, "bodyFatPercent": 18.2, "muscleMassKg": 56.1, "waterPercent": 55.4, "boneMassKg": 3.1, "visceralFat": 7, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"weightKg": 71.8, "bodyFatPercent": 17.9},
+	},
+	{
+		path:   "measurements",
+		field:  "waistCm",
+		create: map[string]any{"waistCm": 82, "hipCm": 98, "chestCm": 100, "neckCm": 38, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"waistCm": 80},
+	},
+	{
+		path:   "nutrition",
+		field:  "name",
+		create: map[string]any{"name": "Oatmeal", "mealType": "breakfast", "calories": 320, "proteinG": 10, "carbsG": 54, "fatG": 6, "fiberG": 8, "sugarG": 12, "sodiumMg": 150, "time": "2024-01-02T08:00:00Z"},
+		update: map[string]any{"name": "Granola", "calories": 400},
+	},
+}
+
+func TestVitalsCRUD(t *testing.T) {
+	for _, tc := range vitalTestCases {
+		t.Run(tc.path, func(t *testing.T) {
+			router := setupRouter(t)
+			alice := createUser(t, "alice")
+			bob := createUser(t, "bob")
+			token := login(t, router, "alice")
+
+			base := fmt.Sprintf(V1+"/users/%d/%s", alice.ID, tc.path)
+
+			// Create
+			w := request(router, http.MethodPost, base, token, tc.create)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var created map[string]any
+			decode(t, w, &created)
+			assert.EqualValues(t, tc.create[tc.field], created[tc.field])
+			assert.Equal(t, "2024-01-02T08:00:00Z", created["time"])
+
+			recordPath := fmt.Sprintf("%s/%v", base, created["id"])
+
+			// Create without a time defaults to now
+			w = request(router, http.MethodPost, base, token, tc.update)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			// List
+			w = request(router, http.MethodGet, base, token, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var list []map[string]any
+			decode(t, w, &list)
+			require.Len(t, list, 2)
+			assert.Equal(t, created["id"], list[1]["id"], "list should be ordered by time descending")
+
+			// Get
+			w = request(router, http.MethodGet, recordPath, token, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var fetched map[string]any
+			decode(t, w, &fetched)
+			assert.Equal(t, created, fetched)
+
+			// Update keeps the existing time when none is given
+			w = request(router, http.MethodPut, recordPath, token, tc.update)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var updated map[string]any
+			decode(t, w, &updated)
+			assert.EqualValues(t, tc.update[tc.field], updated[tc.field])
+			assert.Equal(t, "2024-01-02T08:00:00Z", updated["time"])
+
+			// Records are scoped to their owner
+			w = request(router, http.MethodGet, fmt.Sprintf(V1+"/users/%d/%s/%v", bob.ID, tc.path, created["id"]), token, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			// Validation
+			w = request(router, http.MethodPost, base, token, map[string]any{})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			w = request(router, http.MethodPut, base+"/abc", token, tc.update)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			w = request(router, http.MethodGet, fmt.Sprintf(V1+"/users/999/%s", tc.path), token, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			// Delete
+			w = request(router, http.MethodDelete, recordPath, token, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			w = request(router, http.MethodGet, recordPath, token, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			w = request(router, http.MethodDelete, recordPath, token, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			// Unauthenticated
+			w = request(router, http.MethodGet, base, "", nil)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Sign returns a hex encoded HMAC-SHA256 of message, e.g. to sign a
+// download URL.
+func Sign(key string, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of message.
+func Verify(key string, message string, signature string) bool {
+	return hmac.Equal([]byte(Sign(key, message)), []byte(signature))
+}
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThumbnail(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			img.Set(x, y, color.White)
+		}
+	}
+
+	thumb := Thumbnail(img, 100)
+	assert.Equal(t, image.Rect(0, 0, 100, 25), thumb.Bounds())
+
+	r, _, _, _ := thumb.At(10, 10).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	r, _, _, _ = thumb.At(90, 10).RGBA()
+	assert.Equal(t, uint32(0), r)
+}
+
+func TestThumbnailSmallImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 50, 80))
+	assert.Same(t, img, Thumbnail(img, 100))
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Range limits a numeric reading. Values outside Min and Max are rejected.
+// Values inside the limits but outside WarnMin and WarnMax are accepted
+// with a warning. A zero WarnMax disables the high warning.
+type Range struct {
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	WarnMin float64 `json:"warnMin"`
+	WarnMax float64 `json:"warnMax"`
+}
+
+// Rules are the plausibility limits applied to vital readings.
+type Rules struct {
+	// MaxFutureSkewSeconds is how far in the future a reading time may be,
+	// allowing for clock drift between the client and the server.
+	MaxFutureSkewSeconds int `json:"maxFutureSkewSeconds"`
+
+	Systolic   Range `json:"systolic"`
+	Diastolic  Range `json:"diastolic"`
+	SugarGrams Range `json:"sugarGrams"`
+
+	// In kilograms and milliliters per entry. Readings in other units are
+	// converted before they are checked.
+	Weight  Range `json:"weight"`
+	WaterMl Range `json:"waterMl"`
+
+	// HeartRate applies to every reading. RestingHeartRate replaces the
+	// warning limits for readings taken at rest.
+	HeartRate        Range `json:"heartRate"`
+	RestingHeartRate Range `json:"restingHeartRate"`
+
+	// In mg/dL. Readings in mmol/L are converted before they are checked.
+	BloodGlucose Range `json:"bloodGlucose"`
+
+	// Hours per session.
+	SleepHours Range `json:"sleepHours"`
+
+	// Per activity. The average heart rate of an activity is checked
+	// against HeartRate.
+	ActivityMinutes    Range `json:"activityMinutes"`
+	ActivityDistanceKm Range `json:"activityDistanceKm"`
+	ActivityCalories   Range `json:"activityCalories"`
+
+	// In degrees Celsius. Readings in Fahrenheit are converted before they
+	// are checked.
+	BodyTemperature Range `json:"bodyTemperature"`
+
+	// SpO2 in percent. The pulse of a reading is checked against HeartRate.
+	OxygenSaturation Range `json:"oxygenSaturation"`
+
+	// Height on the user profile, in centimeters.
+	HeightCm Range `json:"heightCm"`
+
+	// Body composition. Masses are in kilograms and are checked along
+	// with the weight of the reading against Weight.
+	BodyFatPercent Range `json:"bodyFatPercent"`
+	WaterPercent   Range `json:"waterPercent"`
+	VisceralFat    Range `json:"visceralFat"`
+
+	// Body circumferences in centimeters.
+	CircumferenceCm Range `json:"circumferenceCm"`
+
+	// Per nutrition entry. Nutrients are in grams except sodium.
+	NutritionCalories Range `json:"nutritionCalories"`
+	NutrientGrams     Range `json:"nutrientGrams"`
+	SodiumMg          Range `json:"sodiumMg"`
+
+	// Symptoms is the vocabulary of symptoms that can be recorded in the
+	// journal.
+	Symptoms []string `json:"symptoms"`
+}
+
+// MaxFutureSkew returns MaxFutureSkewSeconds as a duration.
+func (r Rules) MaxFutureSkew() time.Duration {
+	return time.Duration(r.MaxFutureSkewSeconds) * time.Second
+}
+
+// DefaultRules returns limits that reject physiologically impossible values
+// and warn about values that are possible but unusual.
+func DefaultRules() Rules {
+	return Rules{
+		MaxFutureSkewSeconds: 5 * 60,
+
+		// mmHg. Warnings at hypotension and hypertensive crisis levels.
+		Systolic:  Range{Min: 50, Max: 300, WarnMin: 90, WarnMax: 180},
+		Diastolic: Range{Min: 20, Max: 200, WarnMin: 60, WarnMax: 120},
+
+		// kg.
+		Weight: Range{Min: 0.5, Max: 700, WarnMin: 10, WarnMax: 300},
+
+		// Per entry.
+		WaterMl:    Range{Min: 20, Max: 10000, WarnMax: 4000},
+		SugarGrams: Range{Min: 0, Max: 1000, WarnMax: 250},
+
+		// Beats per minute.
+		HeartRate:        Range{Min: 20, Max: 300, WarnMin: 40, WarnMax: 200},
+		RestingHeartRate: Range{Min: 20, Max: 300, WarnMin: 40, WarnMax: 100},
+
+		// mg/dL. Warnings at severe hypoglycemia and hyperglycemia.
+		BloodGlucose: Range{Min: 10, Max: 1000, WarnMin: 54, WarnMax: 400},
+
+		SleepHours: Range{Min: 0, Max: 24, WarnMax: 14},
+
+		ActivityMinutes:    Range{Min: 1, Max: 1440, WarnMax: 360},
+		ActivityDistanceKm: Range{Min: 0, Max: 1000, WarnMax: 200},
+		ActivityCalories:   Range{Min: 0, Max: 20000, WarnMax: 5000},
+
+		// °C. Warnings at hypothermia and hyperpyrexia levels.
+		BodyTemperature: Range{Min: 25, Max: 45, WarnMin: 35, WarnMax: 41},
+
+		OxygenSaturation: Range{Min: 50, Max: 100, WarnMin: 80},
+
+		HeightCm: Range{Min: 40, Max: 280},
+
+		BodyFatPercent:  Range{Min: 2, Max: 75, WarnMin: 5, WarnMax: 50},
+		WaterPercent:    Range{Min: 20, Max: 80, WarnMin: 40, WarnMax: 70},
+		VisceralFat:     Range{Min: 1, Max: 59, WarnMax: 13},
+		CircumferenceCm: Range{Min: 10, Max: 300, WarnMin: 20, WarnMax: 200},
+
+		NutritionCalories: Range{Min: 0, Max: 10000, WarnMax: 3000},
+		NutrientGrams:     Range{Min: 0, Max: 2000, WarnMax: 300},
+		SodiumMg:          Range{Min: 0, Max: 50000, WarnMax: 5000},
+
+		Symptoms: []string{
+			"headache", "dizziness", "fatigue", "nausea", "fever", "chills",
+			"cough", "sore-throat", "congestion", "shortness-of-breath",
+			"chest-pain", "palpitations", "muscle-ache", "joint-pain",
+			"abdominal-pain", "diarrhea", "constipation", "insomnia",
+			"anxiety", "blurred-vision", "swelling", "rash",
+		},
+	}
+}
+
+var (
+	mu    sync.RWMutex
+	rules = DefaultRules()
+)
+
+// Current returns the rules in effect.
+func Current() Rules {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return rules
+}
+
+// Configure replaces the rules in effect.
+func Configure(r Rules) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	rules = r
+}
+
+// LoadFile reads rules from a JSON file. Settings missing from the file
+// keep their default values.
+func LoadFile(path string) (Rules, error) {
+	r := DefaultRules()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+
+	err = json.Unmarshal(data, &r)
+
+	return r, err
+}
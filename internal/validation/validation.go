@@ -0,0 +1,503 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenkimoto/vitals-server-api/internal/apierror"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+)
+
+// Result collects the field errors and warnings found while validating a
+// request. Errors reject the request; warnings are returned alongside a
+// successful response.
+type Result struct {
+	Errors   []payload.FieldError
+	Warnings []payload.FieldError
+}
+
+// Err returns the errors as a validation *apierror.Error, or nil if there
+// are none.
+func (r Result) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	return apierror.InvalidFields(r.Errors)
+}
+
+// Merge appends the errors and warnings of other to r.
+func (r *Result) Merge(other Result) {
+	r.Errors = append(r.Errors, other.Errors...)
+	r.Warnings = append(r.Warnings, other.Warnings...)
+}
+
+func (r *Result) addError(field string, code string, message string) {
+	r.Errors = append(r.Errors, payload.FieldError{Field: field, Code: code, Message: message})
+}
+
+func (r *Result) addWarning(field string, code string, message string) {
+	r.Warnings = append(r.Warnings, payload.FieldError{Field: field, Code: code, Message: message})
+}
+
+// Checks value against a range, adding an error if it is outside the hard
+// limits or a warning if it is outside the warning limits.
+func (r *Result) checkRange(field string, value float64, limits Range) {
+	switch {
+	case value < limits.Min || value > limits.Max:
+		r.addError(field, "range", fmt.Sprintf("must be between %s and %s", format(limits.Min), format(limits.Max)))
+	case value < limits.WarnMin:
+		r.addWarning(field, "unusually_low", fmt.Sprintf("is unusually low (below %s)", format(limits.WarnMin)))
+	case limits.WarnMax > 0 && value > limits.WarnMax:
+		r.addWarning(field, "unusually_high", fmt.Sprintf("is unusually high (above %s)", format(limits.WarnMax)))
+	}
+}
+
+// Checks an optional value against a range if it is set.
+func (r *Result) checkOptional(field string, value *float64, limits Range) {
+	if value != nil {
+		r.checkRange(field, *value, limits)
+	}
+}
+
+// Time checks that the time in field is not further in the future than
+// the configured skew. A zero time means the current time is used and is
+// always valid.
+func Time(field string, t time.Time) Result {
+	var res Result
+
+	skew := Current().MaxFutureSkew()
+	if !t.IsZero() && t.After(time.Now().Add(skew)) {
+		res.addError(field, "future", "must not be in the future")
+	}
+
+	return res
+}
+
+// BloodPressure checks that the readings are within physiological limits
+// and that the systolic pressure is higher than the diastolic pressure.
+// The pulse is checked against the heart rate limits.
+func BloodPressure(r payload.BloodPressureRequest) Result {
+	var res Result
+	rules := Current()
+
+	res.checkRange("systolic", float64(r.Sys), rules.Systolic)
+	res.checkRange("diastolic", float64(r.Dia), rules.Diastolic)
+
+	if r.Pulse != nil {
+		res.checkRange("pulse", float64(*r.Pulse), rules.HeartRate)
+	}
+
+	if r.Sys <= r.Dia {
+		res.addError("systolic", "gtfield", "must be greater than diastolic")
+	}
+
+	return res
+}
+
+// Weight checks the weight against the limits converted to the unit of
+// the request.
+func Weight(r payload.WeightRequest) Result {
+	var res Result
+	limits, unit := Current().Weight, r.WeightUnit()
+
+	res.checkRange("weight", r.Weight, Range{
+		Min:     payload.WeightFromKg(limits.Min, unit),
+		Max:     payload.WeightFromKg(limits.Max, unit),
+		WarnMin: payload.WeightFromKg(limits.WarnMin, unit),
+		WarnMax: payload.WeightFromKg(limits.WarnMax, unit),
+	})
+	return res
+}
+
+// WaterIntake checks the amount against the limits converted to the unit
+// of the request.
+func WaterIntake(r payload.WaterIntakeRequest) Result {
+	var res Result
+	limits := Current().WaterMl
+	amount, unit, field := r.Volume()
+
+	res.checkRange(field, amount, Range{
+		Min:     payload.WaterFromMl(limits.Min, unit),
+		Max:     payload.WaterFromMl(limits.Max, unit),
+		WarnMin: payload.WaterFromMl(limits.WarnMin, unit),
+		WarnMax: payload.WaterFromMl(limits.WarnMax, unit),
+	})
+	return res
+}
+
+func SugarIntake(r payload.SugarIntakeRequest) Result {
+	var res Result
+	res.checkRange("grams", float64(r.Grams), Current().SugarGrams)
+	return res
+}
+
+// HeartRate checks the rate against the resting limits when the reading
+// was taken at rest and the general limits otherwise.
+func HeartRate(r payload.HeartRateRequest) Result {
+	var res Result
+	rules := Current()
+
+	limits := rules.HeartRate
+	if r.Context == models.HeartRateResting {
+		limits = rules.RestingHeartRate
+	}
+
+	res.checkRange("bpm", float64(r.Bpm), limits)
+	return res
+}
+
+// BloodGlucose checks the reading against the limits converted to the
+// unit of the request.
+func BloodGlucose(r payload.BloodGlucoseRequest) Result {
+	var res Result
+	limits := Current().BloodGlucose
+
+	res.checkRange("value", r.Value, Range{
+		Min:     payload.GlucoseFromMgDl(limits.Min, r.Unit),
+		Max:     payload.GlucoseFromMgDl(limits.Max, r.Unit),
+		WarnMin: payload.GlucoseFromMgDl(limits.WarnMin, r.Unit),
+		WarnMax: payload.GlucoseFromMgDl(limits.WarnMax, r.Unit),
+	})
+	return res
+}
+
+// SleepSession checks that the session ends after it starts, is not
+// longer than the configured limit and that the stage breakdown fits in
+// the session.
+func SleepSession(r payload.SleepSessionRequest) Result {
+	res := Time("end", r.End)
+
+	if !r.End.After(r.Start) {
+		res.addError("end", "gtfield", "must be after start")
+		return res
+	}
+
+	res.checkRange("durationHours", r.End.Sub(r.Start).Hours(), Current().SleepHours)
+
+	if r.Stages != nil && r.Stages.Total() > int(r.End.Sub(r.Start).Minutes())+1 {
+		res.addError("stages", "max", "must not add up to more than the session duration")
+	}
+
+	return res
+}
+
+// Activity checks the duration and the optional distance, calories and
+// average heart rate.
+func Activity(r payload.ActivityRequest) Result {
+	var res Result
+	rules := Current()
+
+	res.checkRange("durationMinutes", float64(r.DurationMinutes), rules.ActivityMinutes)
+
+	if r.DistanceKm != nil {
+		res.checkRange("distanceKm", *r.DistanceKm, rules.ActivityDistanceKm)
+	}
+
+	if r.Calories != nil {
+		res.checkRange("calories", *r.Calories, rules.ActivityCalories)
+	}
+
+	if r.AverageHeartRate != nil {
+		res.checkRange("averageHeartRate", float64(*r.AverageHeartRate), rules.HeartRate)
+	}
+
+	return res
+}
+
+// BodyTemperature checks the reading against the limits converted to the
+// unit of the request.
+func BodyTemperature(r payload.BodyTemperatureRequest) Result {
+	var res Result
+	limits := Current().BodyTemperature
+
+	res.checkRange("value", r.Value, Range{
+		Min:     payload.TemperatureFromCelsius(limits.Min, r.Unit),
+		Max:     payload.TemperatureFromCelsius(limits.Max, r.Unit),
+		WarnMin: payload.TemperatureFromCelsius(limits.WarnMin, r.Unit),
+		WarnMax: payload.TemperatureFromCelsius(limits.WarnMax, r.Unit),
+	})
+	return res
+}
+
+func OxygenSaturation(r payload.OxygenSaturationRequest) Result {
+	var res Result
+	rules := Current()
+
+	res.checkRange("percent", float64(r.Percent), rules.OxygenSaturation)
+
+	if r.Pulse != nil {
+		res.checkRange("pulse", float64(*r.Pulse), rules.HeartRate)
+	}
+
+	return res
+}
+
+// Medication checks that the medication does not end before it starts and
+// that no dose time is scheduled twice. The start date defaults to today.
+func Medication(r payload.MedicationRequest) Result {
+	var res Result
+
+	start := r.StartDate
+	if start == "" {
+		start = time.Now().Format(time.DateOnly)
+	}
+
+	if r.EndDate != nil && *r.EndDate < start {
+		res.addError("endDate", "gtefield", "must not be before startDate")
+	}
+
+	seen := map[string]bool{}
+	for i, t := range r.Schedule {
+		if seen[t] {
+			res.addError(fmt.Sprintf("schedule[%d]", i), "unique", "is listed more than once")
+		}
+		seen[t] = true
+	}
+
+	return res
+}
+
+// Journal checks that the entry is not empty and that the symptoms are
+// part of the configured vocabulary and are not repeated.
+func Journal(r payload.JournalRequest) Result {
+	var res Result
+
+	if r.Mood == nil && r.Energy == nil && r.Notes == "" && len(r.Symptoms) == 0 {
+		res.addError("notes", "required_without_all", "is required without a mood, energy level or symptoms")
+	}
+	vocabulary := Current().Symptoms
+	seen := map[string]bool{}
+
+	for i, s := range r.Symptoms {
+		field := fmt.Sprintf("symptoms[%d]", i)
+
+		switch {
+		case !slices.Contains(vocabulary, s):
+			res.addError(field, "oneof", "must be one of the configured symptoms")
+		case seen[s]:
+			res.addError(field, "unique", "is listed more than once")
+		}
+
+		seen[s] = true
+	}
+
+	return res
+}
+
+// User checks the height and time zone of a user profile.
+func User(r payload.UserRequest) Result {
+	var res Result
+
+	if r.HeightCm != nil {
+		res.checkRange("heightCm", *r.HeightCm, Current().HeightCm)
+	}
+
+	// An empty time zone resets it to UTC.
+	if tz := r.TimeZone; tz != nil && *tz != "" {
+		if _, err := time.LoadLocation(*tz); err != nil || strings.EqualFold(*tz, "local") {
+			res.addError("timeZone", "timezone", "is invalid")
+		}
+	}
+
+	return res
+}
+
+// BodyComposition checks the values of the reading and that the muscle
+// and bone mass do not exceed the weight.
+func BodyComposition(r payload.BodyCompositionRequest) Result {
+	var res Result
+	rules := Current()
+
+	res.checkRange("weightKg", r.WeightKg, rules.Weight)
+	res.checkOptional("bodyFatPercent", r.BodyFatPercent, rules.BodyFatPercent)
+	res.checkOptional("waterPercent", r.WaterPercent, rules.WaterPercent)
+	res.checkOptional("visceralFat", r.VisceralFat, rules.VisceralFat)
+
+	mass := Range{Min: 0, Max: r.WeightKg}
+	res.checkOptional("muscleMassKg", r.MuscleMassKg, mass)
+	res.checkOptional("boneMassKg", r.BoneMassKg, mass)
+
+	return res
+}
+
+// BodyMeasurement checks that at least one circumference is given and
+// that each is within the limits.
+func BodyMeasurement(r payload.BodyMeasurementRequest) Result {
+	var res Result
+	limits := Current().CircumferenceCm
+
+	if r.WaistCm == nil && r.HipCm == nil && r.ChestCm == nil && r.NeckCm == nil {
+		res.addError("waistCm", "required_without_all", "is required without a hip, chest or neck circumference")
+	}
+
+	res.checkOptional("waistCm", r.WaistCm, limits)
+	res.checkOptional("hipCm", r.HipCm, limits)
+	res.checkOptional("chestCm", r.ChestCm, limits)
+	res.checkOptional("neckCm", r.NeckCm, limits)
+
+	return res
+}
+
+// Nutrition checks the nutrients of the entry and that the sugar and
+// fiber are part of the carbohydrates.
+func Nutrition(r payload.NutritionRequest) Result {
+	var res Result
+	rules := Current()
+
+	res.checkOptional("calories", r.Calories, rules.NutritionCalories)
+	res.checkOptional("proteinG", r.ProteinG, rules.NutrientGrams)
+	res.checkOptional("carbsG", r.CarbsG, rules.NutrientGrams)
+	res.checkOptional("fatG", r.FatG, rules.NutrientGrams)
+	res.checkOptional("fiberG", r.FiberG, rules.NutrientGrams)
+	res.checkOptional("sugarG", r.SugarG, rules.NutrientGrams)
+	res.checkOptional("sodiumMg", r.SodiumMg, rules.SodiumMg)
+
+	if r.CarbsG != nil && r.SugarG != nil && *r.SugarG > *r.CarbsG {
+		res.addError("sugarG", "ltefield", "must not be more than carbsG")
+	}
+
+	if r.CarbsG != nil && r.FiberG != nil && *r.FiberG > *r.CarbsG {
+		res.addError("fiberG", "ltefield", "must not be more than carbsG")
+	}
+
+	return res
+}
+
+// ReadingContext checks that the tags of a reading are slugs and are not
+// repeated.
+func ReadingContext(r payload.ReadingContext) Result {
+	var res Result
+	seen := map[string]bool{}
+
+	for i, tag := range r.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+
+		if seen[tag] {
+			res.addError(field, "unique", "is listed more than once")
+		} else {
+			res.checkSlug(field, tag)
+		}
+
+		seen[tag] = true
+	}
+
+	return res
+}
+
+// Tag checks the name of a tag.
+func Tag(field string, tag string) Result {
+	var res Result
+	res.checkSlug(field, tag)
+	return res
+}
+
+// MetricDefinition checks the key format, that the limits are in order and
+// that enums have distinct options without commas.
+func MetricDefinition(r payload.MetricDefinitionRequest) Result {
+	var res Result
+
+	res.checkSlug("key", r.Key)
+
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		res.addError("max", "gtefield", "must not be less than min")
+	}
+
+	if r.Kind != models.MetricEnum {
+		return res
+	}
+
+	if len(r.Options) == 0 {
+		res.addError("options", "required", "is required for enum metrics")
+	}
+
+	seen := map[string]bool{}
+	for i, o := range r.Options {
+		field := fmt.Sprintf("options[%d]", i)
+
+		switch {
+		case strings.Contains(o, ","):
+			res.addError(field, "excludes", "must not contain a comma")
+		case seen[o]:
+			res.addError(field, "unique", "is listed more than once")
+		}
+
+		seen[o] = true
+	}
+
+	return res
+}
+
+// MetricValue checks that the value has the JSON type of the kind of the
+// metric d and is within its limits or options.
+func MetricValue(d models.MetricDefinition, r payload.MetricValueRequest) Result {
+	var res Result
+
+	if r.Value == nil {
+		res.addError("value", "required", "is required")
+		return res
+	}
+
+	switch d.Kind {
+	case models.MetricNumber, models.MetricInteger:
+		f, ok := r.Number()
+		switch {
+		case !ok:
+			res.addError("value", "number", "must be a number")
+		case d.Kind == models.MetricInteger && f != math.Trunc(f):
+			res.addError("value", "integer", "must be a whole number")
+		default:
+			res.checkLimits("value", f, d)
+		}
+	case models.MetricBoolean:
+		if _, ok := r.Bool(); !ok {
+			res.addError("value", "boolean", "must be true or false")
+		}
+	case models.MetricEnum:
+		options := payload.MetricOptions(d)
+		if s, ok := r.Text(); !ok || !slices.Contains(options, s) {
+			res.addError("value", "oneof", "must be one of "+strings.Join(options, ", "))
+		}
+	case models.MetricPair:
+		p, ok := r.Pair()
+		if !ok {
+			res.addError("value", "pair", "must be an array of two numbers")
+			break
+		}
+
+		res.checkLimits("value[0]", p[0], d)
+		res.checkLimits("value[1]", p[1], d)
+	}
+
+	return res
+}
+
+// Checks a value against the optional limits of a custom metric.
+func (r *Result) checkLimits(field string, value float64, d models.MetricDefinition) {
+	switch {
+	case d.Min != nil && value < *d.Min:
+		r.addError(field, "min", "must be at least "+format(*d.Min))
+	case d.Max != nil && value > *d.Max:
+		r.addError(field, "max", "must be at most "+format(*d.Max))
+	}
+}
+
+var slug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Checks that value is a slug like "after-coffee", as used for tags and
+// metric keys. Slugs are at most 50 characters long.
+func (r *Result) checkSlug(field string, value string) {
+	if len(value) > 50 || !slug.MatchString(value) {
+		r.addError(field, "slug", "must be lowercase letters and digits separated by single dashes")
+	}
+}
+
+func format(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
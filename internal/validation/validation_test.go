@@ -0,0 +1,210 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/payload"
+)
+
+func TestBloodPressure(t *testing.T) {
+	res := BloodPressure(payload.BloodPressureRequest{Sys: 120, Dia: 80})
+	assert.Empty(t, res.Errors)
+	assert.Empty(t, res.Warnings)
+	assert.NoError(t, res.Err())
+
+	res = BloodPressure(payload.BloodPressureRequest{Sys: 5, Dia: 900})
+	assert.Equal(t, []string{"systolic", "diastolic", "systolic"}, fields(res.Errors))
+	assert.Equal(t, "gtfield", res.Errors[2].Code)
+	assert.Error(t, res.Err())
+
+	res = BloodPressure(payload.BloodPressureRequest{Sys: 190, Dia: 125})
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, []string{"systolic", "diastolic"}, fields(res.Warnings))
+	assert.Equal(t, "unusually_high", res.Warnings[0].Code)
+
+	pulse := uint16(35)
+	res = BloodPressure(payload.BloodPressureRequest{Sys: 120, Dia: 80, Pulse: &pulse})
+	assert.Equal(t, []string{"pulse"}, fields(res.Warnings))
+}
+
+func TestRanges(t *testing.T) {
+	assert.Equal(t, []string{"weight"}, fields(Weight(payload.WeightRequest{Weight: -5}).Errors))
+	assert.Equal(t, []string{"weight"}, fields(Weight(payload.WeightRequest{Weight: 8}).Warnings))
+	cups := 100.0
+	assert.Equal(t, []string{"cups"}, fields(WaterIntake(payload.WaterIntakeRequest{Cups: &cups}).Errors))
+	assert.Equal(t, []string{"grams"}, fields(SugarIntake(payload.SugarIntakeRequest{Grams: 300}).Warnings))
+}
+
+func TestUnits(t *testing.T) {
+	// 800 lb is above the warning limit of 300 kg but not the hard limit
+	assert.Empty(t, Weight(payload.WeightRequest{Weight: 800, Unit: "lb"}).Errors)
+	assert.Equal(t, []string{"weight"}, fields(Weight(payload.WeightRequest{Weight: 800, Unit: "lb"}).Warnings))
+	assert.Equal(t, []string{"weight"}, fields(Weight(payload.WeightRequest{Weight: 800, Unit: "kg"}).Errors))
+
+	assert.Empty(t, WaterIntake(payload.WaterIntakeRequest{Amount: 500, Unit: "ml"}).Errors)
+	assert.Equal(t, []string{"amount"}, fields(WaterIntake(payload.WaterIntakeRequest{Amount: 500, Unit: "l"}).Errors))
+	assert.Equal(t, []string{"amount"}, fields(WaterIntake(payload.WaterIntakeRequest{Amount: 200, Unit: "fl oz"}).Warnings))
+}
+
+func TestHeartRate(t *testing.T) {
+	assert.Empty(t, HeartRate(payload.HeartRateRequest{Bpm: 150, Context: "active"}).Warnings)
+	assert.Equal(t, []string{"bpm"}, fields(HeartRate(payload.HeartRateRequest{Bpm: 150, Context: "resting"}).Warnings))
+	assert.Equal(t, []string{"bpm"}, fields(HeartRate(payload.HeartRateRequest{Bpm: 400}).Errors))
+}
+
+func TestBloodGlucose(t *testing.T) {
+	assert.Empty(t, BloodGlucose(payload.BloodGlucoseRequest{Value: 5.5, Unit: "mmol/L"}).Warnings)
+	assert.Equal(t, []string{"value"}, fields(BloodGlucose(payload.BloodGlucoseRequest{Value: 2.5, Unit: "mmol/L"}).Warnings))
+	assert.Equal(t, []string{"value"}, fields(BloodGlucose(payload.BloodGlucoseRequest{Value: 120, Unit: "mmol/L"}).Errors))
+	assert.Empty(t, BloodGlucose(payload.BloodGlucoseRequest{Value: 120, Unit: "mg/dL"}).Errors)
+}
+
+func TestSleepSession(t *testing.T) {
+	start := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	session := func(hours float64, stages *payload.SleepStages) payload.SleepSessionRequest {
+		return payload.SleepSessionRequest{Start: start, End: start.Add(time.Duration(hours * float64(time.Hour))), Stages: stages}
+	}
+
+	res := SleepSession(session(8, &payload.SleepStages{Light: 240, Deep: 90, Rem: 120, Awake: 30}))
+	assert.Empty(t, res.Errors)
+	assert.Empty(t, res.Warnings)
+
+	assert.Equal(t, []string{"end"}, fields(SleepSession(session(-1, nil)).Errors))
+	assert.Equal(t, []string{"durationHours"}, fields(SleepSession(session(16, nil)).Warnings))
+	assert.Equal(t, []string{"durationHours"}, fields(SleepSession(session(25, nil)).Errors))
+	assert.Equal(t, []string{"stages"}, fields(SleepSession(session(1, &payload.SleepStages{Light: 90})).Errors))
+}
+
+func TestActivity(t *testing.T) {
+	distance, bpm := 500.0, uint16(350)
+
+	assert.Empty(t, Activity(payload.ActivityRequest{Type: "run", DurationMinutes: 30}).Warnings)
+	assert.Equal(t, []string{"durationMinutes"}, fields(Activity(payload.ActivityRequest{Type: "run", DurationMinutes: 400}).Warnings))
+	assert.Equal(t, []string{"distanceKm"}, fields(Activity(payload.ActivityRequest{Type: "run", DurationMinutes: 30, DistanceKm: &distance}).Warnings))
+	assert.Equal(t, []string{"averageHeartRate"}, fields(Activity(payload.ActivityRequest{Type: "run", DurationMinutes: 30, AverageHeartRate: &bpm}).Errors))
+}
+
+func TestBodyTemperature(t *testing.T) {
+	assert.Empty(t, BodyTemperature(payload.BodyTemperatureRequest{Value: 98.6, Unit: "F"}).Warnings)
+	assert.Equal(t, []string{"value"}, fields(BodyTemperature(payload.BodyTemperatureRequest{Value: 34, Unit: "C"}).Warnings))
+	assert.Equal(t, []string{"value"}, fields(BodyTemperature(payload.BodyTemperatureRequest{Value: 98.6, Unit: "C"}).Errors))
+}
+
+func TestOxygenSaturation(t *testing.T) {
+	pulse := uint16(10)
+
+	assert.Empty(t, OxygenSaturation(payload.OxygenSaturationRequest{Percent: 97}).Warnings)
+	assert.Equal(t, []string{"percent"}, fields(OxygenSaturation(payload.OxygenSaturationRequest{Percent: 75}).Warnings))
+	assert.Equal(t, []string{"percent", "pulse"}, fields(OxygenSaturation(payload.OxygenSaturationRequest{Percent: 101, Pulse: &pulse}).Errors))
+}
+
+func TestMedication(t *testing.T) {
+	end := "2024-02-01"
+
+	assert.Empty(t, Medication(payload.MedicationRequest{Name: "Lisinopril", StartDate: "2024-01-01", EndDate: &end}).Errors)
+	assert.Equal(t, []string{"endDate"}, fields(Medication(payload.MedicationRequest{Name: "Lisinopril", StartDate: "2024-03-01", EndDate: &end}).Errors))
+	assert.Equal(t, []string{"endDate"}, fields(Medication(payload.MedicationRequest{Name: "Lisinopril", EndDate: &end}).Errors))
+}
+
+func TestJournal(t *testing.T) {
+	assert.Empty(t, Journal(payload.JournalRequest{Symptoms: []string{"headache", "fatigue"}}).Errors)
+	assert.Equal(t, []string{"notes"}, fields(Journal(payload.JournalRequest{}).Errors))
+	assert.Equal(t, []string{"symptoms[0]", "symptoms[2]"}, fields(Journal(payload.JournalRequest{Symptoms: []string{"grumpy", "fever", "fever"}}).Errors))
+}
+
+func TestBodyComposition(t *testing.T) {
+	fat, water, bone := 60.0, 30.0, 80.0
+
+	assert.Empty(t, BodyComposition(payload.BodyCompositionRequest{WeightKg: 70}).Errors)
+	assert.Equal(t, []string{"bodyFatPercent", "waterPercent"}, fields(BodyComposition(payload.BodyCompositionRequest{WeightKg: 70, BodyFatPercent: &fat, WaterPercent: &water}).Warnings))
+	assert.Equal(t, []string{"boneMassKg"}, fields(BodyComposition(payload.BodyCompositionRequest{WeightKg: 70, BoneMassKg: &bone}).Errors))
+}
+
+func TestBodyMeasurement(t *testing.T) {
+	waist := 82.0
+
+	assert.Empty(t, BodyMeasurement(payload.BodyMeasurementRequest{WaistCm: &waist}).Errors)
+	assert.Equal(t, []string{"waistCm"}, fields(BodyMeasurement(payload.BodyMeasurementRequest{}).Errors))
+}
+
+func TestNutrition(t *testing.T) {
+	carbs, sugar, sodium := 20.0, 30.0, 8000.0
+
+	assert.Empty(t, Nutrition(payload.NutritionRequest{Name: "Apple", SugarG: &sugar}).Errors)
+	assert.Equal(t, []string{"sodiumMg"}, fields(Nutrition(payload.NutritionRequest{Name: "Soup", SodiumMg: &sodium}).Warnings))
+	assert.Equal(t, []string{"sugarG"}, fields(Nutrition(payload.NutritionRequest{Name: "Cake", CarbsG: &carbs, SugarG: &sugar}).Errors))
+}
+
+func TestReadingContext(t *testing.T) {
+	assert.Empty(t, ReadingContext(payload.ReadingContext{Note: "New scale", Tags: []string{"new-scale", "after-coffee2"}}).Errors)
+	assert.Equal(t, []string{"tags[0]", "tags[1]", "tags[3]"}, fields(ReadingContext(payload.ReadingContext{Tags: []string{"New", "a--b", "ok", "ok"}}).Errors))
+}
+
+func TestMetricDefinition(t *testing.T) {
+	low, high := 10.0, 5.0
+
+	assert.Empty(t, MetricDefinition(payload.MetricDefinitionRequest{Key: "peak-flow", Kind: models.MetricNumber}).Errors)
+	assert.Equal(t, []string{"key"}, fields(MetricDefinition(payload.MetricDefinitionRequest{Key: "Peak Flow", Kind: models.MetricNumber}).Errors))
+	assert.Equal(t, []string{"max"}, fields(MetricDefinition(payload.MetricDefinitionRequest{Key: "pain", Kind: models.MetricInteger, Min: &low, Max: &high}).Errors))
+	assert.Equal(t, []string{"options"}, fields(MetricDefinition(payload.MetricDefinitionRequest{Key: "aura", Kind: models.MetricEnum}).Errors))
+	assert.Equal(t, []string{"options[1]", "options[2]"}, fields(MetricDefinition(payload.MetricDefinitionRequest{
+		Key: "aura", Kind: models.MetricEnum, Options: []string{"none", "a,b", "none"},
+	}).Errors))
+}
+
+func TestMetricValue(t *testing.T) {
+	low, high := 0.0, 10.0
+	number := models.MetricDefinition{Kind: models.MetricNumber, Min: &low, Max: &high}
+	integer := models.MetricDefinition{Kind: models.MetricInteger}
+	enum := models.MetricDefinition{Kind: models.MetricEnum, Options: "none,mild,severe"}
+	pair := models.MetricDefinition{Kind: models.MetricPair, Min: &low, Max: &high}
+
+	assert.Empty(t, MetricValue(number, payload.MetricValueRequest{Value: 2.5}).Errors)
+	assert.Equal(t, []string{"value"}, fields(MetricValue(number, payload.MetricValueRequest{Value: 11.0}).Errors))
+	assert.Equal(t, []string{"value"}, fields(MetricValue(number, payload.MetricValueRequest{Value: "2"}).Errors))
+	assert.Equal(t, []string{"value"}, fields(MetricValue(number, payload.MetricValueRequest{}).Errors))
+	assert.Equal(t, []string{"value"}, fields(MetricValue(integer, payload.MetricValueRequest{Value: 2.5}).Errors))
+	assert.Empty(t, MetricValue(models.MetricDefinition{Kind: models.MetricBoolean}, payload.MetricValueRequest{Value: false}).Errors)
+	assert.Empty(t, MetricValue(enum, payload.MetricValueRequest{Value: "mild"}).Errors)
+	assert.Equal(t, []string{"value"}, fields(MetricValue(enum, payload.MetricValueRequest{Value: "extreme"}).Errors))
+	assert.Empty(t, MetricValue(pair, payload.MetricValueRequest{Value: []any{3.0, 4.0}}).Errors)
+	assert.Equal(t, []string{"value[1]"}, fields(MetricValue(pair, payload.MetricValueRequest{Value: []any{3.0, 40.0}}).Errors))
+	assert.Equal(t, []string{"value"}, fields(MetricValue(pair, payload.MetricValueRequest{Value: []any{3.0}}).Errors))
+}
+
+func TestTime(t *testing.T) {
+	assert.Empty(t, Time("time", time.Time{}).Errors)
+	assert.Empty(t, Time("time", time.Now().Add(time.Minute)).Errors)
+	assert.Equal(t, []string{"time"}, fields(Time("time", time.Now().Add(time.Hour)).Errors))
+}
+
+func TestConfigure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"maxFutureSkewSeconds": 7200, "systolic": {"warnMax": 140}}`), 0o600))
+
+	rules, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, rules.MaxFutureSkew())
+	assert.Equal(t, 140.0, rules.Systolic.WarnMax)
+	assert.Equal(t, DefaultRules().Systolic.Max, rules.Systolic.Max)
+
+	Configure(rules)
+	t.Cleanup(func() { Configure(DefaultRules()) })
+
+	assert.Empty(t, Time("time", time.Now().Add(time.Hour)).Errors)
+	assert.Equal(t, []string{"systolic"}, fields(BloodPressure(payload.BloodPressureRequest{Sys: 150, Dia: 80}).Warnings))
+}
+
+func fields(errs []payload.FieldError) []string {
+	var f []string
+	for _, e := range errs {
+		f = append(f, e.Field)
+	}
+	return f
+}
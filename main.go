@@ -1,15 +1,20 @@
 package main
 
 import (
+	"log"
+	_ "time/tzdata" // time zones of users on hosts without a zoneinfo database
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerfiles "github.com/swaggo/files"     // swagger embed files
 	ginSwagger "github.com/swaggo/gin-swagger" // gin-swagger middleware
+	"github.com/swaggo/swag"
 	docs "github.com/zenkimoto/vitals-server-api/docs"
-	"github.com/zenkimoto/vitals-server-api/internal/controllers"
+	"github.com/zenkimoto/vitals-server-api/internal/blobstore"
 	"github.com/zenkimoto/vitals-server-api/internal/env"
-	"github.com/zenkimoto/vitals-server-api/internal/middleware"
 	"github.com/zenkimoto/vitals-server-api/internal/models"
+	"github.com/zenkimoto/vitals-server-api/internal/routes"
+	"github.com/zenkimoto/vitals-server-api/internal/validation"
 )
 
 func main() {
@@ -17,21 +22,52 @@ func main() {
 
 	host, user, password, dbname := env.GetDatabaseConnectionInfo()
 
+	configureUnits()
+
 	models.InitializeDatabase(host, user, password, dbname)
 
+	if path := env.GetValidationRulesFile(); path != "" {
+		rules, err := validation.LoadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load validation rules from %s: %v", path, err)
+		}
+
+		validation.Configure(rules)
+	}
+
+	blobstore.Configure(blobstore.NewLocal(env.GetBlobStoreDir()))
+
 	startServer()
 }
 
+// Sets the units of readings submitted without a unit. They must be set
+// before the database is initialized, which converts the readings
+// recorded before units were supported.
+func configureUnits() {
+	weightUnit, waterUnit := env.GetDefaultWeightUnit(), env.GetDefaultWaterUnit()
+
+	if _, ok := models.KilogramsPer[weightUnit]; !ok {
+		log.Fatalf("Invalid DEFAULT_WEIGHT_UNIT %q", weightUnit)
+	}
+	if _, ok := models.MillilitersPer[waterUnit]; !ok {
+		log.Fatalf("Invalid DEFAULT_WATER_UNIT %q", waterUnit)
+	}
+
+	models.DefaultWeightUnit = weightUnit
+	models.DefaultWaterUnit = waterUnit
+}
+
 // @title           Vitals Server API
 // @version         1.0
 // @description     <h3>Vitals API is a simple API for tracking health vitals and lifestyle.</h3>
-// @description     <p>The Vitals API tracks weight, blood pressure, water and sugar intake.</p>
+// @description     <p>The Vitals API tracks weight, blood pressure, heart rate, blood glucose, body temperature, oxygen saturation, body composition, sleep, activities, medications, symptoms and mood, nutrition, water and sugar intake, as well as user-defined custom metrics.</p>
 // @description		<h4>To Use the Vitals API:</h4>
 // @description     <ol>
-// @description     <p><li>Log into the /auth endpoint.</li></p>
+// @description     <p><li>Log into the /v1/auth endpoint.</li></p>
 // @description     <p><li>Once successful, you will get a receive a token in the authentication response.<p>The token must be added in the Authorization header in any of the secured endpoints.</p><p>Authorization: Bearer {token}</p></li></p>
-// @description     <p><li>Call any of the endpoints: /weight, /blood-pressure, /water, /sugar</li></p>
+// @description     <p><li>Call any of the endpoints: /weight, /blood-pressure, /heart-rate, /glucose, /temperature, /spo2, /body-composition, /measurements, /sleep, /activities, /medications, /doses, /journal, /nutrition, /water, /sugar, /metric-types, /metrics, /tags, /attachments</li></p>
 // @description     </ol>
+// @description     <p>All endpoints are versioned under /v1. The unversioned endpoints are deprecated aliases of /v1 and will be removed after the date in their Sunset response header.</p>
 //
 // @contact.name	Alex Yip
 //
@@ -46,48 +82,26 @@ func startServer() {
 	// CORS
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-Request-Id"}
+	config.ExposeHeaders = []string{"X-Request-Id", "Deprecation", "Sunset", "Link", "X-Next-Cursor"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE"}
 	config.AllowCredentials = true
 	router.Use(cors.New(config))
 
-	// Public Routes
-	router.GET("/health-check", controllers.HealthCheck)
-
-	router.POST("/auth", controllers.Login)
-	router.POST("/token/validate", controllers.ValidateToken)
-	router.POST("/token/refresh", controllers.RefreshToken)
-
 	// Swagger Set Up
+	// The generated document is served once per API version, each with
+	// its own base path. The root document describes the deprecated
+	// unversioned routes.
 	docs.SwaggerInfo.BasePath = "/"
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
-	// JWT Auth Middleware
-	router.Use(middleware.JwtAuth())
-
-	// Protected Routes
-	router.GET("/users", controllers.GetUsers)
-	router.GET("/users/:id", controllers.GetUserById)
-
-	router.GET("/users/:id/blood-pressure", controllers.GetBloodPressureByUserId)
-	router.POST("/users/:id/blood-pressure", controllers.PostBloodPressureByUserId)
-	router.PUT("/users/:userId/blood-pressure/:id", controllers.PutBloodPressureByUserId)
-	router.DELETE("/users/:userId/blood-pressure/:id", controllers.DeleteBloodPressureByUserId)
-
-	router.GET("/users/:id/weight", controllers.GetWeightByUserId)
-	router.POST("/users/:id/weight", controllers.PostWeightByUserId)
-	router.PUT("/users/:userId/weight/:id", controllers.PutWeightByUserId)
-	router.DELETE("/users/:userId/weight/:id", controllers.DeleteWeightByUserId)
-
-	router.GET("/users/:id/sugar", controllers.GetSugarIntakeByUserId)
-	router.POST("/users/:id/sugar", controllers.PostSugarIntakeByUserId)
-	router.PUT("/users/:userId/sugar/:id", controllers.PutSugarIntakeByUserId)
-	router.DELETE("/users/:userId/sugar/:id", controllers.DeleteSugarIntakeByUserId)
+	v1Docs := *docs.SwaggerInfo
+	v1Docs.BasePath = routes.V1
+	v1Docs.InfoInstanceName = "v1"
+	swag.Register(v1Docs.InstanceName(), &v1Docs)
+	router.GET(routes.V1+"/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler, ginSwagger.InstanceName(v1Docs.InstanceName())))
 
-	router.GET("/users/:id/water", controllers.GetWaterIntakeByUserId)
-	router.POST("/users/:id/water", controllers.PostWaterIntakeByUserId)
-	router.PUT("/users/:userId/water/:id", controllers.PutWaterIntakeByUserId)
-	router.DELETE("/users/:userId/water/:id", controllers.DeleteWaterIntakeByUserId)
+	routes.Register(router)
 
 	router.Run(port)
 }